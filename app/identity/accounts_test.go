@@ -0,0 +1,93 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/password"
+	"auction/pkg/revocation"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAccountsDisable(t *testing.T) {
+	f := newOAuthFixture(t)
+	ctx := context.Background()
+	accounts := NewAccounts(f.store, f.store, f.store, f.revocations)
+
+	user, err := f.store.FindByID(ctx, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := f.issuer.Issue(ctx, user, []string{jwt.AMRPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The watermark has second precision: tokens of the second it is set in
+	// stay valid.
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+	if disabled, err := accounts.Disable(ctx, f.userID); err != nil || !disabled {
+		t.Fatalf("Disable = (%v, %v)", disabled, err)
+	}
+	if disabled, err := accounts.Disable(ctx, f.userID); err != nil || disabled {
+		t.Fatalf("second Disable = (%v, %v)", disabled, err)
+	}
+	if len(f.store.auditEvents) != 1 || f.store.auditEvents[0].Event != auditAccountDisabled {
+		t.Fatalf("audit events %+v", f.store.auditEvents)
+	}
+
+	if _, err := f.issuer.redeem(ctx, pair.RefreshToken, ""); !errors.Is(err, errInvalidRefreshToken) {
+		t.Fatalf("refresh token survived: %v", err)
+	}
+	claims, err := jwt.DecodeAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = revocation.Check(ctx, f.revocations, claims.ID, claims.SessionID, claims.Subject, claims.IssuedAt.Time)
+	if !errors.Is(err, revocation.ErrRevoked) {
+		t.Fatalf("access token survived: %v", err)
+	}
+
+	user, err = f.store.FindByID(ctx, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.issuer.Issue(ctx, user, []string{jwt.AMRPassword}); !errors.Is(err, errUserDisabled) {
+		t.Fatalf("issued tokens to a disabled account: %v", err)
+	}
+
+	if enabled, err := accounts.Enable(ctx, f.userID); err != nil || !enabled {
+		t.Fatalf("Enable = (%v, %v)", enabled, err)
+	}
+	user, _ = f.store.FindByID(ctx, f.userID)
+	if _, err := f.issuer.Issue(ctx, user, []string{jwt.AMRPassword}); err != nil {
+		t.Fatalf("re-enabled account cannot sign in: %v", err)
+	}
+}
+
+func TestLoginDisabledAccount(t *testing.T) {
+	f := newOAuthFixture(t)
+	ctx := context.Background()
+
+	hasher := password.NewArgon2idHasher(password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1})
+	hashed, err := hasher.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.store.UpdatePassword(ctx, f.userID, hashed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.store.SetDisabled(ctx, f.userID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewLoginHandler(f.store, hasher, f.issuer, nil, newTestLockout(f.store), nil)
+	_, err = h.Handle(ctx, &LoginRequest{Email: "bidder@example.com", Password: "correct horse battery staple"})
+
+	var httpErr *httperror.Error
+	if !errors.As(err, &httpErr) || httpErr.Code != "identity.login.account_disabled" {
+		t.Fatalf("got %v, want account_disabled", err)
+	}
+}
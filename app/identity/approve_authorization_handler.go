@@ -0,0 +1,94 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/securetoken"
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ApproveAuthorizationHandler struct {
+	clients           *OAuthClients
+	repository        OAuthRepository
+	sessionRepository SessionRepository
+	codeTTL           time.Duration
+}
+
+type ApproveAuthorizationRequest = AuthorizeRequest
+
+type ApproveAuthorizationResponse = AuthorizeResponse
+
+func NewApproveAuthorizationHandler(clients *OAuthClients, repository OAuthRepository, sessionRepository SessionRepository, codeTTL time.Duration) *ApproveAuthorizationHandler {
+	return &ApproveAuthorizationHandler{
+		clients:           clients,
+		repository:        repository,
+		sessionRepository: sessionRepository,
+		codeTTL:           codeTTL,
+	}
+}
+
+// Handle completes an authorization request for the signed-in user and
+// returns the client's redirect URI with a single-use code. The code starts
+// a new session for the client; auth_time and amr are taken from the
+// session the login page signed in with.
+func (h ApproveAuthorizationHandler) Handle(ctx context.Context, req *ApproveAuthorizationRequest) (*ApproveAuthorizationResponse, error) {
+	client, oauthErr, err := h.clients.checkAuthorization(ctx, req, "identity.approve_authorization")
+	if err != nil {
+		return nil, err
+	}
+	if oauthErr != nil {
+		return &ApproveAuthorizationResponse{RedirectTo: authorizationError(req, oauthErr)}, nil
+	}
+
+	userID := ctx.Value("UserID").(string)
+	sessionID, _ := ctx.Value("SessionID").(string)
+
+	claims, err := jwt.Decode(ctx.Value("Jwt").(string))
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.approve_authorization.internal_server_error", "Internal server error", nil)
+	}
+
+	session, err := h.sessionRepository.FindSession(ctx, userID, sessionID)
+	if errors.Is(err, sql.ErrNoRows) || sessionID == "" {
+		return &ApproveAuthorizationResponse{
+			RedirectTo: authorizationError(req, httperror.OAuth("login_required", "The sign-in session has ended")),
+		}, nil
+	}
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.approve_authorization.internal_server_error", "Internal server error", nil)
+	}
+
+	code, err := securetoken.Generate()
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.approve_authorization.internal_server_error", "Internal server error", nil)
+	}
+
+	err = h.repository.CreateAuthorizationCode(ctx, domain.AuthorizationCode{
+		CodeHash:      securetoken.Hash(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		SessionID:     uuid.New().String(),
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AMR:           strings.Join(claims.AMR, " "),
+		AuthTime:      session.CreatedAt,
+		ExpiresAt:     time.Now().Add(h.codeTTL),
+	})
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.approve_authorization.internal_server_error", "Internal server error", nil)
+	}
+
+	return &ApproveAuthorizationResponse{
+		RedirectTo: authorizationRedirect(req, url.Values{"code": {code}}),
+	}, nil
+}
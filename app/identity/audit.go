@@ -0,0 +1,54 @@
+package identity
+
+import (
+	"auction/domain"
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"go.uber.org/zap"
+)
+
+const (
+	auditPasswordChanged = "password.changed"
+	auditPasswordReset   = "password.reset"
+	auditAccountLocked   = "account.locked"
+	auditAccountUnlocked = "account.unlocked"
+	auditAccountDisabled = "account.disabled"
+	auditAccountEnabled  = "account.enabled"
+	auditSessionRevoked  = "session.revoked"
+	auditDeviceRevoked   = "device.revoked"
+	auditRoleGranted     = "role.granted"
+	auditRoleRevoked     = "role.revoked"
+)
+
+// recordAudit appends an audit event for the user, taking the client IP and
+// User-Agent from the request context. Failures are logged and never fail the
+// request, which has already taken effect.
+func recordAudit(ctx context.Context, repository AuditRepository, userID, event string, metadata map[string]any) {
+	clientIP, _ := ctx.Value("ClientIP").(string)
+	userAgent, _ := ctx.Value("UserAgent").(string)
+
+	var data []byte
+	if len(metadata) > 0 {
+		var err error
+		if data, err = json.Marshal(metadata); err != nil {
+			zap.L().Warn("Failed to encode audit metadata", zap.String("event", event), zap.Error(err))
+		}
+	}
+
+	err := repository.RecordAuditEvent(ctx, domain.AuditEvent{
+		UserID:    nullString(userID),
+		Event:     event,
+		IPAddress: nullString(clientIP),
+		UserAgent: nullString(userAgent),
+		Metadata:  data,
+	})
+	if err != nil {
+		zap.L().Error("Failed to record audit event", zap.String("event", event), zap.String("user_id", userID), zap.Error(err))
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,128 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	maxNonceLength = 512
+
+	codeChallengeMethodS256 = "S256"
+)
+
+// codeChallengePattern matches an RFC 7636 code challenge or verifier.
+var codeChallengePattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// AuthorizeRequest is an OpenID Connect authentication request for the
+// authorization code flow. PKCE with S256 is required from every client.
+type AuthorizeRequest struct {
+	ClientID            string `json:"client_id" query:"client_id" form:"client_id"`
+	RedirectURI         string `json:"redirect_uri" query:"redirect_uri" form:"redirect_uri"`
+	ResponseType        string `json:"response_type" query:"response_type" form:"response_type"`
+	Scope               string `json:"scope" query:"scope" form:"scope"`
+	State               string `json:"state" query:"state" form:"state"`
+	Nonce               string `json:"nonce" query:"nonce" form:"nonce"`
+	CodeChallenge       string `json:"code_challenge" query:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" query:"code_challenge_method" form:"code_challenge_method"`
+}
+
+// AuthorizeResponse is where the browser goes next: the login page, or the
+// client's redirect URI with a code or an error.
+type AuthorizeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// checkAuthorization validates req and normalises its scope. An unknown
+// client or redirect URI is returned as err and shown to the user, since
+// redirecting to an unverified URI would make this an open redirector.
+// Any other problem is returned as an OAuth error to send back to the client.
+func (c *OAuthClients) checkAuthorization(ctx context.Context, req *AuthorizeRequest, prefix string) (*domain.OAuthClient, *httperror.OAuthError, error) {
+	client, err := c.Find(ctx, req.ClientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, httperror.BadRequest(prefix+".invalid_client", "Unknown client", nil)
+	}
+	if err != nil {
+		return nil, nil, httperror.InternalServerError(prefix+".internal_server_error", "Internal server error", nil)
+	}
+
+	if !client.AllowsGrantType(grantTypeAuthorizationCode) {
+		return nil, nil, httperror.BadRequest(prefix+".unauthorized_client", "Client cannot sign users in", nil)
+	}
+
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return nil, nil, httperror.BadRequest(prefix+".invalid_redirect_uri", "Redirect URI is not registered for this client", nil)
+	}
+
+	if req.ResponseType != "code" {
+		return client, httperror.OAuth("unsupported_response_type", "Only the authorization code flow is supported"), nil
+	}
+
+	var scopes []string
+	for _, scope := range strings.Fields(req.Scope) {
+		if !client.AllowsScope(scope) {
+			return client, httperror.OAuth("invalid_scope", "Scope "+scope+" is not allowed for this client"), nil
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if !slices.Contains(scopes, scopeOpenID) {
+		return client, httperror.OAuth("invalid_scope", "Scope must include openid"), nil
+	}
+	req.Scope = strings.Join(scopes, " ")
+
+	if req.CodeChallengeMethod != codeChallengeMethodS256 || !codeChallengePattern.MatchString(req.CodeChallenge) {
+		return client, httperror.OAuth("invalid_request", "A code_challenge with code_challenge_method S256 is required"), nil
+	}
+
+	if len(req.Nonce) > maxNonceLength {
+		return client, httperror.OAuth("invalid_request", "Nonce is too long"), nil
+	}
+
+	return client, nil, nil
+}
+
+// authorizationRedirect returns the client's redirect URI with params and
+// the state of req added to its query. The iss parameter (RFC 9207) lets the
+// client check which provider answered.
+func authorizationRedirect(req *AuthorizeRequest, params url.Values) string {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	params.Set("iss", jwt.Issuer())
+
+	return appendQuery(req.RedirectURI, params)
+}
+
+func authorizationError(req *AuthorizeRequest, oauthErr *httperror.OAuthError) string {
+	return authorizationRedirect(req, url.Values{
+		"error":             {oauthErr.Code},
+		"error_description": {oauthErr.Description},
+	})
+}
+
+// appendQuery adds params to the query of rawURL, keeping any it already
+// has.
+func appendQuery(rawURL string, params url.Values) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := parsed.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
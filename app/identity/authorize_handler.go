@@ -0,0 +1,49 @@
+package identity
+
+import (
+	"context"
+	"net/url"
+)
+
+type AuthorizeHandler struct {
+	clients  *OAuthClients
+	loginURL string
+}
+
+func NewAuthorizeHandler(clients *OAuthClients, loginURL string) *AuthorizeHandler {
+	return &AuthorizeHandler{
+		clients:  clients,
+		loginURL: loginURL,
+	}
+}
+
+// Handle starts an authorization request. The service has no pages of its
+// own, so a valid request is passed on to the login page, which signs the
+// user in as usual and completes it through POST /authorize/approve.
+func (a AuthorizeHandler) Handle(ctx context.Context, req *AuthorizeRequest) (*AuthorizeResponse, error) {
+	client, oauthErr, err := a.clients.checkAuthorization(ctx, req, "identity.authorize")
+	if err != nil {
+		return nil, err
+	}
+	if oauthErr != nil {
+		return &AuthorizeResponse{RedirectTo: authorizationError(req, oauthErr)}, nil
+	}
+
+	params := url.Values{
+		"client_id":             {req.ClientID},
+		"client_name":           {client.Name},
+		"redirect_uri":          {req.RedirectURI},
+		"response_type":         {req.ResponseType},
+		"scope":                 {req.Scope},
+		"code_challenge":        {req.CodeChallenge},
+		"code_challenge_method": {req.CodeChallengeMethod},
+	}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	if req.Nonce != "" {
+		params.Set("nonce", req.Nonce)
+	}
+
+	return &AuthorizeResponse{RedirectTo: appendQuery(a.loginURL, params)}, nil
+}
@@ -0,0 +1,41 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+type BeginPasskeyLoginHandler struct {
+	passkeys *Passkeys
+}
+
+type BeginPasskeyLoginRequest struct {
+}
+
+// BeginPasskeyLoginResponse carries the options for navigator.credentials.get.
+// No credentials are listed, so the authenticator offers its discoverable
+// passkeys for this relying party.
+type BeginPasskeyLoginResponse struct {
+	SessionID string                        `json:"session_id"`
+	Options   *protocol.CredentialAssertion `json:"options"`
+}
+
+func NewBeginPasskeyLoginHandler(passkeys *Passkeys) *BeginPasskeyLoginHandler {
+	return &BeginPasskeyLoginHandler{
+		passkeys: passkeys,
+	}
+}
+
+func (b BeginPasskeyLoginHandler) Handle(ctx context.Context, _ *BeginPasskeyLoginRequest) (*BeginPasskeyLoginResponse, error) {
+	options, sessionID, err := b.passkeys.BeginPasswordlessLogin(ctx)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.begin_passkey_login.internal_server_error", "Internal server error", nil)
+	}
+
+	return &BeginPasskeyLoginResponse{
+		SessionID: sessionID,
+		Options:   options,
+	}, nil
+}
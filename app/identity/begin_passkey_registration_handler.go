@@ -0,0 +1,50 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+type BeginPasskeyRegistrationHandler struct {
+	repository Repository
+	passkeys   *Passkeys
+}
+
+type BeginPasskeyRegistrationRequest struct {
+}
+
+// BeginPasskeyRegistrationResponse carries the options for
+// navigator.credentials.create and the session to finish the ceremony with.
+type BeginPasskeyRegistrationResponse struct {
+	SessionID string                       `json:"session_id"`
+	Options   *protocol.CredentialCreation `json:"options"`
+}
+
+func NewBeginPasskeyRegistrationHandler(repository Repository, passkeys *Passkeys) *BeginPasskeyRegistrationHandler {
+	return &BeginPasskeyRegistrationHandler{
+		repository: repository,
+		passkeys:   passkeys,
+	}
+}
+
+func (b BeginPasskeyRegistrationHandler) Handle(ctx context.Context, _ *BeginPasskeyRegistrationRequest) (*BeginPasskeyRegistrationResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	user, err := b.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.NotFound("identity.begin_passkey_registration.not_found", "User not found", nil)
+	}
+
+	options, sessionID, err := b.passkeys.BeginRegistration(ctx, user)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.begin_passkey_registration.internal_server_error", "Internal server error", nil)
+	}
+
+	return &BeginPasskeyRegistrationResponse{
+		SessionID: sessionID,
+		Options:   options,
+	}, nil
+}
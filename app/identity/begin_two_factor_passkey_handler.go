@@ -0,0 +1,74 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/revocation"
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+type BeginTwoFactorPasskeyHandler struct {
+	repository  Repository
+	revocations revocation.Store
+	passkeys    *Passkeys
+}
+
+// BeginTwoFactorPasskeyRequest takes the MFA token returned by /login.
+type BeginTwoFactorPasskeyRequest struct {
+	Jwt string `json:"jwt"`
+}
+
+type BeginTwoFactorPasskeyResponse struct {
+	SessionID string                        `json:"session_id"`
+	Options   *protocol.CredentialAssertion `json:"options"`
+}
+
+func NewBeginTwoFactorPasskeyHandler(repository Repository, revocations revocation.Store, passkeys *Passkeys) *BeginTwoFactorPasskeyHandler {
+	return &BeginTwoFactorPasskeyHandler{
+		repository:  repository,
+		revocations: revocations,
+		passkeys:    passkeys,
+	}
+}
+
+func (b BeginTwoFactorPasskeyHandler) Handle(ctx context.Context, req *BeginTwoFactorPasskeyRequest) (*BeginTwoFactorPasskeyResponse, error) {
+	claims, err := jwt.DecodeMFAToken(strings.TrimSpace(req.Jwt))
+	if err != nil {
+		return nil, httperror.Unauthorized("identity.begin_two_factor_passkey.invalid_token", "MFA token missing or invalid", nil)
+	}
+
+	err = revocation.Check(ctx, b.revocations, claims.ID, claims.SessionID, claims.Subject, claims.IssuedAt.Time)
+	if errors.Is(err, revocation.ErrRevoked) {
+		return nil, httperror.Unauthorized("identity.begin_two_factor_passkey.invalid_token", "MFA token missing or invalid", nil)
+	}
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.begin_two_factor_passkey.internal_server_error", "Internal server error", nil)
+	}
+
+	user, err := b.repository.FindByID(ctx, claims.Subject)
+	if err != nil {
+		return nil, httperror.NotFound("identity.begin_two_factor_passkey.not_found", "User not found", nil)
+	}
+
+	hasPasskeys, err := b.passkeys.HasCredentials(ctx, user)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.begin_two_factor_passkey.internal_server_error", "Internal server error", nil)
+	}
+	if !hasPasskeys {
+		return nil, httperror.BadRequest("identity.begin_two_factor_passkey.no_passkeys", "No passkey registered", nil)
+	}
+
+	options, sessionID, err := b.passkeys.BeginLogin(ctx, user)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.begin_two_factor_passkey.internal_server_error", "Internal server error", nil)
+	}
+
+	return &BeginTwoFactorPasskeyResponse{
+		SessionID: sessionID,
+		Options:   options,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/mailer"
+	"auction/pkg/password"
+	"auction/pkg/revocation"
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type ChangePasswordHandler struct {
+	repository             Repository
+	refreshTokenRepository RefreshTokenRepository
+	auditRepository        AuditRepository
+	hasher                 password.Hasher
+	policy                 *password.Policy
+	otpVerifier            *OTPVerifier
+	revocations            revocation.Store
+	tokenIssuer            *TokenIssuer
+	mailer                 mailer.Mailer
+	trustedDevices         *TrustedDevices
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+	Code            string `json:"code"`
+}
+
+// ChangePasswordResponse carries a fresh token pair for the caller, whose
+// previous tokens were revoked together with every other session.
+type ChangePasswordResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func NewChangePasswordHandler(repository Repository, refreshTokenRepository RefreshTokenRepository, auditRepository AuditRepository, hasher password.Hasher, policy *password.Policy, otpVerifier *OTPVerifier, revocations revocation.Store, tokenIssuer *TokenIssuer, mailer mailer.Mailer, trustedDevices *TrustedDevices) *ChangePasswordHandler {
+	return &ChangePasswordHandler{
+		repository:             repository,
+		refreshTokenRepository: refreshTokenRepository,
+		auditRepository:        auditRepository,
+		hasher:                 hasher,
+		policy:                 policy,
+		otpVerifier:            otpVerifier,
+		revocations:            revocations,
+		tokenIssuer:            tokenIssuer,
+		mailer:                 mailer,
+		trustedDevices:         trustedDevices,
+	}
+}
+
+func (h ChangePasswordHandler) Handle(ctx context.Context, req *ChangePasswordRequest) (*ChangePasswordResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	req.Code = strings.TrimSpace(req.Code)
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return nil, httperror.BadRequest("identity.change_password.invalid_payload", "Current and new password fields are required", nil)
+	}
+
+	user, err := h.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.NotFound("identity.change_password.not_found", "User not found", nil)
+	}
+
+	_, valid, err := verifyPassword(h.hasher, user, req.CurrentPassword)
+	if err != nil || !valid {
+		return nil, httperror.BadRequest("identity.change_password.invalid_password", "Current password is incorrect", nil)
+	}
+
+	if user.TwoFactorEnabled && user.TwoFactorVerified {
+		if req.Code == "" {
+			return nil, httperror.BadRequest("identity.change_password.code_required", "Two factor code is required", nil)
+		}
+
+		passed, err := h.otpVerifier.Verify(ctx, user, req.Code)
+		if err != nil {
+			return nil, httperror.InternalServerError("identity.change_password.internal_server_error", "Internal server error", nil)
+		}
+		if !passed {
+			return nil, httperror.BadRequest("identity.change_password.invalid_code", "Invalid code", nil)
+		}
+	}
+
+	if same, _ := h.hasher.Verify(req.NewPassword, user.Password); same {
+		return nil, httperror.BadRequest("identity.change_password.password_unchanged", "New password must differ from the current one", nil)
+	}
+
+	if err := checkPasswordPolicy(h.policy, "identity.change_password.weak_password", req.NewPassword, user.Email, user.Name); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := h.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.change_password.internal_server_error", "Internal server error", nil)
+	}
+
+	if err := h.repository.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return nil, httperror.InternalServerError("identity.change_password.internal_server_error", "Internal server error", nil)
+	}
+
+	if err := revocation.RevokeUserTokens(ctx, h.revocations, user.ID); err != nil {
+		return nil, httperror.InternalServerError("identity.change_password.internal_server_error", "Internal server error", nil)
+	}
+
+	if err := h.refreshTokenRepository.RevokeUserRefreshTokens(ctx, user.ID); err != nil {
+		return nil, httperror.InternalServerError("identity.change_password.internal_server_error", "Internal server error", nil)
+	}
+
+	// Remembered devices must pass the second factor again.
+	if err := h.trustedDevices.ForgetAll(ctx, user.ID); err != nil {
+		return nil, httperror.InternalServerError("identity.change_password.internal_server_error", "Internal server error", nil)
+	}
+
+	recordAudit(ctx, h.auditRepository, user.ID, auditPasswordChanged, nil)
+
+	if err := sendPasswordChangedNotice(ctx, h.mailer, user); err != nil {
+		zap.L().Warn("Failed to send password change notice", zap.String("user_id", user.ID), zap.Error(err))
+	}
+
+	pair, err := h.tokenIssuer.Issue(ctx, user, h.currentMethods(ctx))
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.change_password.internal_server_error", "Internal server error", nil)
+	}
+
+	return &ChangePasswordResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}, nil
+}
+
+// currentMethods carries the amr of the caller's token over to the new pair.
+func (h ChangePasswordHandler) currentMethods(ctx context.Context) []string {
+	jwtString, _ := ctx.Value("Jwt").(string)
+
+	claims, err := jwt.DecodeAccessToken(jwtString)
+	if err != nil || len(claims.AMR) == 0 {
+		return []string{jwt.AMRPassword}
+	}
+	return claims.AMR
+}
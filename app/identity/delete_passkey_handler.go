@@ -0,0 +1,67 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type DeletePasskeyHandler struct {
+	repository           Repository
+	credentialRepository CredentialRepository
+}
+
+type DeletePasskeyRequest struct {
+	ID string `params:"id"`
+}
+
+type DeletePasskeyResponse struct {
+}
+
+func NewDeletePasskeyHandler(repository Repository, credentialRepository CredentialRepository) *DeletePasskeyHandler {
+	return &DeletePasskeyHandler{
+		repository:           repository,
+		credentialRepository: credentialRepository,
+	}
+}
+
+// Handle removes one of the user's passkeys. The last one cannot go while
+// passkeys are the second factor, or the next login would not ask for one.
+func (d DeletePasskeyHandler) Handle(ctx context.Context, req *DeletePasskeyRequest) (*DeletePasskeyResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	if _, err := uuid.Parse(req.ID); err != nil {
+		return nil, httperror.NotFound("identity.delete_passkey.not_found", "Passkey not found", nil)
+	}
+
+	user, err := d.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.NotFound("identity.delete_passkey.not_found", "User not found", nil)
+	}
+
+	if user.PasskeySecondFactor {
+		credentials, err := d.credentialRepository.ListCredentials(ctx, userID)
+		if err != nil {
+			return nil, httperror.InternalServerError("identity.delete_passkey.internal_server_error", "Internal server error", nil)
+		}
+		if len(credentials) == 1 && credentials[0].ID == req.ID {
+			return nil, httperror.Conflict(
+				"identity.delete_passkey.last_second_factor",
+				"This is your last passkey. Call POST /2fa/webauthn/disable first",
+				nil,
+			)
+		}
+	}
+
+	deleted, err := d.credentialRepository.DeleteCredential(ctx, userID, req.ID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.delete_passkey.internal_server_error", "Internal server error", nil)
+	}
+	if !deleted {
+		return nil, httperror.NotFound("identity.delete_passkey.not_found", "Passkey not found", nil)
+	}
+
+	return nil, httperror.NoContent("identity.delete_passkey.no_content", "No content", nil)
+}
@@ -0,0 +1,39 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+)
+
+type DisablePasskeySecondFactorHandler struct {
+	repository Repository
+}
+
+type DisablePasskeySecondFactorRequest struct {
+}
+
+type DisablePasskeySecondFactorResponse struct {
+}
+
+func NewDisablePasskeySecondFactorHandler(repository Repository) *DisablePasskeySecondFactorHandler {
+	return &DisablePasskeySecondFactorHandler{
+		repository: repository,
+	}
+}
+
+// Handle stops password logins from asking for a passkey. Passkeys stay
+// registered for passwordless login and as an alternative to OTP.
+func (d DisablePasskeySecondFactorHandler) Handle(ctx context.Context, _ *DisablePasskeySecondFactorRequest) (*DisablePasskeySecondFactorResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	if _, err := d.repository.FindByID(ctx, userID); err != nil {
+		return nil, httperror.NotFound("identity.disable_passkey_second_factor.not_found", "User not found", nil)
+	}
+
+	if err := d.repository.SetPasskeySecondFactor(ctx, userID, false); err != nil {
+		return nil, httperror.InternalServerError("identity.disable_passkey_second_factor.internal_server_error", "Internal server error", nil)
+	}
+
+	return nil, httperror.NoContent("identity.disable_passkey_second_factor.no_content", "No content", nil)
+}
@@ -6,7 +6,8 @@ import (
 )
 
 type DisableTwoFactorHandler struct {
-	repository Repository
+	repository     Repository
+	trustedDevices *TrustedDevices
 }
 
 type DisableTwoFactorRequest struct {
@@ -15,12 +16,15 @@ type DisableTwoFactorRequest struct {
 type DisableTwoFactorResponse struct {
 }
 
-func NewDisableTwoFactorHandler(repository Repository) *DisableTwoFactorHandler {
+func NewDisableTwoFactorHandler(repository Repository, trustedDevices *TrustedDevices) *DisableTwoFactorHandler {
 	return &DisableTwoFactorHandler{
-		repository: repository,
+		repository:     repository,
+		trustedDevices: trustedDevices,
 	}
 }
 
+// Handle turns OTP off. Remembered devices are forgotten too, so they do not
+// skip a second factor the user turns on again later.
 func (e DisableTwoFactorHandler) Handle(ctx context.Context, _ *DisableTwoFactorRequest) (*DisableTwoFactorResponse, error) {
 	val := ctx.Value("UserID")
 	userID := val.(string)
@@ -43,5 +47,14 @@ func (e DisableTwoFactorHandler) Handle(ctx context.Context, _ *DisableTwoFactor
 		)
 	}
 
+	err = e.trustedDevices.ForgetAll(ctx, userID)
+	if err != nil {
+		return nil, httperror.InternalServerError(
+			"identity.enable_two_factor.internal_server_error",
+			"Internal server error",
+			nil,
+		)
+	}
+
 	return nil, httperror.NoContent("identity.enable_two_factor.no_content", "No content", nil)
 }
\ No newline at end of file
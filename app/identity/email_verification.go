@@ -0,0 +1,79 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/jwt"
+	"auction/pkg/mailer"
+	"context"
+	"fmt"
+)
+
+// EmailVerification mails the link that proves a user controls their address.
+// The link points at the frontend, which posts the token to /email/verify.
+type EmailVerification struct {
+	mailer    mailer.Mailer
+	verifyURL string
+}
+
+func NewEmailVerification(mailer mailer.Mailer, verifyURL string) *EmailVerification {
+	return &EmailVerification{
+		mailer:    mailer,
+		verifyURL: verifyURL,
+	}
+}
+
+func (e *EmailVerification) Send(ctx context.Context, user *domain.User) error {
+	token, err := jwt.CreateEmailVerificationToken(user)
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(e.verifyURL, token)
+	if err != nil {
+		return err
+	}
+
+	return sendMail(ctx, e.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s. If you did not create an account, you can ignore this email.\n",
+			user.Name, link, describeDuration(jwt.EmailVerificationTTL()),
+		),
+	})
+}
+
+// SendChange mails the confirmation link for an address change to the new
+// address, which only replaces the current one once the link is followed.
+func (e *EmailVerification) SendChange(ctx context.Context, user *domain.User, newEmail string) error {
+	token, err := jwt.CreateEmailChangeToken(user, newEmail)
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(e.verifyURL, token)
+	if err != nil {
+		return err
+	}
+
+	return sendMail(ctx, e.mailer, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm that you want to use this address for your account by opening the link below:\n\n%s\n\nThe link expires in %s. Until then your previous address stays active. If you did not ask for this, you can ignore this email.\n",
+			user.Name, link, describeDuration(jwt.EmailVerificationTTL()),
+		),
+	})
+}
+
+// NotifyChanged tells the previous address that the account email changed.
+func (e *EmailVerification) NotifyChanged(ctx context.Context, user *domain.User, previousEmail string) error {
+	return sendMail(ctx, e.mailer, mailer.Message{
+		To:      previousEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe email address of your account was changed to %s.\n\nIf you did not do this, reset your password immediately and contact support.\n",
+			user.Name, user.Email,
+		),
+	})
+}
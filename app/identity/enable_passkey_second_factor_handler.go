@@ -0,0 +1,50 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+)
+
+type EnablePasskeySecondFactorHandler struct {
+	repository Repository
+	passkeys   *Passkeys
+}
+
+type EnablePasskeySecondFactorRequest struct {
+}
+
+type EnablePasskeySecondFactorResponse struct {
+}
+
+func NewEnablePasskeySecondFactorHandler(repository Repository, passkeys *Passkeys) *EnablePasskeySecondFactorHandler {
+	return &EnablePasskeySecondFactorHandler{
+		repository: repository,
+		passkeys:   passkeys,
+	}
+}
+
+// Handle makes password logins ask for a passkey. The user needs at least one
+// registered passkey, or they could not sign in any more.
+func (e EnablePasskeySecondFactorHandler) Handle(ctx context.Context, _ *EnablePasskeySecondFactorRequest) (*EnablePasskeySecondFactorResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	user, err := e.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.NotFound("identity.enable_passkey_second_factor.not_found", "User not found", nil)
+	}
+
+	hasPasskeys, err := e.passkeys.HasCredentials(ctx, user)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.enable_passkey_second_factor.internal_server_error", "Internal server error", nil)
+	}
+	if !hasPasskeys {
+		return nil, httperror.BadRequest("identity.enable_passkey_second_factor.no_passkeys", "Register a passkey first", nil)
+	}
+
+	if err := e.repository.SetPasskeySecondFactor(ctx, user.ID, true); err != nil {
+		return nil, httperror.InternalServerError("identity.enable_passkey_second_factor.internal_server_error", "Internal server error", nil)
+	}
+
+	return nil, httperror.NoContent("identity.enable_passkey_second_factor.no_content", "No content", nil)
+}
@@ -1,29 +1,42 @@
 package identity
 
 import (
+	"auction/domain"
+	"auction/pkg/envelope"
 	"auction/pkg/httperror"
 	"auction/pkg/totp"
 	"context"
+	"strings"
 )
 
 type EnableTwoFactorHandler struct {
-	repository Repository
+	repository  Repository
+	secrets     *TwoFactorSecrets
+	otpSettings OTPSettings
 }
 
+// EnableTwoFactorRequest optionally overrides the OTP parameters for tokens
+// that cannot use the service defaults, e.g. SHA256 hardware tokens or HOTP.
 type EnableTwoFactorRequest struct {
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period"`
 }
 
 type EnableTwoFactorResponse struct {
 	TotpUrl string `json:"totp_url"`
 }
 
-func NewEnableTwoFactorHandler(repository Repository) *EnableTwoFactorHandler {
+func NewEnableTwoFactorHandler(repository Repository, secrets *TwoFactorSecrets, otpSettings OTPSettings) *EnableTwoFactorHandler {
 	return &EnableTwoFactorHandler{
-		repository: repository,
+		repository:  repository,
+		secrets:     secrets,
+		otpSettings: otpSettings,
 	}
 }
 
-func (e EnableTwoFactorHandler) Handle(ctx context.Context, _ *EnableTwoFactorRequest) (*EnableTwoFactorResponse, error) {
+func (e EnableTwoFactorHandler) Handle(ctx context.Context, req *EnableTwoFactorRequest) (*EnableTwoFactorResponse, error) {
 	val := ctx.Value("UserID")
 	userID := val.(string)
 
@@ -36,14 +49,39 @@ func (e EnableTwoFactorHandler) Handle(ctx context.Context, _ *EnableTwoFactorRe
 		)
 	}
 
+	customized := req.Type != "" || req.Algorithm != "" || req.Digits != 0 || req.Period != 0
+
 	var secret string
-	if user.TwoFactorEnabled && user.TwoFactorSecret.Valid {
-		secret = user.TwoFactorSecret.String
+	var sealed envelope.Sealed
+	var config domain.TwoFactorConfig
+	if user.TwoFactorEnabled && user.TwoFactorSecret.Valid && !customized {
+		secret, err = e.secrets.Open(ctx, user)
+		if err != nil {
+			return nil, httperror.InternalServerError(
+				"identity.enable_two_factor.internal_server_error",
+				"Internal server error",
+				nil,
+			)
+		}
+		sealed = sealedTwoFactorSecret(user)
+		config = user.TwoFactorConfig()
 	} else {
-		secret = totp.GenerateTwoFactorSecret()
+		config, err = e.resolveConfig(req)
+		if err != nil {
+			return nil, err
+		}
+		secret = totp.GenerateSecret(totp.Algorithm(config.Algorithm))
+		sealed, err = e.secrets.Seal(ctx, user.ID, secret)
+		if err != nil {
+			return nil, httperror.InternalServerError(
+				"identity.enable_two_factor.internal_server_error",
+				"Internal server error",
+				nil,
+			)
+		}
 	}
 
-	err = e.repository.EnableTwoFactor(ctx, userID, secret)
+	err = e.repository.EnableTwoFactor(ctx, userID, sealed, config)
 	if err != nil {
 		return nil, httperror.InternalServerError(
 			"identity.enable_two_factor.internal_server_error",
@@ -52,9 +90,64 @@ func (e EnableTwoFactorHandler) Handle(ctx context.Context, _ *EnableTwoFactorRe
 		)
 	}
 
-	totpUrl := totp.BuildUrl(secret, user.Email, "Auction Identity")
+	opts := totp.Options{
+		Type:      config.Type,
+		Algorithm: totp.Algorithm(config.Algorithm),
+		Digits:    config.Digits,
+		Period:    config.Period,
+	}
+	totpUrl := totp.BuildUrl(secret, user.Email, "Auction Identity", opts)
 
 	return &EnableTwoFactorResponse{
 		TotpUrl: totpUrl,
 	}, nil
-}
\ No newline at end of file
+}
+
+func (e EnableTwoFactorHandler) resolveConfig(req *EnableTwoFactorRequest) (domain.TwoFactorConfig, error) {
+	defaults := e.otpSettings.Defaults
+	config := domain.TwoFactorConfig{
+		Type:      defaults.Type,
+		Algorithm: string(defaults.Algorithm),
+		Digits:    defaults.Digits,
+		Period:    defaults.Period,
+	}
+
+	if req.Type != "" {
+		config.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	}
+	if config.Type != totp.TypeTOTP && config.Type != totp.TypeHOTP {
+		return config, invalidTwoFactorSettings("type must be totp or hotp")
+	}
+
+	if req.Algorithm != "" {
+		algorithm, err := totp.ParseAlgorithm(strings.TrimSpace(req.Algorithm))
+		if err != nil {
+			return config, invalidTwoFactorSettings("algorithm must be SHA1, SHA256 or SHA512")
+		}
+		config.Algorithm = string(algorithm)
+	}
+
+	if req.Digits != 0 {
+		config.Digits = req.Digits
+	}
+	if config.Digits < 6 || config.Digits > 8 {
+		return config, invalidTwoFactorSettings("digits must be between 6 and 8")
+	}
+
+	if req.Period != 0 {
+		config.Period = req.Period
+	}
+	if config.Period < 15 || config.Period > 120 {
+		return config, invalidTwoFactorSettings("period must be between 15 and 120 seconds")
+	}
+
+	return config, nil
+}
+
+func invalidTwoFactorSettings(reason string) error {
+	return httperror.BadRequest(
+		"identity.enable_two_factor.invalid_settings",
+		"Invalid two factor settings",
+		map[string]string{"error": reason},
+	)
+}
@@ -0,0 +1,66 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+type FinishPasskeyLoginHandler struct {
+	passkeys    *Passkeys
+	tokenIssuer *TokenIssuer
+}
+
+// FinishPasskeyLoginRequest carries the PublicKeyCredential returned by
+// navigator.credentials.get, serialised as JSON.
+type FinishPasskeyLoginRequest struct {
+	SessionID  string          `json:"session_id"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+type FinishPasskeyLoginResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func NewFinishPasskeyLoginHandler(passkeys *Passkeys, tokenIssuer *TokenIssuer) *FinishPasskeyLoginHandler {
+	return &FinishPasskeyLoginHandler{
+		passkeys:    passkeys,
+		tokenIssuer: tokenIssuer,
+	}
+}
+
+func (f FinishPasskeyLoginHandler) Handle(ctx context.Context, req *FinishPasskeyLoginRequest) (*FinishPasskeyLoginResponse, error) {
+	req.SessionID = strings.TrimSpace(req.SessionID)
+
+	if req.SessionID == "" || len(req.Credential) == 0 {
+		return nil, httperror.BadRequest("identity.finish_passkey_login.invalid_payload", "Session id and credential are required", nil)
+	}
+
+	user, err := f.passkeys.FinishPasswordlessLogin(ctx, req.SessionID, req.Credential)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.finish_passkey_login.internal_server_error", "Internal server error", nil)
+	}
+	if user == nil {
+		return nil, httperror.Unauthorized("identity.finish_passkey_login.invalid_credential", "Passkey could not be verified", nil)
+	}
+
+	// User verification was required, so the passkey alone satisfies MFA.
+	pair, err := f.tokenIssuer.Issue(ctx, user, []string{jwt.AMRHardwareKey, jwt.AMRMultiFactor})
+	if errors.Is(err, errUserDisabled) {
+		return nil, accountDisabled("identity.finish_passkey_login")
+	}
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.finish_passkey_login.internal_server_error", "Internal server error", nil)
+	}
+
+	return &FinishPasskeyLoginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}, nil
+}
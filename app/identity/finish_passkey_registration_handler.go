@@ -0,0 +1,76 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxPasskeyNameLength = 64
+
+type FinishPasskeyRegistrationHandler struct {
+	repository Repository
+	passkeys   *Passkeys
+}
+
+// FinishPasskeyRegistrationRequest carries the PublicKeyCredential returned by
+// navigator.credentials.create, serialised as JSON.
+type FinishPasskeyRegistrationRequest struct {
+	SessionID  string          `json:"session_id"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+type FinishPasskeyRegistrationResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewFinishPasskeyRegistrationHandler(repository Repository, passkeys *Passkeys) *FinishPasskeyRegistrationHandler {
+	return &FinishPasskeyRegistrationHandler{
+		repository: repository,
+		passkeys:   passkeys,
+	}
+}
+
+func (f FinishPasskeyRegistrationHandler) Handle(ctx context.Context, req *FinishPasskeyRegistrationRequest) (*FinishPasskeyRegistrationResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	req.SessionID = strings.TrimSpace(req.SessionID)
+	req.Name = strings.TrimSpace(req.Name)
+
+	if req.Name == "" {
+		req.Name = "Passkey"
+	}
+	if utf8.RuneCountInString(req.Name) > maxPasskeyNameLength {
+		return nil, httperror.BadRequest("identity.finish_passkey_registration.invalid_name", "Name must be at most 64 characters", nil)
+	}
+
+	if req.SessionID == "" || len(req.Credential) == 0 {
+		return nil, httperror.BadRequest("identity.finish_passkey_registration.invalid_payload", "Session id and credential are required", nil)
+	}
+
+	user, err := f.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.NotFound("identity.finish_passkey_registration.not_found", "User not found", nil)
+	}
+
+	credential, ok, err := f.passkeys.FinishRegistration(ctx, user, req.SessionID, req.Name, req.Credential)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.finish_passkey_registration.internal_server_error", "Internal server error", nil)
+	}
+	if !ok {
+		return nil, httperror.BadRequest("identity.finish_passkey_registration.invalid_credential", "Credential could not be verified", nil)
+	}
+
+	return &FinishPasskeyRegistrationResponse{
+		ID:        credential.ID,
+		Name:      credential.Name,
+		CreatedAt: credential.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,60 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type ForgotPasswordHandler struct {
+	repository     Repository
+	passwordResets *PasswordResets
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ForgotPasswordResponse struct {
+}
+
+func NewForgotPasswordHandler(repository Repository, passwordResets *PasswordResets) *ForgotPasswordHandler {
+	return &ForgotPasswordHandler{
+		repository:     repository,
+		passwordResets: passwordResets,
+	}
+}
+
+// Handle answers 202 whether or not the address belongs to an account. The
+// token is created and mailed in the background so the response time does
+// not reveal it either.
+func (f ForgotPasswordHandler) Handle(ctx context.Context, req *ForgotPasswordRequest) (*ForgotPasswordResponse, error) {
+	req.Email = strings.TrimSpace(req.Email)
+
+	if req.Email == "" {
+		return nil, httperror.BadRequest("identity.forgot_password.email_required", "Email field is required", nil)
+	}
+
+	user, err := f.repository.FindByEmail(ctx, req.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, httperror.InternalServerError("identity.forgot_password.internal_server_error", "Internal server error", nil)
+	}
+
+	if user != nil {
+		go func(ctx context.Context) {
+			if err := f.passwordResets.Start(ctx, user); err != nil {
+				zap.L().Error("Failed to send password reset email", zap.String("user_id", user.ID), zap.Error(err))
+			}
+		}(context.WithoutCancel(ctx))
+	}
+
+	return nil, httperror.Accepted(
+		"identity.forgot_password.accepted",
+		"If an account exists for this email, a reset link has been sent",
+		nil,
+	)
+}
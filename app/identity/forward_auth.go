@@ -0,0 +1,235 @@
+package identity
+
+import (
+	"auction/pkg/jwt"
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Requirements a forward-auth rule can place on the request's token.
+const (
+	requirePublic        = "public"
+	requireUser          = "user"
+	requireScope         = "scope"
+	requirePermission    = "permission"
+	requireSecondFactor  = "2fa"
+	requireVerifiedEmail = "verified_email"
+)
+
+// ForwardAuthRule decides what a token must satisfy for requests matching
+// Method and Path. Path is matched exactly unless it ends in "*", which
+// matches any suffix. Method "*" matches every method.
+type ForwardAuthRule struct {
+	Method        string
+	Path          string
+	Public        bool
+	UserOnly      bool
+	Scopes        []string
+	Permissions   []string
+	SecondFactor  bool
+	VerifiedEmail bool
+}
+
+func (r ForwardAuthRule) matches(method, requestPath string) bool {
+	if r.Method != "*" && r.Method != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(requestPath, prefix)
+	}
+	return r.Path == requestPath
+}
+
+// ForwardAuthPolicies holds the rules of FORWARD_AUTH_POLICIES_FILE. The
+// first matching rule applies; requests no rule matches only need a valid
+// token.
+type ForwardAuthPolicies struct {
+	rules []ForwardAuthRule
+}
+
+// LoadForwardAuthPolicies reads the rules from path. An empty path yields no
+// rules.
+func LoadForwardAuthPolicies(path string) (*ForwardAuthPolicies, error) {
+	if path == "" {
+		return &ForwardAuthPolicies{}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseForwardAuthPolicies(f)
+}
+
+// ParseForwardAuthPolicies reads one rule per line: a method, a path and
+// any number of requirements, e.g.
+// "POST /bids/* permission=bids:create verified_email 2fa".
+// Blank lines and lines starting with # are ignored.
+func ParseForwardAuthPolicies(r io.Reader) (*ForwardAuthPolicies, error) {
+	policies := &ForwardAuthPolicies{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("forward auth policies: line %d: expected a method and a path", line)
+		}
+
+		rule := ForwardAuthRule{
+			Method: strings.ToUpper(fields[0]),
+			Path:   fields[1],
+		}
+		if !strings.HasPrefix(rule.Path, "/") {
+			return nil, fmt.Errorf("forward auth policies: line %d: path %q must start with /", line, rule.Path)
+		}
+
+		for _, requirement := range fields[2:] {
+			name, value, _ := strings.Cut(requirement, "=")
+			switch {
+			case name == requirePublic && value == "":
+				rule.Public = true
+			case name == requireUser && value == "":
+				rule.UserOnly = true
+			case name == requireScope && scopeTokenPattern.MatchString(value):
+				rule.Scopes = append(rule.Scopes, value)
+			case name == requirePermission && scopeTokenPattern.MatchString(value):
+				rule.Permissions = append(rule.Permissions, value)
+			case name == requireSecondFactor && value == "":
+				rule.SecondFactor = true
+			case name == requireVerifiedEmail && value == "":
+				rule.VerifiedEmail = true
+			default:
+				return nil, fmt.Errorf("forward auth policies: line %d: unknown requirement %q", line, requirement)
+			}
+		}
+		if rule.Public && len(fields) > 3 {
+			return nil, fmt.Errorf("forward auth policies: line %d: public cannot be combined with other requirements", line)
+		}
+		// Only client tokens carry scopes and only first-party tokens
+		// permissions, so no token could pass both.
+		if len(rule.Scopes) > 0 && len(rule.Permissions) > 0 {
+			return nil, fmt.Errorf("forward auth policies: line %d: scope and permission cannot be combined", line)
+		}
+
+		policies.rules = append(policies.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// match returns the rule for the forwarded method and URI. The path is
+// decoded and cleaned first so that "/public/../admin" cannot slip past a
+// rule for "/admin/*".
+func (p *ForwardAuthPolicies) match(method, uri string) ForwardAuthRule {
+	if uri == "" {
+		return ForwardAuthRule{}
+	}
+
+	parsed, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return ForwardAuthRule{}
+	}
+	requestPath := path.Clean(parsed.Path)
+	if strings.HasSuffix(parsed.Path, "/") && requestPath != "/" {
+		requestPath += "/"
+	}
+
+	method = strings.ToUpper(method)
+	for _, rule := range p.rules {
+		if rule.matches(method, requestPath) {
+			return rule
+		}
+	}
+	return ForwardAuthRule{}
+}
+
+// ForwardAuthHeader names the response header that carries a value of the
+// validated token to the upstream.
+type ForwardAuthHeader struct {
+	Value  string
+	Header string
+}
+
+// forwardAuthValues are the token values FORWARD_AUTH_HEADERS can map to
+// headers. user_id, email and name are only set for user tokens, roles and
+// permissions only for first-party ones.
+var forwardAuthValues = map[string]func(claims *jwt.Claims, token string) string{
+	"user_id": func(claims *jwt.Claims, _ string) string {
+		if claims.Type == jwt.TokenTypeMachine {
+			return ""
+		}
+		return claims.Subject
+	},
+	"email":     func(claims *jwt.Claims, _ string) string { return claims.Email },
+	"name":      func(claims *jwt.Claims, _ string) string { return claims.Name },
+	"client_id": func(claims *jwt.Claims, _ string) string { return claims.ClientID },
+	"scope":     func(claims *jwt.Claims, _ string) string { return claims.Scope },
+	"sid":       func(claims *jwt.Claims, _ string) string { return claims.SessionID },
+	"amr":       func(claims *jwt.Claims, _ string) string { return strings.Join(claims.AMR, " ") },
+	"roles": func(claims *jwt.Claims, _ string) string {
+		if claims.ClientID != "" {
+			return ""
+		}
+		return strings.Join(claims.Roles, " ")
+	},
+	"permissions": func(claims *jwt.Claims, _ string) string {
+		if claims.ClientID != "" {
+			return ""
+		}
+		return strings.Join(claims.Permissions, " ")
+	},
+	"typ":   func(claims *jwt.Claims, _ string) string { return claims.Type },
+	"token": func(_ *jwt.Claims, token string) string { return "Bearer " + token },
+}
+
+// ParseForwardAuthHeaders parses a comma-separated list of "value:Header"
+// pairs, e.g. "user_id:User-ID,email:User-Email".
+func ParseForwardAuthHeaders(value string) ([]ForwardAuthHeader, error) {
+	var headers []ForwardAuthHeader
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, header, ok := strings.Cut(part, ":")
+		name, header = strings.TrimSpace(name), strings.TrimSpace(header)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("forward auth headers: malformed entry %q", part)
+		}
+		if _, ok := forwardAuthValues[name]; !ok {
+			return nil, fmt.Errorf("forward auth headers: unknown value %q", name)
+		}
+
+		headers = append(headers, ForwardAuthHeader{Value: name, Header: http.CanonicalHeaderKey(header)})
+	}
+
+	return headers, nil
+}
+
+// hasSecondFactor reports whether amr records a second factor. A remembered
+// device stands in for the factor it was remembered with.
+func hasSecondFactor(amr []string) bool {
+	for _, method := range []string{jwt.AMROTP, jwt.AMRRecoveryCode, jwt.AMRHardwareKey, jwt.AMRMultiFactor, jwt.AMRTrustedDevice} {
+		if slices.Contains(amr, method) {
+			return true
+		}
+	}
+	return false
+}
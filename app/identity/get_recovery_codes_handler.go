@@ -3,23 +3,35 @@ package identity
 import (
 	"auction/pkg/httperror"
 	"context"
-	"encoding/json"
+	"time"
 )
 
 type GetRecoveryCodesHandler struct {
-	repository Repository
+	repository             Repository
+	recoveryCodeRepository RecoveryCodeRepository
 }
 
 type GetRecoveryCodesRequest struct {
 }
 
+type RecoveryCodeStatus struct {
+	ID        string     `json:"id"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// GetRecoveryCodesResponse describes the current set without revealing the
+// codes, which are only stored hashed and shown once when generated.
 type GetRecoveryCodesResponse struct {
-	RecoveryCodes []string `json:"recovery_codes"`
+	Total         int                  `json:"total"`
+	Remaining     int                  `json:"remaining"`
+	RecoveryCodes []RecoveryCodeStatus `json:"recovery_codes"`
 }
 
-func NewGetRecoveryCodesHandler(repository Repository) *GetRecoveryCodesHandler {
+func NewGetRecoveryCodesHandler(repository Repository, recoveryCodeRepository RecoveryCodeRepository) *GetRecoveryCodesHandler {
 	return &GetRecoveryCodesHandler{
-		repository: repository,
+		repository:             repository,
+		recoveryCodeRepository: recoveryCodeRepository,
 	}
 }
 
@@ -32,14 +44,7 @@ func (g GetRecoveryCodesHandler) Handle(ctx context.Context, _ *GetRecoveryCodes
 		return nil, httperror.NotFound("identity.get_recovery_codes.not_found", "User not found", nil)
 	}
 
-	var recoveryCodes []string
-
-	recoveryCodesStr := "[]"
-	if user.TwoFactorRecoveryCodes.Valid && user.TwoFactorRecoveryCodes.String != "" {
-		recoveryCodesStr = user.TwoFactorRecoveryCodes.String
-	}
-
-	err = json.Unmarshal([]byte(recoveryCodesStr), &recoveryCodes)
+	codes, err := g.recoveryCodeRepository.ListRecoveryCodes(ctx, user.ID)
 	if err != nil {
 		return nil, httperror.InternalServerError(
 			"identity.get_recovery_codes.server_error",
@@ -48,7 +53,22 @@ func (g GetRecoveryCodesHandler) Handle(ctx context.Context, _ *GetRecoveryCodes
 		)
 	}
 
-	return &GetRecoveryCodesResponse{
-		RecoveryCodes: recoveryCodes,
-	}, nil
-}
\ No newline at end of file
+	res := &GetRecoveryCodesResponse{
+		Total:         len(codes),
+		RecoveryCodes: make([]RecoveryCodeStatus, 0, len(codes)),
+	}
+
+	for _, code := range codes {
+		status := RecoveryCodeStatus{ID: code.ID, CreatedAt: code.CreatedAt}
+		if code.UsedAt.Valid {
+			usedAt := code.UsedAt.Time
+			status.UsedAt = &usedAt
+		} else {
+			res.Remaining++
+		}
+
+		res.RecoveryCodes = append(res.RecoveryCodes, status)
+	}
+
+	return res, nil
+}
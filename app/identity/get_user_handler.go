@@ -1,8 +1,10 @@
 package identity
 
 import (
+	"auction/domain"
 	"auction/pkg/httperror"
 	"context"
+	"time"
 )
 
 type GetUserHandler struct {
@@ -16,8 +18,12 @@ type GetUserResponse struct {
 	ID string `json:"id"`
 	Name     string `json:"name"`
 	Email    string `json:"email"`
+	EmailVerified bool `json:"email_verified"`
+	PendingEmail string `json:"pending_email,omitempty"`
 	TwoFactorVerified bool `json:"two_factor_verified"`
 	TwoFactorEnabled bool `json:"two_factor_enabled"`
+	PasskeySecondFactor bool `json:"passkey_second_factor"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func NewGetUserHandler(repository Repository) *GetUserHandler {
@@ -35,11 +41,20 @@ func (g GetUserHandler) Handle(ctx context.Context, _ *GetUserRequest) (*GetUser
 		return nil, httperror.NotFound("identity.get_user.not_found", "User not found", nil)
 	}
 
-	return &GetUserResponse{
+	res := userProfile(user)
+	return &res, nil
+}
+
+func userProfile(user *domain.User) GetUserResponse {
+	return GetUserResponse{
 		ID:                user.ID,
 		Name:              user.Name,
 		Email:             user.Email,
+		EmailVerified:     user.EmailVerifiedAt.Valid,
+		PendingEmail:      user.PendingEmail.String,
 		TwoFactorVerified: user.TwoFactorVerified,
 		TwoFactorEnabled:  user.TwoFactorEnabled,
-	}, nil
+		PasskeySecondFactor: user.PasskeySecondFactor,
+		UpdatedAt:         user.UpdatedAt,
+	}
 }
\ No newline at end of file
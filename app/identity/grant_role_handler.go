@@ -0,0 +1,44 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"errors"
+)
+
+type GrantRoleHandler struct {
+	roles *Roles
+}
+
+type GrantRoleRequest struct {
+	ID   string `params:"id"`
+	Role string `params:"role"`
+}
+
+type GrantRoleResponse struct {
+}
+
+func NewGrantRoleHandler(roles *Roles) *GrantRoleHandler {
+	return &GrantRoleHandler{
+		roles: roles,
+	}
+}
+
+// Handle grants the role. Granting a role the user already holds is not an
+// error.
+func (g GrantRoleHandler) Handle(ctx context.Context, req *GrantRoleRequest) (*GrantRoleResponse, error) {
+	adminID := ctx.Value("UserID").(string)
+
+	_, err := g.roles.Grant(ctx, req.ID, req.Role, adminID)
+	if errors.Is(err, errUnknownUser) {
+		return nil, httperror.NotFound("identity.grant_role.user_not_found", "User not found", nil)
+	}
+	if errors.Is(err, errUnknownRole) {
+		return nil, httperror.NotFound("identity.grant_role.role_not_found", "Role not found", nil)
+	}
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.grant_role.internal_server_error", "Internal server error", nil)
+	}
+
+	return nil, httperror.NoContent("identity.grant_role.no_content", "No content", nil)
+}
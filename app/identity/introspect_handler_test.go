@@ -0,0 +1,91 @@
+package identity
+
+import (
+	"auction/pkg/jwt"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestIntrospectRequiresScope(t *testing.T) {
+	f := newOAuthFixture(t)
+	ctx := context.Background()
+	handler := NewIntrospectHandler(f.store, f.clients, f.revocations, time.Minute)
+
+	service, serviceSecret, err := f.clients.RegisterService(ctx, "Payments service", []string{"bids:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceServer, resourceServerSecret, err := f.clients.RegisterService(ctx, "Bidding service", []string{"bids:read", scopeIntrospect})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err := jwt.CreateMachineToken(service.ID, "bids:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		secret string
+	}{
+		{"sign-in client", f.client.ID, f.secret},
+		{"service client without the scope", service.ID, serviceSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := handler.Handle(ctx, &IntrospectRequest{Token: token, ClientID: tt.id, ClientSecret: tt.secret})
+			assertOAuthError(t, err, "unauthorized_client")
+		})
+	}
+
+	res, err := handler.Handle(ctx, &IntrospectRequest{Token: token, ClientID: resourceServer.ID, ClientSecret: resourceServerSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Active || res.ClientID != service.ID {
+		t.Fatalf("machine token introspected as %+v", res)
+	}
+}
+
+func TestIntrospectDisabledAccount(t *testing.T) {
+	f := newOAuthFixture(t)
+	ctx := context.Background()
+	handler := NewIntrospectHandler(f.store, f.clients, f.revocations, time.Minute)
+	resourceServer, secret, err := f.clients.RegisterService(ctx, "Bidding service", []string{scopeIntrospect})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := f.store.FindByID(ctx, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := f.issuer.Issue(ctx, user, []string{jwt.AMRPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	introspect := func() *IntrospectResponse {
+		t.Helper()
+		res, err := handler.Handle(ctx, &IntrospectRequest{Token: pair.AccessToken, ClientID: resourceServer.ID, ClientSecret: secret})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	if res := introspect(); !res.Active || res.Username != "bidder@example.com" {
+		t.Fatalf("token of an enabled account: %+v", res)
+	}
+
+	// Disabled directly in the database, without revoking the tokens.
+	f.store.users[f.userID].DisabledAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	if res := introspect(); res.Active {
+		t.Fatalf("token of a disabled account is active: %+v", res)
+	}
+}
@@ -0,0 +1,26 @@
+package identity
+
+import (
+	"auction/pkg/jwt"
+	"context"
+)
+
+type JWKSHandler struct {
+	keyStore *jwt.KeyStore
+}
+
+type JWKSRequest struct {
+}
+
+type JWKSResponse = jwt.JWKSet
+
+func NewJWKSHandler(keyStore *jwt.KeyStore) *JWKSHandler {
+	return &JWKSHandler{
+		keyStore: keyStore,
+	}
+}
+
+func (h JWKSHandler) Handle(_ context.Context, _ *JWKSRequest) (*JWKSResponse, error) {
+	set := h.keyStore.JWKS()
+	return &set, nil
+}
@@ -0,0 +1,57 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"time"
+)
+
+type ListPasskeysHandler struct {
+	credentialRepository CredentialRepository
+}
+
+type ListPasskeysRequest struct {
+}
+
+type PasskeyStatus struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+type ListPasskeysResponse struct {
+	Passkeys []PasskeyStatus `json:"passkeys"`
+}
+
+func NewListPasskeysHandler(credentialRepository CredentialRepository) *ListPasskeysHandler {
+	return &ListPasskeysHandler{
+		credentialRepository: credentialRepository,
+	}
+}
+
+func (l ListPasskeysHandler) Handle(ctx context.Context, _ *ListPasskeysRequest) (*ListPasskeysResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	credentials, err := l.credentialRepository.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.list_passkeys.internal_server_error", "Internal server error", nil)
+	}
+
+	res := &ListPasskeysResponse{
+		Passkeys: make([]PasskeyStatus, 0, len(credentials)),
+	}
+
+	for _, credential := range credentials {
+		status := PasskeyStatus{ID: credential.ID, Name: credential.Name, CreatedAt: credential.CreatedAt}
+		if credential.LastUsedAt.Valid {
+			lastUsedAt := credential.LastUsedAt.Time
+			status.LastUsedAt = &lastUsedAt
+		}
+
+		res.Passkeys = append(res.Passkeys, status)
+	}
+
+	return res, nil
+}
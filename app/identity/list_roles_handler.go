@@ -0,0 +1,53 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"context"
+)
+
+type ListRolesHandler struct {
+	roleRepository RoleRepository
+}
+
+type ListRolesRequest struct {
+}
+
+type RoleStatus struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	IsDefault   bool     `json:"is_default"`
+	Permissions []string `json:"permissions"`
+}
+
+type ListRolesResponse struct {
+	Roles []RoleStatus `json:"roles"`
+}
+
+func NewListRolesHandler(roleRepository RoleRepository) *ListRolesHandler {
+	return &ListRolesHandler{
+		roleRepository: roleRepository,
+	}
+}
+
+func (l ListRolesHandler) Handle(ctx context.Context, _ *ListRolesRequest) (*ListRolesResponse, error) {
+	roles, err := l.roleRepository.ListRoles(ctx)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.list_roles.internal_server_error", "Internal server error", nil)
+	}
+
+	return &ListRolesResponse{Roles: roleStatuses(roles)}, nil
+}
+
+func roleStatuses(roles []domain.Role) []RoleStatus {
+	statuses := make([]RoleStatus, 0, len(roles))
+	for _, role := range roles {
+		statuses = append(statuses, RoleStatus{
+			Name:        role.Name,
+			Description: role.Description,
+			IsDefault:   role.IsDefault,
+			Permissions: role.PermissionNames(),
+		})
+	}
+	return statuses
+}
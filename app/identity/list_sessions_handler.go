@@ -0,0 +1,63 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"time"
+)
+
+type ListSessionsHandler struct {
+	sessionRepository SessionRepository
+}
+
+type ListSessionsRequest struct {
+}
+
+// SessionStatus describes one signed-in client. Current marks the session the
+// request was made from.
+type SessionStatus struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+type ListSessionsResponse struct {
+	Sessions []SessionStatus `json:"sessions"`
+}
+
+func NewListSessionsHandler(sessionRepository SessionRepository) *ListSessionsHandler {
+	return &ListSessionsHandler{
+		sessionRepository: sessionRepository,
+	}
+}
+
+func (l ListSessionsHandler) Handle(ctx context.Context, _ *ListSessionsRequest) (*ListSessionsResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+	currentID, _ := ctx.Value("SessionID").(string)
+
+	sessions, err := l.sessionRepository.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.list_sessions.internal_server_error", "Internal server error", nil)
+	}
+
+	res := &ListSessionsResponse{
+		Sessions: make([]SessionStatus, 0, len(sessions)),
+	}
+
+	for _, session := range sessions {
+		res.Sessions = append(res.Sessions, SessionStatus{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent.String,
+			IPAddress:  session.IPAddress.String,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentID,
+		})
+	}
+
+	return res, nil
+}
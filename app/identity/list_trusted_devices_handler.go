@@ -0,0 +1,65 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"time"
+)
+
+type ListTrustedDevicesHandler struct {
+	trustedDeviceRepository TrustedDeviceRepository
+}
+
+type ListTrustedDevicesRequest struct {
+}
+
+type TrustedDeviceStatus struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+type ListTrustedDevicesResponse struct {
+	Devices []TrustedDeviceStatus `json:"devices"`
+}
+
+func NewListTrustedDevicesHandler(trustedDeviceRepository TrustedDeviceRepository) *ListTrustedDevicesHandler {
+	return &ListTrustedDevicesHandler{
+		trustedDeviceRepository: trustedDeviceRepository,
+	}
+}
+
+func (l ListTrustedDevicesHandler) Handle(ctx context.Context, _ *ListTrustedDevicesRequest) (*ListTrustedDevicesResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	devices, err := l.trustedDeviceRepository.ListTrustedDevices(ctx, userID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.list_trusted_devices.internal_server_error", "Internal server error", nil)
+	}
+
+	res := &ListTrustedDevicesResponse{
+		Devices: make([]TrustedDeviceStatus, 0, len(devices)),
+	}
+
+	for _, device := range devices {
+		status := TrustedDeviceStatus{
+			ID:        device.ID,
+			UserAgent: device.UserAgent.String,
+			IPAddress: device.IPAddress.String,
+			CreatedAt: device.CreatedAt,
+			ExpiresAt: device.ExpiresAt,
+		}
+		if device.LastUsedAt.Valid {
+			lastUsedAt := device.LastUsedAt.Time
+			status.LastUsedAt = &lastUsedAt
+		}
+
+		res.Devices = append(res.Devices, status)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,51 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+type ListUserRolesHandler struct {
+	repository     Repository
+	roleRepository RoleRepository
+}
+
+type ListUserRolesRequest struct {
+	ID string `params:"id"`
+}
+
+type ListUserRolesResponse struct {
+	Roles []RoleStatus `json:"roles"`
+}
+
+func NewListUserRolesHandler(repository Repository, roleRepository RoleRepository) *ListUserRolesHandler {
+	return &ListUserRolesHandler{
+		repository:     repository,
+		roleRepository: roleRepository,
+	}
+}
+
+func (l ListUserRolesHandler) Handle(ctx context.Context, req *ListUserRolesRequest) (*ListUserRolesResponse, error) {
+	if _, err := uuid.Parse(req.ID); err != nil {
+		return nil, httperror.NotFound("identity.list_user_roles.not_found", "User not found", nil)
+	}
+
+	_, err := l.repository.FindByID(ctx, req.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, httperror.NotFound("identity.list_user_roles.not_found", "User not found", nil)
+	}
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.list_user_roles.internal_server_error", "Internal server error", nil)
+	}
+
+	roles, err := l.roleRepository.ListUserRoles(ctx, req.ID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.list_user_roles.internal_server_error", "Internal server error", nil)
+	}
+
+	return &ListUserRolesResponse{Roles: roleStatuses(roles)}, nil
+}
@@ -0,0 +1,189 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"context"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	lockoutScopeAccount = "account"
+	lockoutScopeIP      = "ip"
+)
+
+// LockoutLimits applies to one scope. The first FreeAttempts failures are
+// not delayed; further failures wait twice as long each time, and from
+// Threshold failures on the subject is locked for the full lockout duration.
+type LockoutLimits struct {
+	FreeAttempts int
+	Threshold    int
+}
+
+// LockoutSettings configures brute-force protection for sign-in. Failures
+// are forgotten once none has happened for Window.
+type LockoutSettings struct {
+	Account   LockoutLimits
+	IP        LockoutLimits
+	BaseDelay time.Duration
+	Duration  time.Duration
+	Window    time.Duration
+}
+
+// Lockout tracks failed sign-in attempts per account and per client IP. The
+// IP limits are meant to be looser, since many users can share an address,
+// while still stopping one client from spraying guesses across accounts.
+type Lockout struct {
+	repository      LoginThrottleRepository
+	auditRepository AuditRepository
+	settings        LockoutSettings
+}
+
+func NewLockout(repository LoginThrottleRepository, auditRepository AuditRepository, settings LockoutSettings) *Lockout {
+	return &Lockout{
+		repository:      repository,
+		auditRepository: auditRepository,
+		settings:        settings,
+	}
+}
+
+// Attempt counts a sign-in attempt as failed for the client IP and, when
+// known, the account before the credentials are checked, so that parallel
+// guesses cannot slip past a lock that a sequential guess would hit. It
+// returns a 429 coded "<prefix>.locked" while either one is locked, e.g.
+// identity.login.locked. userID may be empty before the account is known.
+func (l *Lockout) Attempt(ctx context.Context, userID, prefix string) (*LoginAttempt, error) {
+	attempt := &LoginAttempt{lockout: l, userID: userID}
+
+	for _, subject := range l.subjects(ctx, userID) {
+		throttle, counted, err := l.repository.RecordLoginAttempt(ctx, subject.scope, subject.key, l.settings.Window, func(failures int) time.Duration {
+			return l.delay(subject.limits, failures)
+		})
+		if err != nil {
+			zap.L().Error("Failed to record login attempt", zap.String("scope", subject.scope), zap.Error(err))
+			attempt.Pass(ctx)
+			return nil, httperror.InternalServerError(prefix+".internal_server_error", "Internal server error", nil)
+		}
+
+		if !counted {
+			// Refused attempts are not counted against any subject.
+			attempt.Pass(ctx)
+
+			retryAfter := int64(math.Ceil(time.Until(throttle.LockedUntil.Time).Seconds()))
+			return nil, httperror.TooManyRequests(prefix+".locked", "Too many failed attempts, try again later", map[string]any{
+				"retry_after":  max(retryAfter, 1),
+				"locked_until": throttle.LockedUntil.Time.UTC(),
+			})
+		}
+
+		attempt.counted = append(attempt.counted, countedSubject{lockoutSubject: subject, throttle: throttle})
+	}
+
+	return attempt, nil
+}
+
+// LoginAttempt is a sign-in attempt that has been counted as failed until
+// its credentials are found to be right.
+type LoginAttempt struct {
+	lockout *Lockout
+	userID  string
+	counted []countedSubject
+}
+
+type countedSubject struct {
+	lockoutSubject
+	throttle *domain.LoginThrottle
+}
+
+// Fail confirms the attempt as failed, auditing the account lock when this
+// attempt reached the threshold.
+func (a *LoginAttempt) Fail(ctx context.Context) {
+	for _, subject := range a.counted {
+		if subject.scope == lockoutScopeAccount && subject.throttle.Failures == subject.limits.Threshold {
+			recordAudit(ctx, a.lockout.auditRepository, a.userID, auditAccountLocked, map[string]any{
+				"failures":     subject.throttle.Failures,
+				"locked_until": subject.throttle.LockedUntil.Time.UTC(),
+			})
+		}
+	}
+	a.counted = nil
+}
+
+// Pass takes the attempt back because its credentials were right. Earlier
+// failures and any lock the attempt caused are kept; Succeed clears the
+// account once the sign-in is complete. Failures to forgive are logged.
+func (a *LoginAttempt) Pass(ctx context.Context) {
+	for _, subject := range a.counted {
+		if err := a.lockout.repository.ForgiveLoginAttempt(ctx, subject.scope, subject.key); err != nil {
+			zap.L().Warn("Failed to forgive login attempt", zap.String("scope", subject.scope), zap.Error(err))
+		}
+	}
+	a.counted = nil
+}
+
+// Succeed forgets the failures of the account after a completed sign-in.
+// The IP record is kept so that an attacker cannot reset it by signing in
+// to an account of their own between guesses.
+func (l *Lockout) Succeed(ctx context.Context, userID string) {
+	if _, err := l.repository.ClearLoginThrottle(ctx, lockoutScopeAccount, userID); err != nil {
+		zap.L().Warn("Failed to clear login failures", zap.String("user_id", userID), zap.Error(err))
+	}
+}
+
+// Unlock lifts the lockout of an account and reports whether it had any
+// recorded failures.
+func (l *Lockout) Unlock(ctx context.Context, userID string) (bool, error) {
+	cleared, err := l.repository.ClearLoginThrottle(ctx, lockoutScopeAccount, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if cleared {
+		recordAudit(ctx, l.auditRepository, userID, auditAccountUnlocked, nil)
+	}
+	return cleared, nil
+}
+
+// UnlockIP lifts the lockout of a client IP address.
+func (l *Lockout) UnlockIP(ctx context.Context, ip string) (bool, error) {
+	return l.repository.ClearLoginThrottle(ctx, lockoutScopeIP, ip)
+}
+
+func (l *Lockout) delay(limits LockoutLimits, failures int) time.Duration {
+	if failures <= limits.FreeAttempts {
+		return 0
+	}
+	if limits.Threshold > 0 && failures >= limits.Threshold {
+		return l.settings.Duration
+	}
+
+	exponent := failures - limits.FreeAttempts - 1
+	if exponent >= 32 {
+		return l.settings.Duration
+	}
+
+	delay := time.Duration(math.Pow(2, float64(exponent))) * l.settings.BaseDelay
+	return min(delay, l.settings.Duration)
+}
+
+type lockoutSubject struct {
+	scope  string
+	key    string
+	limits LockoutLimits
+}
+
+func (l *Lockout) subjects(ctx context.Context, userID string) []lockoutSubject {
+	var subjects []lockoutSubject
+
+	if clientIP, _ := ctx.Value("ClientIP").(string); clientIP != "" {
+		subjects = append(subjects, lockoutSubject{scope: lockoutScopeIP, key: clientIP, limits: l.settings.IP})
+	}
+	if userID != "" {
+		subjects = append(subjects, lockoutSubject{scope: lockoutScopeAccount, key: userID, limits: l.settings.Account})
+	}
+
+	return subjects
+}
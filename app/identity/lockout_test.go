@@ -0,0 +1,111 @@
+package identity
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestLockout(store *memoryStore) *Lockout {
+	return NewLockout(store, store, LockoutSettings{
+		Account:   LockoutLimits{FreeAttempts: 3, Threshold: 5},
+		IP:        LockoutLimits{FreeAttempts: 20, Threshold: 50},
+		BaseDelay: time.Second,
+		Duration:  15 * time.Minute,
+		Window:    time.Hour,
+	})
+}
+
+// Parallel guesses are counted before any of them is checked, so no more of
+// them get through than sequential guesses would.
+func TestLockoutParallelAttempts(t *testing.T) {
+	store := newMemoryStore()
+	lockout := newTestLockout(store)
+	ctx := context.WithValue(context.Background(), "ClientIP", "203.0.113.7")
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempt, err := lockout.Attempt(ctx, "user-1", "identity.login")
+			if err != nil {
+				return
+			}
+			allowed.Add(1)
+			attempt.Fail(ctx)
+		}()
+	}
+	wg.Wait()
+
+	// The three free attempts plus the one that triggers the first delay.
+	if got := allowed.Load(); got != 4 {
+		t.Fatalf("%d parallel attempts got through, want 4", got)
+	}
+}
+
+func TestLockoutPassForgivesAttempt(t *testing.T) {
+	store := newMemoryStore()
+	lockout := newTestLockout(store)
+	ctx := context.WithValue(context.Background(), "ClientIP", "203.0.113.7")
+
+	for range 2 {
+		attempt, err := lockout.Attempt(ctx, "user-1", "identity.login")
+		if err != nil {
+			t.Fatal(err)
+		}
+		attempt.Fail(ctx)
+	}
+
+	attempt, err := lockout.Attempt(ctx, "user-1", "identity.login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempt.Pass(ctx)
+
+	if failures := store.loginThrottles["account:user-1"].Failures; failures != 2 {
+		t.Fatalf("account has %d failures after a passed attempt, want 2", failures)
+	}
+	if failures := store.loginThrottles["ip:203.0.113.7"].Failures; failures != 2 {
+		t.Fatalf("IP has %d failures after a passed attempt, want 2", failures)
+	}
+
+	lockout.Succeed(ctx, "user-1")
+	if _, ok := store.loginThrottles["account:user-1"]; ok {
+		t.Fatal("Succeed kept the account failures")
+	}
+	if _, ok := store.loginThrottles["ip:203.0.113.7"]; !ok {
+		t.Fatal("Succeed cleared the IP failures")
+	}
+}
+
+// Only the failure that reaches the threshold is audited.
+func TestLockoutAuditsAccountLock(t *testing.T) {
+	store := newMemoryStore()
+	lockout := newTestLockout(store)
+	ctx := context.Background()
+
+	for i := 1; i <= 6; i++ {
+		// Let the delay from the previous failure run out.
+		if throttle, ok := store.loginThrottles["account:user-1"]; ok {
+			throttle.LockedUntil.Valid = false
+		}
+
+		attempt, err := lockout.Attempt(ctx, "user-1", "identity.login")
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+		attempt.Fail(ctx)
+
+		want := 0
+		if i >= 5 {
+			want = 1
+		}
+		if len(store.auditEvents) != want {
+			t.Fatalf("after %d failures: %d audit events, want %d", i, len(store.auditEvents), want)
+		}
+	}
+}
@@ -1,38 +1,55 @@
 package identity
 
 import (
+	"auction/domain"
 	"auction/pkg/jwt"
+	"auction/pkg/password"
 	"context"
 	"database/sql"
 	"errors"
 	"strings"
-	"time"
 
 	"auction/pkg/httperror"
+
+	"go.uber.org/zap"
 )
 
 type LoginHandler struct {
-	repository Repository
+	repository     Repository
+	hasher         password.Hasher
+	tokenIssuer    *TokenIssuer
+	passkeys       *Passkeys
+	lockout        *Lockout
+	trustedDevices *TrustedDevices
 }
 
 type LoginRequest struct {
 	Email    string `json:"email" param:"email"`
 	Password string `json:"password" param:"password"`
+	// DeviceToken is the token of a device remembered after an earlier 2FA
+	// challenge. While valid it replaces the second factor.
+	DeviceToken string `json:"device_token"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
 }
 
-func NewLoginHandler(repository Repository) *LoginHandler {
+func NewLoginHandler(repository Repository, hasher password.Hasher, tokenIssuer *TokenIssuer, passkeys *Passkeys, lockout *Lockout, trustedDevices *TrustedDevices) *LoginHandler {
 	return &LoginHandler{
-		repository: repository,
+		repository:     repository,
+		hasher:         hasher,
+		tokenIssuer:    tokenIssuer,
+		passkeys:       passkeys,
+		lockout:        lockout,
+		trustedDevices: trustedDevices,
 	}
 }
 
 func (h *LoginHandler) Handle(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
 	req.Email = strings.TrimSpace(req.Email)
-	req.Password = strings.TrimSpace(req.Password)
 
 	if req.Email == "" || req.Password == "" {
 		return nil, httperror.BadRequest(
@@ -45,6 +62,13 @@ func (h *LoginHandler) Handle(ctx context.Context, req *LoginRequest) (*LoginRes
 	user, err := h.repository.FindByEmail(ctx, req.Email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			// Unknown emails still count against the client IP.
+			attempt, err := h.lockout.Attempt(ctx, "", "identity.login")
+			if err != nil {
+				return nil, err
+			}
+			attempt.Fail(ctx)
+
 			return nil, httperror.Unauthorized(
 				"identity.login.invalid_credentials",
 				"Invalid email or password",
@@ -61,7 +85,16 @@ func (h *LoginHandler) Handle(ctx context.Context, req *LoginRequest) (*LoginRes
 		)
 	}
 
-	if !user.ValidatePassword(req.Password) {
+	// A locked account is refused even with the right password, so guessing
+	// cannot continue while it is locked.
+	attempt, err := h.lockout.Attempt(ctx, user.ID, "identity.login")
+	if err != nil {
+		return nil, err
+	}
+
+	matched, valid, err := verifyPassword(h.hasher, user, req.Password)
+	if err != nil || !valid {
+		attempt.Fail(ctx)
 		return nil, httperror.Unauthorized(
 			"identity.login.invalid_credentials",
 			"Invalid email or password",
@@ -69,8 +102,37 @@ func (h *LoginHandler) Handle(ctx context.Context, req *LoginRequest) (*LoginRes
 		)
 	}
 
-	if user.TwoFactorEnabled && user.TwoFactorVerified {
-		tfaJwt, err := jwt.CreateToken(user)
+	attempt.Pass(ctx)
+	h.rehashIfNeeded(ctx, user.ID, user.Password, matched)
+
+	if user.IsDisabled() {
+		return nil, accountDisabled("identity.login")
+	}
+
+	methods, err := h.secondFactors(ctx, user)
+	if errors.Is(err, errPasskeyFactorMissing) {
+		return nil, httperror.Forbidden(
+			"identity.login.second_factor_unavailable",
+			"No passkey registered for the second factor",
+			nil,
+		)
+	}
+	if err != nil {
+		return nil, httperror.InternalServerError(
+			"identity.login.lookup_failed",
+			"Invalid user",
+			nil,
+		)
+	}
+
+	amr := []string{jwt.AMRPassword}
+	if len(methods) > 0 && h.recognizeDevice(ctx, user, req.DeviceToken) {
+		methods = nil
+		amr = append(amr, jwt.AMRTrustedDevice)
+	}
+
+	if len(methods) > 0 {
+		mfaJwt, mfaClaims, err := jwt.CreateMFAToken(user)
 
 		if err != nil {
 			return nil, httperror.InternalServerError(
@@ -84,16 +146,18 @@ func (h *LoginHandler) Handle(ctx context.Context, req *LoginRequest) (*LoginRes
 			"identity.login.accepted",
 			"Request accepted. Verify otp",
 			struct {
-				Jwt string `json:"jwt"`
-				ExpiresIn int64 `json:"expires_at"`
+				Jwt       string   `json:"jwt"`
+				ExpiresAt int64    `json:"expires_at"`
+				Methods   []string `json:"methods"`
 			}{
-				Jwt: tfaJwt,
-				ExpiresIn: time.Now().Add(time.Hour).Unix(),
+				Jwt:       mfaJwt,
+				ExpiresAt: mfaClaims.ExpiresAt.Unix(),
+				Methods:   methods,
 			},
 		)
 	}
 
-	token, err := jwt.CreateToken(user)
+	pair, err := h.tokenIssuer.Issue(ctx, user, amr)
 	if err != nil {
 		return nil, httperror.InternalServerError(
 			"identity.login.token_generation_failed",
@@ -102,5 +166,82 @@ func (h *LoginHandler) Handle(ctx context.Context, req *LoginRequest) (*LoginRes
 		)
 	}
 
-	return &LoginResponse{Token: token}, nil
+	// With a second factor pending the failures are kept until
+	// /2fa/challenge succeeds, so a known password cannot reset the count
+	// of guessed codes.
+	h.lockout.Succeed(ctx, user.ID)
+
+	return &LoginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}, nil
+}
+
+// errPasskeyFactorMissing means the user asks for a passkey after the
+// password but has none registered.
+var errPasskeyFactorMissing = errors.New("identity: passkey second factor without passkeys")
+
+// secondFactors lists the factors the user can complete /2fa/challenge with.
+// A second factor is required once OTP is verified or the user turned on
+// passkeys as a second factor; either way a registered passkey may answer it.
+// A user who turned passkeys on but has none left gets errPasskeyFactorMissing
+// rather than a password-only login.
+func (h *LoginHandler) secondFactors(ctx context.Context, user *domain.User) ([]string, error) {
+	otp := user.TwoFactorEnabled && user.TwoFactorVerified
+	if !otp && !user.PasskeySecondFactor {
+		return nil, nil
+	}
+
+	var methods []string
+	if otp {
+		methods = append(methods, secondFactorOTP)
+	}
+
+	hasPasskeys, err := h.passkeys.HasCredentials(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if hasPasskeys {
+		methods = append(methods, secondFactorWebAuthn)
+	} else if user.PasskeySecondFactor {
+		return nil, errPasskeyFactorMissing
+	}
+
+	return methods, nil
+}
+
+// recognizeDevice reports whether token belongs to a device the user
+// remembered. Lookup failures fall back to asking for the second factor.
+func (h *LoginHandler) recognizeDevice(ctx context.Context, user *domain.User, token string) bool {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return false
+	}
+
+	recognized, err := h.trustedDevices.Recognize(ctx, user, token)
+	if err != nil {
+		zap.L().Warn("Failed to check trusted device", zap.String("user_id", user.ID), zap.Error(err))
+		return false
+	}
+	return recognized
+}
+
+// rehashIfNeeded upgrades legacy or outdated hashes once the plaintext is known
+// to be correct. plain must be the plaintext that verified, so the stored
+// password does not change. Failures are logged and never block the login.
+func (h *LoginHandler) rehashIfNeeded(ctx context.Context, userID, encoded, plain string) {
+	if !h.hasher.NeedsRehash(encoded) {
+		return
+	}
+
+	hashed, err := h.hasher.Hash(plain)
+	if err != nil {
+		zap.L().Warn("Failed to rehash password", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+
+	if err := h.repository.RehashPassword(ctx, userID, hashed); err != nil {
+		zap.L().Warn("Failed to store rehashed password", zap.String("user_id", userID), zap.Error(err))
+	}
 }
@@ -0,0 +1,89 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/password"
+	"context"
+	"testing"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	hasher := password.NewArgon2idHasher(password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1})
+	hashed, err := hasher.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashedSpaced, err := hasher.Hash(" hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		stored      string
+		trimmed     bool
+		input       string
+		wantValid   bool
+		wantMatched string
+	}{
+		{"exact", hashed, false, "hunter2", true, "hunter2"},
+		{"padded input", hashed, false, " hunter2", false, " hunter2"},
+		{"padded input, trimmed hash", hashed, true, " hunter2 ", true, "hunter2"},
+		{"padded password", hashedSpaced, false, " hunter2", true, " hunter2"},
+		{"padded password, trimmed input", hashedSpaced, false, "hunter2", false, "hunter2"},
+		{"wrong password, trimmed hash", hashed, true, " hunter3", false, "hunter3"},
+		{"whitespace only, trimmed hash", hashed, true, "   ", false, "   "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &domain.User{Password: tt.stored, PasswordTrimmed: tt.trimmed}
+
+			matched, valid, err := verifyPassword(hasher, user, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid != tt.wantValid || matched != tt.wantMatched {
+				t.Fatalf("got (%q, %v), want (%q, %v)", matched, valid, tt.wantMatched, tt.wantValid)
+			}
+		})
+	}
+}
+
+// A rehash after a trimmed match must store the password the user has, not
+// the padded input.
+func TestLoginRehashKeepsTrimmedPassword(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+
+	previous := password.NewArgon2idHasher(password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1})
+	current := password.NewArgon2idHasher(password.Argon2idParams{Memory: 2048, Iterations: 1, Parallelism: 1})
+	h := &LoginHandler{repository: store, hasher: current}
+
+	hashed, err := previous.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := store.addUser(domain.User{Email: "bidder@example.com", Password: hashed, PasswordTrimmed: true})
+	user, _ := store.FindByID(ctx, id)
+
+	matched, valid, err := verifyPassword(h.hasher, user, " hunter2")
+	if err != nil || !valid {
+		t.Fatalf("padded input rejected: %v", err)
+	}
+	h.rehashIfNeeded(ctx, user.ID, user.Password, matched)
+
+	user, _ = store.FindByID(ctx, id)
+	if user.Password == hashed {
+		t.Fatal("hash was not upgraded")
+	}
+	if !user.PasswordTrimmed {
+		t.Fatal("rehash cleared password_trimmed")
+	}
+	if ok, _ := current.Verify("hunter2", user.Password); !ok {
+		t.Fatal("upgraded hash no longer matches the registered password")
+	}
+	if ok, _ := current.Verify(" hunter2", user.Password); ok {
+		t.Fatal("upgraded hash stores the padded input")
+	}
+}
@@ -0,0 +1,52 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/revocation"
+	"context"
+)
+
+type LogoutAllHandler struct {
+	refreshTokenRepository RefreshTokenRepository
+	revocations            revocation.Store
+	trustedDevices         *TrustedDevices
+}
+
+type LogoutAllRequest struct {
+}
+
+type LogoutAllResponse struct {
+}
+
+func NewLogoutAllHandler(refreshTokenRepository RefreshTokenRepository, revocations revocation.Store, trustedDevices *TrustedDevices) *LogoutAllHandler {
+	return &LogoutAllHandler{
+		refreshTokenRepository: refreshTokenRepository,
+		revocations:            revocations,
+		trustedDevices:         trustedDevices,
+	}
+}
+
+// Handle invalidates every access token issued to the user so far by moving
+// the watermark forward, revokes all of the user's refresh tokens and forgets
+// their remembered devices.
+func (h LogoutAllHandler) Handle(ctx context.Context, _ *LogoutAllRequest) (*LogoutAllResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	err := revocation.RevokeUserTokens(ctx, h.revocations, userID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.logout_all.server_error", "Internal server error", nil)
+	}
+
+	err = h.refreshTokenRepository.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.logout_all.server_error", "Internal server error", nil)
+	}
+
+	err = h.trustedDevices.ForgetAll(ctx, userID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.logout_all.server_error", "Internal server error", nil)
+	}
+
+	return nil, httperror.NoContent("identity.logout_all.no_content", "No content", nil)
+}
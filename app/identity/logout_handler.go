@@ -0,0 +1,76 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/revocation"
+	"auction/pkg/securetoken"
+	"context"
+	"strings"
+	"time"
+)
+
+type LogoutHandler struct {
+	refreshTokenRepository RefreshTokenRepository
+	sessionRepository      SessionRepository
+	revocations            revocation.Store
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutResponse struct {
+}
+
+func NewLogoutHandler(refreshTokenRepository RefreshTokenRepository, sessionRepository SessionRepository, revocations revocation.Store) *LogoutHandler {
+	return &LogoutHandler{
+		refreshTokenRepository: refreshTokenRepository,
+		sessionRepository:      sessionRepository,
+		revocations:            revocations,
+	}
+}
+
+// Handle denylists the presented access token and ends its session, which
+// revokes the refresh token family that came with it. Tokens issued before
+// sessions existed carry no sid; for those the client passes its refresh
+// token to revoke the family.
+func (h LogoutHandler) Handle(ctx context.Context, req *LogoutRequest) (*LogoutResponse, error) {
+	val := ctx.Value("Jwt")
+	jwtString := val.(string)
+
+	claims, err := jwt.Decode(jwtString)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.logout.server_error", "Internal server error", nil)
+	}
+
+	err = h.revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.logout.server_error", "Internal server error", nil)
+	}
+
+	if claims.SessionID != "" {
+		if _, err := h.sessionRepository.RevokeSession(ctx, claims.Subject, claims.SessionID); err != nil {
+			return nil, httperror.InternalServerError("identity.logout.server_error", "Internal server error", nil)
+		}
+
+		// Earlier access tokens of the session may still be unexpired.
+		err = h.revocations.RevokeToken(ctx, claims.SessionID, time.Now().Add(jwt.AccessTokenTTL()))
+		if err != nil {
+			return nil, httperror.InternalServerError("identity.logout.server_error", "Internal server error", nil)
+		}
+	}
+
+	req.RefreshToken = strings.TrimSpace(req.RefreshToken)
+	if req.RefreshToken != "" {
+		stored, err := h.refreshTokenRepository.FindRefreshTokenByHash(ctx, securetoken.Hash(req.RefreshToken))
+		if err == nil && stored.UserID == claims.Subject {
+			err = h.refreshTokenRepository.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+			if err != nil {
+				return nil, httperror.InternalServerError("identity.logout.server_error", "Internal server error", nil)
+			}
+		}
+	}
+
+	return nil, httperror.NoContent("identity.logout.no_content", "No content", nil)
+}
@@ -0,0 +1,659 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/envelope"
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore keeps the repositories the tests need in maps, standing in for
+// PgRepository.
+type memoryStore struct {
+	mu               sync.Mutex
+	users            map[string]*domain.User
+	credentials      map[string]*domain.Credential
+	webAuthnSessions map[string]*domain.WebAuthnSession
+	loginThrottles   map[string]*domain.LoginThrottle
+	auditEvents      []domain.AuditEvent
+	refreshTokens    map[string]*domain.RefreshToken
+	sessions         map[string]*domain.Session
+	oauthClients     map[string]*domain.OAuthClient
+	codes            map[string]*domain.AuthorizationCode
+	userRoles        map[string][]domain.Role
+	trustedDevices   map[string]*domain.TrustedDevice
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:            make(map[string]*domain.User),
+		credentials:      make(map[string]*domain.Credential),
+		webAuthnSessions: make(map[string]*domain.WebAuthnSession),
+		loginThrottles:   make(map[string]*domain.LoginThrottle),
+		refreshTokens:    make(map[string]*domain.RefreshToken),
+		sessions:         make(map[string]*domain.Session),
+		oauthClients:     make(map[string]*domain.OAuthClient),
+		codes:            make(map[string]*domain.AuthorizationCode),
+		userRoles:        make(map[string][]domain.Role),
+		trustedDevices:   make(map[string]*domain.TrustedDevice),
+	}
+}
+
+// addUser stores a copy of user under a new id and returns the id.
+func (m *memoryStore) addUser(user domain.User) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user.ID = uuid.NewString()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	m.users[user.ID] = &user
+	return user.ID
+}
+
+func (m *memoryStore) FindByID(_ context.Context, id string) (*domain.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (m *memoryStore) FindByEmail(_ context.Context, email string) (*domain.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if strings.EqualFold(user.Email, email) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *memoryStore) Create(_ context.Context, email, password, name string) (string, error) {
+	return m.addUser(domain.User{Email: email, Password: password, Name: name}), nil
+}
+
+func (m *memoryStore) update(id string, apply func(user *domain.User)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	apply(user)
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *memoryStore) Update(_ context.Context, id, email, name string) error {
+	return m.update(id, func(user *domain.User) {
+		user.Email = email
+		user.Name = name
+	})
+}
+
+func (m *memoryStore) UpdatePassword(_ context.Context, id, password string) error {
+	return m.update(id, func(user *domain.User) {
+		user.Password = password
+		user.PasswordTrimmed = false
+	})
+}
+
+func (m *memoryStore) RehashPassword(_ context.Context, id, password string) error {
+	return m.update(id, func(user *domain.User) { user.Password = password })
+}
+
+func (m *memoryStore) EnableTwoFactor(_ context.Context, id string, secret envelope.Sealed, config domain.TwoFactorConfig) error {
+	return m.update(id, func(user *domain.User) {
+		user.TwoFactorEnabled = true
+		user.TwoFactorSecret = sql.NullString{String: secret.Ciphertext, Valid: true}
+		user.TwoFactorType = config.Type
+		user.TwoFactorAlgorithm = config.Algorithm
+		user.TwoFactorDigits = config.Digits
+		user.TwoFactorPeriod = config.Period
+	})
+}
+
+func (m *memoryStore) DisableTwoFactor(_ context.Context, id string) error {
+	return m.update(id, func(user *domain.User) {
+		user.TwoFactorEnabled = false
+		user.TwoFactorVerified = false
+		user.TwoFactorSecret = sql.NullString{}
+		user.TwoFactorLastCounter = sql.NullInt64{}
+	})
+}
+
+func (m *memoryStore) SetPasskeySecondFactor(_ context.Context, id string, enabled bool) error {
+	return m.update(id, func(user *domain.User) { user.PasskeySecondFactor = enabled })
+}
+
+func (m *memoryStore) SetDisabled(_ context.Context, id string, disabled bool) (bool, error) {
+	changed := false
+	err := m.update(id, func(user *domain.User) {
+		if user.IsDisabled() == disabled {
+			return
+		}
+		user.DisabledAt = sql.NullTime{Time: time.Now(), Valid: disabled}
+		changed = true
+	})
+	return changed, err
+}
+
+func (m *memoryStore) MarkTwoFactorVerified(_ context.Context, id string) error {
+	return m.update(id, func(user *domain.User) { user.TwoFactorVerified = true })
+}
+
+func (m *memoryStore) AcceptTwoFactorCounter(_ context.Context, id string, counter int64) (bool, error) {
+	accepted := false
+	err := m.update(id, func(user *domain.User) {
+		if user.TwoFactorLastCounter.Valid && user.TwoFactorLastCounter.Int64 >= counter {
+			return
+		}
+		user.TwoFactorLastCounter = sql.NullInt64{Int64: counter, Valid: true}
+		accepted = true
+	})
+	return accepted, err
+}
+
+func (m *memoryStore) MarkEmailVerified(_ context.Context, id, email string) (bool, error) {
+	marked := false
+	err := m.update(id, func(user *domain.User) {
+		if user.Email == email {
+			user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			marked = true
+		}
+	})
+	return marked, err
+}
+
+func (m *memoryStore) SetPendingEmail(_ context.Context, id, email string) error {
+	return m.update(id, func(user *domain.User) {
+		user.PendingEmail = sql.NullString{String: email, Valid: email != ""}
+	})
+}
+
+func (m *memoryStore) ConfirmPendingEmail(_ context.Context, id, email string) (bool, error) {
+	confirmed := false
+	err := m.update(id, func(user *domain.User) {
+		if user.PendingEmail.Valid && user.PendingEmail.String == email {
+			user.Email = email
+			user.PendingEmail = sql.NullString{}
+			user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			confirmed = true
+		}
+	})
+	return confirmed, err
+}
+
+func (m *memoryStore) CreateCredential(_ context.Context, userID string, credentialID []byte, name string, data []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := uuid.NewString()
+	m.credentials[id] = &domain.Credential{
+		ID:           id,
+		UserID:       userID,
+		CredentialID: credentialID,
+		Name:         name,
+		Data:         data,
+		CreatedAt:    time.Now(),
+	}
+	return id, nil
+}
+
+func (m *memoryStore) ListCredentials(_ context.Context, userID string) ([]domain.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var credentials []domain.Credential
+	for _, credential := range m.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, *credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (m *memoryStore) FindCredentialByCredentialID(_ context.Context, credentialID []byte) (*domain.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, credential := range m.credentials {
+		if bytes.Equal(credential.CredentialID, credentialID) {
+			copied := *credential
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *memoryStore) UpdateCredentialUsage(_ context.Context, id string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	credential, ok := m.credentials[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	credential.Data = data
+	credential.LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return nil
+}
+
+func (m *memoryStore) DeleteCredential(_ context.Context, userID, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	credential, ok := m.credentials[id]
+	if !ok || credential.UserID != userID {
+		return false, nil
+	}
+	delete(m.credentials, id)
+	return true, nil
+}
+
+func (m *memoryStore) CreateWebAuthnSession(_ context.Context, userID, purpose string, data []byte, expiresAt time.Time) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := uuid.NewString()
+	m.webAuthnSessions[id] = &domain.WebAuthnSession{
+		ID:        id,
+		UserID:    sql.NullString{String: userID, Valid: userID != ""},
+		Purpose:   purpose,
+		Data:      data,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	return id, nil
+}
+
+func (m *memoryStore) ConsumeWebAuthnSession(_ context.Context, id, purpose string) (*domain.WebAuthnSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.webAuthnSessions[id]
+	if !ok || session.Purpose != purpose || !session.ExpiresAt.After(time.Now()) {
+		return nil, sql.ErrNoRows
+	}
+	delete(m.webAuthnSessions, id)
+	return session, nil
+}
+
+func (m *memoryStore) RecordLoginAttempt(_ context.Context, scope, subject string, window time.Duration, delay func(failures int) time.Duration) (*domain.LoginThrottle, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	throttle, ok := m.loginThrottles[scope+":"+subject]
+	if !ok {
+		throttle = &domain.LoginThrottle{Scope: scope, Subject: subject}
+		m.loginThrottles[scope+":"+subject] = throttle
+	}
+	if throttle.LockedUntil.Valid && throttle.LockedUntil.Time.After(now) {
+		copied := *throttle
+		return &copied, false, nil
+	}
+
+	if throttle.LastFailureAt.Before(now.Add(-window)) {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	if d := delay(throttle.Failures); d > 0 {
+		throttle.LockedUntil = sql.NullTime{Time: now.Add(d), Valid: true}
+	}
+
+	copied := *throttle
+	return &copied, true, nil
+}
+
+func (m *memoryStore) ForgiveLoginAttempt(_ context.Context, scope, subject string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if throttle, ok := m.loginThrottles[scope+":"+subject]; ok {
+		throttle.Failures = max(throttle.Failures-1, 0)
+	}
+	return nil
+}
+
+func (m *memoryStore) ClearLoginThrottle(_ context.Context, scope, subject string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.loginThrottles[scope+":"+subject]
+	delete(m.loginThrottles, scope+":"+subject)
+	return ok, nil
+}
+
+func (m *memoryStore) RecordAuditEvent(_ context.Context, event domain.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.auditEvents = append(m.auditEvents, event)
+	return nil
+}
+
+func (m *memoryStore) CreateRefreshToken(_ context.Context, userID, familyID, tokenHash, amr, clientID, scope string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := uuid.NewString()
+	m.refreshTokens[id] = &domain.RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		AMR:       amr,
+		ClientID:  sql.NullString{String: clientID, Valid: clientID != ""},
+		Scope:     scope,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (m *memoryStore) FindRefreshTokenByHash(_ context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.refreshTokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *memoryStore) MarkRefreshTokenUsed(_ context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.refreshTokens[id]
+	if !ok || token.UsedAt.Valid || token.RevokedAt.Valid {
+		return false, nil
+	}
+	token.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return true, nil
+}
+
+func (m *memoryStore) revokeRefreshTokensLocked(match func(token *domain.RefreshToken) bool) {
+	for _, token := range m.refreshTokens {
+		if !token.RevokedAt.Valid && match(token) {
+			token.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+}
+
+func (m *memoryStore) RevokeRefreshTokenFamily(_ context.Context, familyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revokeRefreshTokensLocked(func(token *domain.RefreshToken) bool { return token.FamilyID == familyID })
+	return nil
+}
+
+func (m *memoryStore) RevokeUserRefreshTokens(_ context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revokeRefreshTokensLocked(func(token *domain.RefreshToken) bool { return token.UserID == userID })
+	return nil
+}
+
+func (m *memoryStore) RecordSession(_ context.Context, session domain.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := m.sessions[session.ID]; ok {
+		session.CreatedAt = existing.CreatedAt
+	} else {
+		session.CreatedAt = now
+	}
+	session.LastSeenAt = now
+	m.sessions[session.ID] = &session
+	return nil
+}
+
+func (m *memoryStore) ListActiveSessions(_ context.Context, userID string) ([]domain.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []domain.Session
+	for _, session := range m.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *memoryStore) RevokeSession(_ context.Context, userID, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID {
+		return false, nil
+	}
+	delete(m.sessions, id)
+	m.revokeRefreshTokensLocked(func(token *domain.RefreshToken) bool { return token.FamilyID == id })
+	return true, nil
+}
+
+func (m *memoryStore) FindSession(_ context.Context, userID, id string) (*domain.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID {
+		return nil, sql.ErrNoRows
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (m *memoryStore) CreateOAuthClient(_ context.Context, client domain.OAuthClient) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	client.ID = uuid.NewString()
+	client.CreatedAt = time.Now()
+	m.oauthClients[client.ID] = &client
+	return client.ID, nil
+}
+
+func (m *memoryStore) FindOAuthClient(_ context.Context, id string) (*domain.OAuthClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	client, ok := m.oauthClients[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *client
+	return &copied, nil
+}
+
+func (m *memoryStore) ListOAuthClients(_ context.Context) ([]domain.OAuthClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var clients []domain.OAuthClient
+	for _, client := range m.oauthClients {
+		clients = append(clients, *client)
+	}
+	return clients, nil
+}
+
+func (m *memoryStore) DeleteOAuthClient(_ context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.oauthClients[id]
+	delete(m.oauthClients, id)
+	return ok, nil
+}
+
+func (m *memoryStore) CreateAuthorizationCode(_ context.Context, code domain.AuthorizationCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code.CreatedAt = time.Now()
+	m.codes[code.CodeHash] = &code
+	return nil
+}
+
+func (m *memoryStore) FindAuthorizationCode(_ context.Context, codeHash string) (*domain.AuthorizationCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code, ok := m.codes[codeHash]
+	if !ok || code.IsExpired(time.Now()) {
+		return nil, sql.ErrNoRows
+	}
+	copied := *code
+	return &copied, nil
+}
+
+func (m *memoryStore) ConsumeAuthorizationCode(_ context.Context, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code, ok := m.codes[codeHash]
+	if !ok || code.UsedAt.Valid {
+		return false, nil
+	}
+	code.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return true, nil
+}
+
+func (m *memoryStore) ListRoles(_ context.Context) ([]domain.Role, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) FindRole(_ context.Context, _ string) (*domain.Role, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (m *memoryStore) ListUserRoles(_ context.Context, userID string) ([]domain.Role, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.userRoles[userID], nil
+}
+
+// AssignUserRole grants a role with no permissions; tests that need
+// permissions set userRoles directly.
+func (m *memoryStore) AssignUserRole(_ context.Context, userID, role string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, held := range m.userRoles[userID] {
+		if held.Name == role {
+			return false, nil
+		}
+	}
+	m.userRoles[userID] = append(m.userRoles[userID], domain.Role{Name: role})
+	return true, nil
+}
+
+func (m *memoryStore) RemoveUserRole(_ context.Context, userID, role string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	roles := m.userRoles[userID]
+	for i, held := range roles {
+		if held.Name == role {
+			m.userRoles[userID] = append(roles[:i], roles[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryStore) CreateTrustedDevice(_ context.Context, device domain.TrustedDevice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	device.CreatedAt = time.Now()
+	m.trustedDevices[device.ID] = &device
+	return nil
+}
+
+func (m *memoryStore) FindTrustedDevice(_ context.Context, userID, tokenHash string) (*domain.TrustedDevice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, device := range m.trustedDevices {
+		if device.UserID == userID && device.TokenHash == tokenHash && time.Now().Before(device.ExpiresAt) {
+			found := *device
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *memoryStore) TouchTrustedDevice(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if device, ok := m.trustedDevices[id]; ok {
+		device.LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+func (m *memoryStore) ListTrustedDevices(_ context.Context, userID string) ([]domain.TrustedDevice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var devices []domain.TrustedDevice
+	for _, device := range m.trustedDevices {
+		if device.UserID == userID {
+			devices = append(devices, *device)
+		}
+	}
+	return devices, nil
+}
+
+func (m *memoryStore) DeleteTrustedDevice(_ context.Context, userID, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	device, ok := m.trustedDevices[id]
+	if !ok || device.UserID != userID {
+		return false, nil
+	}
+	delete(m.trustedDevices, id)
+	return true, nil
+}
+
+func (m *memoryStore) DeleteUserTrustedDevices(_ context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, device := range m.trustedDevices {
+		if device.UserID == userID {
+			delete(m.trustedDevices, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/mailer"
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// mailSendTimeout bounds how long a request waits for the mail relay.
+const mailSendTimeout = 10 * time.Second
+
+// sendMail delivers msg with mailSendTimeout applied.
+func sendMail(ctx context.Context, m mailer.Mailer, msg mailer.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, mailSendTimeout)
+	defer cancel()
+
+	return m.Send(ctx, msg)
+}
+
+// sendPasswordChangedNotice tells the user their password changed, so an
+// unexpected change is noticed even though all sessions were signed out.
+func sendPasswordChangedNotice(ctx context.Context, m mailer.Mailer, user *domain.User) error {
+	return sendMail(ctx, m, mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password of your account was changed on %s and every device was signed out.\n\nIf you did not do this, reset your password immediately and contact support.\n",
+			user.Name, time.Now().UTC().Format("2 January 2006 at 15:04 UTC"),
+		),
+	})
+}
+
+// linkWithToken appends token as a query parameter to a frontend URL.
+func linkWithToken(base, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
+
+// describeDuration renders a TTL for humans, e.g. "24 hours" or "30 minutes".
+func describeDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		if d == time.Hour {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	if d <= time.Minute {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", d/time.Minute)
+}
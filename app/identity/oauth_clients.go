@@ -0,0 +1,248 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"auction/pkg/securetoken"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	scopeOpenID  = "openid"
+	scopeProfile = "profile"
+	scopeEmail   = "email"
+)
+
+// scopeIntrospect lets a service client call /introspect. It is granted
+// with identityctl create-client -service -scopes introspect.
+const scopeIntrospect = "introspect"
+
+// SupportedScopes lists the scopes a client can be registered for and
+// request. Clients are registered for all of them by default.
+var SupportedScopes = []string{scopeOpenID, scopeProfile, scopeEmail}
+
+// scopeTokenPattern matches a scope token as defined by RFC 6749 section 3.3.
+var scopeTokenPattern = regexp.MustCompile(`^[\x21\x23-\x5B\x5D-\x7E]+$`)
+
+var errInvalidClient = errors.New("identity: unknown client or wrong client secret")
+
+// OAuthClients manages the applications that sign users in through the
+// OpenID Connect endpoints.
+type OAuthClients struct {
+	repository OAuthRepository
+}
+
+func NewOAuthClients(repository OAuthRepository) *OAuthClients {
+	return &OAuthClients{
+		repository: repository,
+	}
+}
+
+// Register stores a new client and returns it together with its secret,
+// which is only stored hashed and cannot be shown again. Public clients get
+// no secret. Redirect URIs must be absolute and use https, except for
+// loopback addresses and the private schemes of native apps.
+func (c *OAuthClients) Register(ctx context.Context, name string, redirectURIs, scopes []string, public bool) (*domain.OAuthClient, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("client name is required")
+	}
+
+	if len(redirectURIs) == 0 {
+		return nil, "", errors.New("at least one redirect URI is required")
+	}
+	for _, redirectURI := range redirectURIs {
+		if err := checkRedirectURI(redirectURI); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if len(scopes) == 0 {
+		scopes = SupportedScopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(SupportedScopes, scope) {
+			return nil, "", fmt.Errorf("unsupported scope %q", scope)
+		}
+	}
+	if !slices.Contains(scopes, scopeOpenID) {
+		return nil, "", fmt.Errorf("scopes must include %q", scopeOpenID)
+	}
+
+	return c.create(ctx, domain.OAuthClient{
+		Name:         name,
+		RedirectURIs: strings.Join(redirectURIs, " "),
+		Scopes:       strings.Join(scopes, " "),
+		GrantTypes:   grantTypeAuthorizationCode + " " + grantTypeRefreshToken,
+	}, public)
+}
+
+// RegisterService stores a confidential client for a service that calls
+// other services on its own behalf with the client_credentials grant. Its
+// scopes are free-form, e.g. "bids:read payments:write", but cannot be the
+// OpenID Connect scopes, which describe users.
+func (c *OAuthClients) RegisterService(ctx context.Context, name string, scopes []string) (*domain.OAuthClient, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("client name is required")
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !scopeTokenPattern.MatchString(scope) {
+			return nil, "", fmt.Errorf("invalid scope %q", scope)
+		}
+		if slices.Contains(SupportedScopes, scope) {
+			return nil, "", fmt.Errorf("scope %q is reserved for user sign-in", scope)
+		}
+	}
+
+	return c.create(ctx, domain.OAuthClient{
+		Name:       name,
+		Scopes:     strings.Join(scopes, " "),
+		GrantTypes: grantTypeClientCredentials,
+	}, false)
+}
+
+func (c *OAuthClients) create(ctx context.Context, client domain.OAuthClient, public bool) (*domain.OAuthClient, string, error) {
+	var secret string
+	if !public {
+		var err error
+		if secret, err = securetoken.Generate(); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = sql.NullString{String: securetoken.Hash(secret), Valid: true}
+	}
+
+	id, err := c.repository.CreateOAuthClient(ctx, client)
+	if err != nil {
+		return nil, "", err
+	}
+	client.ID = id
+
+	return &client, secret, nil
+}
+
+// Find returns the client with the given id, or sql.ErrNoRows.
+func (c *OAuthClients) Find(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, sql.ErrNoRows
+	}
+	return c.repository.FindOAuthClient(ctx, id)
+}
+
+// Authenticate checks the credentials a client presents to the token
+// endpoint. Public clients must not send a secret; confidential clients must
+// send theirs.
+func (c *OAuthClients) Authenticate(ctx context.Context, id, secret string) (*domain.OAuthClient, error) {
+	client, err := c.Find(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if client.IsPublic() {
+		if secret != "" {
+			return nil, errInvalidClient
+		}
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(securetoken.Hash(secret)), []byte(client.SecretHash.String)) != 1 {
+		return nil, errInvalidClient
+	}
+	return client, nil
+}
+
+// authenticateRequest authenticates the client of a token or introspection
+// request. It accepts exactly one of the HTTP Basic authorization header and
+// the client_id and client_secret body parameters (RFC 6749 section 2.3.1).
+func (c *OAuthClients) authenticateRequest(ctx context.Context, authorization, clientID, secret string) (*domain.OAuthClient, error) {
+	if scheme, credentials, ok := strings.Cut(strings.TrimSpace(authorization), " "); ok && strings.EqualFold(scheme, "Basic") {
+		if secret != "" {
+			return nil, httperror.OAuth("invalid_request", "Use only one client authentication method")
+		}
+
+		id, password, ok := parseBasicCredentials(credentials)
+		if !ok || (clientID != "" && clientID != id) {
+			return nil, httperror.OAuth("invalid_client", "Client authentication failed")
+		}
+		clientID, secret = id, password
+	}
+
+	if clientID == "" {
+		return nil, httperror.OAuth("invalid_client", "Client authentication failed")
+	}
+
+	client, err := c.Authenticate(ctx, clientID, secret)
+	if errors.Is(err, errInvalidClient) {
+		return nil, httperror.OAuth("invalid_client", "Client authentication failed")
+	}
+	if err != nil {
+		return nil, serverError()
+	}
+	return client, nil
+}
+
+// parseBasicCredentials decodes HTTP Basic credentials whose parts are
+// form-encoded, as RFC 6749 requires for client credentials.
+func parseBasicCredentials(encoded string) (string, string, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+
+	rawID, rawSecret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+
+	id, err := url.QueryUnescape(rawID)
+	if err != nil {
+		return "", "", false
+	}
+	secret, err := url.QueryUnescape(rawSecret)
+	if err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+func checkRedirectURI(redirectURI string) error {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || !parsed.IsAbs() || strings.ContainsAny(redirectURI, " \t\n") {
+		return fmt.Errorf("redirect URI %q must be an absolute URI", redirectURI)
+	}
+	if parsed.Fragment != "" {
+		return fmt.Errorf("redirect URI %q must not contain a fragment", redirectURI)
+	}
+
+	if parsed.Scheme == "http" && !isLoopback(parsed.Hostname()) {
+		return fmt.Errorf("redirect URI %q must use https", redirectURI)
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,257 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/revocation"
+	"auction/pkg/securetoken"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeRefreshToken      = "refresh_token"
+	grantTypeClientCredentials = "client_credentials"
+)
+
+type OAuthTokenHandler struct {
+	repository        Repository
+	clients           *OAuthClients
+	oauthRepository   OAuthRepository
+	sessionRepository SessionRepository
+	tokenIssuer       *TokenIssuer
+	revocations       revocation.Store
+}
+
+// OAuthTokenRequest is a form-encoded token request (RFC 6749 section 4.1.3
+// and 6). Clients authenticate with HTTP Basic or client_id/client_secret
+// in the body; public clients only send client_id.
+type OAuthTokenRequest struct {
+	GrantType     string `json:"grant_type" form:"grant_type"`
+	Code          string `json:"code" form:"code"`
+	RedirectURI   string `json:"redirect_uri" form:"redirect_uri"`
+	CodeVerifier  string `json:"code_verifier" form:"code_verifier"`
+	RefreshToken  string `json:"refresh_token" form:"refresh_token"`
+	Scope         string `json:"scope" form:"scope"`
+	ClientID      string `json:"client_id" form:"client_id"`
+	ClientSecret  string `json:"client_secret" form:"client_secret"`
+	Authorization string `reqHeader:"Authorization"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+func NewOAuthTokenHandler(repository Repository, clients *OAuthClients, oauthRepository OAuthRepository, sessionRepository SessionRepository, tokenIssuer *TokenIssuer, revocations revocation.Store) *OAuthTokenHandler {
+	return &OAuthTokenHandler{
+		repository:        repository,
+		clients:           clients,
+		oauthRepository:   oauthRepository,
+		sessionRepository: sessionRepository,
+		tokenIssuer:       tokenIssuer,
+		revocations:       revocations,
+	}
+}
+
+func (h *OAuthTokenHandler) Handle(ctx context.Context, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	client, err := h.clients.authenticateRequest(ctx, req.Authorization, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case grantTypeAuthorizationCode, grantTypeRefreshToken, grantTypeClientCredentials:
+		if !client.AllowsGrantType(req.GrantType) {
+			return nil, httperror.OAuth("unauthorized_client", "Client may not use grant type "+req.GrantType)
+		}
+	case "":
+		return nil, httperror.OAuth("invalid_request", "grant_type is required")
+	default:
+		return nil, httperror.OAuth("unsupported_grant_type", "Grant type "+req.GrantType+" is not supported")
+	}
+
+	switch req.GrantType {
+	case grantTypeAuthorizationCode:
+		return h.exchangeCode(ctx, client, req)
+	case grantTypeRefreshToken:
+		return h.refresh(ctx, client, req)
+	default:
+		return h.clientCredentials(client, req)
+	}
+}
+
+// exchangeCode redeems an authorization code for the tokens of the session it
+// started. A code that is presented twice revokes that session, as one of
+// the two requests did not come from the client.
+func (h *OAuthTokenHandler) exchangeCode(ctx context.Context, client *domain.OAuthClient, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	codeHash := securetoken.Hash(req.Code)
+
+	code, err := h.oauthRepository.FindAuthorizationCode(ctx, codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, invalidGrant()
+	}
+	if err != nil {
+		return nil, serverError()
+	}
+
+	if code.UsedAt.Valid {
+		h.revokeReplayedCode(ctx, code)
+		return nil, invalidGrant()
+	}
+
+	if code.ClientID != client.ID || code.RedirectURI != req.RedirectURI || code.IsExpired(time.Now()) {
+		return nil, invalidGrant()
+	}
+	if !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier) {
+		return nil, httperror.OAuth("invalid_grant", "code_verifier does not match the code_challenge")
+	}
+
+	consumed, err := h.oauthRepository.ConsumeAuthorizationCode(ctx, codeHash)
+	if err != nil {
+		return nil, serverError()
+	}
+	if !consumed {
+		h.revokeReplayedCode(ctx, code)
+		return nil, invalidGrant()
+	}
+
+	user, err := h.repository.FindByID(ctx, code.UserID)
+	if err != nil {
+		return nil, invalidGrant()
+	}
+
+	pair, err := h.tokenIssuer.issue(ctx, user, code.SessionID, code.Methods(), ClientGrant{ClientID: client.ID, Scope: code.Scope})
+	if errors.Is(err, errUserDisabled) {
+		return nil, invalidGrant()
+	}
+	if err != nil {
+		return nil, serverError()
+	}
+
+	idToken, err := jwt.CreateIDToken(user, jwt.IDTokenOptions{
+		ClientID:  client.ID,
+		SessionID: code.SessionID,
+		Nonce:     code.Nonce,
+		Scope:     code.Scope,
+		AuthTime:  code.AuthTime,
+		AMR:       code.Methods(),
+	})
+	if err != nil {
+		return nil, serverError()
+	}
+
+	return &OAuthTokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    pair.ExpiresIn,
+		RefreshToken: pair.RefreshToken,
+		IDToken:      idToken,
+		Scope:        code.Scope,
+	}, nil
+}
+
+// refresh rotates a refresh token issued to the client. The response carries
+// no new ID token, which OpenID Connect leaves optional.
+func (h *OAuthTokenHandler) refresh(ctx context.Context, client *domain.OAuthClient, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	stored, err := h.tokenIssuer.redeem(ctx, req.RefreshToken, client.ID)
+	if errors.Is(err, errInvalidRefreshToken) || errors.Is(err, errRefreshTokenReused) {
+		return nil, invalidGrant()
+	}
+	if err != nil {
+		return nil, serverError()
+	}
+
+	user, err := h.repository.FindByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, invalidGrant()
+	}
+
+	pair, err := h.tokenIssuer.issue(ctx, user, stored.FamilyID, stored.Methods(), ClientGrant{ClientID: client.ID, Scope: stored.Scope})
+	if errors.Is(err, errUserDisabled) {
+		return nil, invalidGrant()
+	}
+	if err != nil {
+		return nil, serverError()
+	}
+
+	return &OAuthTokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    pair.ExpiresIn,
+		RefreshToken: pair.RefreshToken,
+		Scope:        stored.Scope,
+	}, nil
+}
+
+// clientCredentials issues a machine token to a service client for the
+// requested scope, or every scope it is registered for when none is given.
+// No refresh token is issued; the client authenticates again instead.
+func (h *OAuthTokenHandler) clientCredentials(client *domain.OAuthClient, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	scope := client.Scopes
+	if requested := strings.Fields(req.Scope); len(requested) > 0 {
+		for _, s := range requested {
+			if !client.AllowsScope(s) {
+				return nil, httperror.OAuth("invalid_scope", "Scope "+s+" is not allowed for this client")
+			}
+		}
+		scope = strings.Join(requested, " ")
+	}
+
+	token, _, err := jwt.CreateMachineToken(client.ID, scope)
+	if err != nil {
+		return nil, serverError()
+	}
+
+	return &OAuthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(jwt.AccessTokenTTL().Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+func (h *OAuthTokenHandler) revokeReplayedCode(ctx context.Context, code *domain.AuthorizationCode) {
+	zap.L().Warn("Authorization code reuse detected", zap.String("user_id", code.UserID), zap.String("client_id", code.ClientID))
+
+	if _, err := h.sessionRepository.RevokeSession(ctx, code.UserID, code.SessionID); err != nil {
+		zap.L().Error("Failed to revoke session", zap.String("session_id", code.SessionID), zap.Error(err))
+	}
+	if err := h.revocations.RevokeToken(ctx, code.SessionID, time.Now().Add(jwt.AccessTokenTTL())); err != nil {
+		zap.L().Error("Failed to revoke session tokens", zap.String("session_id", code.SessionID), zap.Error(err))
+	}
+}
+
+// verifyCodeChallenge checks a PKCE code_verifier against the S256
+// code_challenge of the authorization request.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if !codeChallengePattern.MatchString(verifier) {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func invalidGrant() error {
+	return httperror.OAuth("invalid_grant", "Grant is invalid, expired or was issued to another client")
+}
+
+func serverError() error {
+	return httperror.OAuth("server_error", "Internal server error")
+}
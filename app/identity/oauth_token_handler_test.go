@@ -0,0 +1,344 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/revocation"
+	"auction/pkg/securetoken"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const testRedirectURI = "https://bidding.example.com/callback"
+
+func useTestKeys(t *testing.T) {
+	t.Helper()
+
+	key, err := jwt.GenerateEd25519Key("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := jwt.NewKeyStore("test", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := jwt.Keys()
+	jwt.UseKeyStore(store)
+	t.Cleanup(func() { jwt.UseKeyStore(previous) })
+}
+
+type oauthFixture struct {
+	store       *memoryStore
+	clients     *OAuthClients
+	issuer      *TokenIssuer
+	revocations *revocation.MemoryStore
+	handler     *OAuthTokenHandler
+	client      *domain.OAuthClient
+	secret      string
+	userID      string
+}
+
+func newOAuthFixture(t *testing.T) *oauthFixture {
+	t.Helper()
+	useTestKeys(t)
+
+	f := &oauthFixture{store: newMemoryStore(), revocations: revocation.NewMemoryStore()}
+	f.clients = NewOAuthClients(f.store)
+	f.issuer = NewTokenIssuer(f.store, f.store, f.store, f.revocations, time.Hour)
+	f.handler = NewOAuthTokenHandler(f.store, f.clients, f.store, f.store, f.issuer, f.revocations)
+	f.client, f.secret = f.registerClient(t)
+	f.userID = f.store.addUser(domain.User{
+		Email:           "bidder@example.com",
+		Name:            "Bidder",
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	return f
+}
+
+func (f *oauthFixture) registerClient(t *testing.T) (*domain.OAuthClient, string) {
+	t.Helper()
+
+	client, secret, err := f.clients.Register(context.Background(), "Bidding", []string{testRedirectURI}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, secret
+}
+
+// authorize stores a code as /authorize/approve would and returns it with
+// the PKCE verifier of its challenge.
+func (f *oauthFixture) authorize(t *testing.T, scope string) (string, string, *domain.AuthorizationCode) {
+	t.Helper()
+
+	code, err := securetoken.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := securetoken.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(verifier))
+
+	authorization := domain.AuthorizationCode{
+		CodeHash:      securetoken.Hash(code),
+		ClientID:      f.client.ID,
+		UserID:        f.userID,
+		SessionID:     uuid.NewString(),
+		RedirectURI:   testRedirectURI,
+		Scope:         scope,
+		Nonce:         "n-0S6_WzA2Mj",
+		CodeChallenge: base64.RawURLEncoding.EncodeToString(digest[:]),
+		AMR:           jwt.AMRPassword,
+		AuthTime:      time.Now(),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	if err := f.store.CreateAuthorizationCode(context.Background(), authorization); err != nil {
+		t.Fatal(err)
+	}
+	return code, verifier, &authorization
+}
+
+func (f *oauthFixture) exchangeRequest(code, verifier string) *OAuthTokenRequest {
+	return &OAuthTokenRequest{
+		GrantType:    grantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: verifier,
+		ClientID:     f.client.ID,
+		ClientSecret: f.secret,
+	}
+}
+
+func assertOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var oauthErr *httperror.OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+		t.Fatalf("got %v, want %s", err, code)
+	}
+}
+
+func assertHTTPError(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var httpErr *httperror.Error
+	if !errors.As(err, &httpErr) || httpErr.Code != code {
+		t.Fatalf("got %v, want %s", err, code)
+	}
+}
+
+func TestOAuthExchangeCodeRejects(t *testing.T) {
+	f := newOAuthFixture(t)
+	other, otherSecret := f.registerClient(t)
+
+	otherVerifier, err := securetoken.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(req *OAuthTokenRequest, challenge string)
+	}{
+		{"PKCE verifier of another challenge", func(req *OAuthTokenRequest, _ string) { req.CodeVerifier = otherVerifier }},
+		{"PKCE challenge sent as plain verifier", func(req *OAuthTokenRequest, challenge string) { req.CodeVerifier = challenge }},
+		{"PKCE verifier missing", func(req *OAuthTokenRequest, _ string) { req.CodeVerifier = "" }},
+		{"redirect_uri with trailing slash", func(req *OAuthTokenRequest, _ string) { req.RedirectURI = testRedirectURI + "/" }},
+		{"redirect_uri with extra query", func(req *OAuthTokenRequest, _ string) { req.RedirectURI = testRedirectURI + "?next=1" }},
+		{"redirect_uri with other case", func(req *OAuthTokenRequest, _ string) { req.RedirectURI = "https://Bidding.example.com/callback" }},
+		{"redirect_uri missing", func(req *OAuthTokenRequest, _ string) { req.RedirectURI = "" }},
+		{"code of another client", func(req *OAuthTokenRequest, _ string) { req.ClientID, req.ClientSecret = other.ID, otherSecret }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, verifier, authorization := f.authorize(t, "openid")
+			req := f.exchangeRequest(code, verifier)
+			tt.modify(req, authorization.CodeChallenge)
+
+			_, err := f.handler.Handle(context.Background(), req)
+			assertOAuthError(t, err, "invalid_grant")
+
+			// A rejected request does not use up the code.
+			if _, err := f.handler.Handle(context.Background(), f.exchangeRequest(code, verifier)); err != nil {
+				t.Fatalf("code unusable after a rejected request: %v", err)
+			}
+		})
+	}
+}
+
+func TestOAuthExchangeCodeReplayRevokesSession(t *testing.T) {
+	f := newOAuthFixture(t)
+	ctx := context.Background()
+
+	code, verifier, authorization := f.authorize(t, "openid profile")
+	res, err := f.handler.Handle(ctx, f.exchangeRequest(code, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := jwt.DecodeAccessToken(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.SessionID != authorization.SessionID || claims.ClientID != f.client.ID {
+		t.Fatalf("access token not bound to the code's session and client: %+v", claims)
+	}
+
+	_, err = f.handler.Handle(ctx, f.exchangeRequest(code, verifier))
+	assertOAuthError(t, err, "invalid_grant")
+
+	if revoked, _ := f.revocations.IsTokenRevoked(ctx, authorization.SessionID); !revoked {
+		t.Fatal("access tokens of the session were not revoked")
+	}
+	if _, err := f.store.FindSession(ctx, f.userID, authorization.SessionID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("session survived the replay: %v", err)
+	}
+
+	_, err = f.handler.Handle(ctx, &OAuthTokenRequest{
+		GrantType:    grantTypeRefreshToken,
+		RefreshToken: res.RefreshToken,
+		ClientID:     f.client.ID,
+		ClientSecret: f.secret,
+	})
+	assertOAuthError(t, err, "invalid_grant")
+}
+
+func TestTokenIssuerRedeemIsClientBound(t *testing.T) {
+	f := newOAuthFixture(t)
+	other, _ := f.registerClient(t)
+	ctx := context.Background()
+
+	user, err := f.store.FindByID(ctx, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientPair, err := f.issuer.issue(ctx, user, uuid.NewString(), []string{jwt.AMRPassword}, ClientGrant{ClientID: f.client.ID, Scope: "openid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstPartyPair, err := f.issuer.Issue(ctx, user, []string{jwt.AMRPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		clientID string
+	}{
+		{"client token redeemed by another client", clientPair.RefreshToken, other.ID},
+		{"client token redeemed first-party", clientPair.RefreshToken, ""},
+		{"first-party token redeemed by a client", firstPartyPair.RefreshToken, f.client.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.issuer.redeem(ctx, tt.token, tt.clientID); !errors.Is(err, errInvalidRefreshToken) {
+				t.Fatalf("got %v, want errInvalidRefreshToken", err)
+			}
+		})
+	}
+
+	// Rejected attempts leave the tokens usable by their own client.
+	stored, err := f.issuer.redeem(ctx, clientPair.RefreshToken, f.client.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ClientID.String != f.client.ID || stored.Scope != "openid" {
+		t.Fatalf("redeemed token lost its grant: %+v", stored)
+	}
+	if _, err := f.issuer.redeem(ctx, firstPartyPair.RefreshToken, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.issuer.redeem(ctx, clientPair.RefreshToken, f.client.ID); !errors.Is(err, errRefreshTokenReused) {
+		t.Fatalf("got %v, want errRefreshTokenReused", err)
+	}
+}
+
+func TestTokenIssuerRedeemReuseRevokesSession(t *testing.T) {
+	f := newOAuthFixture(t)
+	ctx := context.Background()
+
+	user, err := f.store.FindByID(ctx, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := f.issuer.Issue(ctx, user, []string{jwt.AMRPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := jwt.DecodeAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := f.issuer.redeem(ctx, pair.RefreshToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := f.issuer.issue(ctx, user, stored.FamilyID, []string{jwt.AMRPassword}, ClientGrant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := revocation.Check(ctx, f.revocations, claims.ID, claims.SessionID, user.ID, claims.IssuedAt.Time); err != nil {
+		t.Fatalf("session revoked before any reuse: %v", err)
+	}
+
+	if _, err := f.issuer.redeem(ctx, pair.RefreshToken, ""); !errors.Is(err, errRefreshTokenReused) {
+		t.Fatalf("got %v, want errRefreshTokenReused", err)
+	}
+
+	if err := revocation.Check(ctx, f.revocations, claims.ID, claims.SessionID, user.ID, claims.IssuedAt.Time); !errors.Is(err, revocation.ErrRevoked) {
+		t.Fatalf("access token of the replayed family: got %v, want ErrRevoked", err)
+	}
+	if _, err := f.issuer.redeem(ctx, rotated.RefreshToken, ""); !errors.Is(err, errInvalidRefreshToken) {
+		t.Fatalf("rotated refresh token: got %v, want errInvalidRefreshToken", err)
+	}
+}
+
+func TestTokenIssuerLeavesRolesOutOfClientTokens(t *testing.T) {
+	f := newOAuthFixture(t)
+	ctx := context.Background()
+	f.store.userRoles[f.userID] = []domain.Role{{Name: "admin", Permissions: "users:manage"}}
+
+	user, err := f.store.FindByID(ctx, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstPartyPair, err := f.issuer.Issue(ctx, user, []string{jwt.AMRPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := jwt.DecodeAccessToken(firstPartyPair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims.Roles) != 1 || len(claims.Permissions) != 1 {
+		t.Fatalf("first-party token: got roles %v permissions %v", claims.Roles, claims.Permissions)
+	}
+
+	clientPair, err := f.issuer.issue(ctx, user, uuid.NewString(), []string{jwt.AMRPassword}, ClientGrant{ClientID: f.client.ID, Scope: "openid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = jwt.DecodeAccessToken(clientPair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims.Roles) != 0 || len(claims.Permissions) != 0 {
+		t.Fatalf("client token: got roles %v permissions %v", claims.Roles, claims.Permissions)
+	}
+}
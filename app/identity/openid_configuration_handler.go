@@ -0,0 +1,75 @@
+package identity
+
+import (
+	"auction/pkg/jwt"
+	"context"
+	"slices"
+)
+
+type OpenIDConfigurationHandler struct {
+	keyStore *jwt.KeyStore
+}
+
+type OpenIDConfigurationRequest struct {
+}
+
+// OpenIDConfigurationResponse is the OpenID Connect discovery document.
+type OpenIDConfigurationResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	IntrospectionAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	AuthorizationResponseIssParameter bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+func NewOpenIDConfigurationHandler(keyStore *jwt.KeyStore) *OpenIDConfigurationHandler {
+	return &OpenIDConfigurationHandler{
+		keyStore: keyStore,
+	}
+}
+
+func (h OpenIDConfigurationHandler) Handle(_ context.Context, _ *OpenIDConfigurationRequest) (*OpenIDConfigurationResponse, error) {
+	issuer := jwt.Issuer()
+
+	var algorithms []string
+	for _, key := range h.keyStore.JWKS().Keys {
+		if !slices.Contains(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+
+	return &OpenIDConfigurationResponse{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		IntrospectionEndpoint:             issuer + "/introspect",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   SupportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{grantTypeAuthorizationCode, grantTypeRefreshToken, grantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		IntrospectionAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeMethodS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr", "azp", "sid",
+			"name", "email", "email_verified",
+		},
+		AuthorizationResponseIssParameter: true,
+	}, nil
+}
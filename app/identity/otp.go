@@ -0,0 +1,94 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/totp"
+	"context"
+)
+
+// OTPSettings holds the service-wide OTP configuration. Defaults apply to new
+// enrollments; existing users keep the type, algorithm, digits and period
+// stored with their secret. Defaults.Window is the TOTP drift window.
+type OTPSettings struct {
+	Defaults         totp.Options
+	HOTPLookAhead    int
+	HOTPResyncWindow int
+}
+
+func (s OTPSettings) optionsFor(user *domain.User) totp.Options {
+	config := user.TwoFactorConfig()
+
+	opts := totp.Options{
+		Type:      config.Type,
+		Algorithm: totp.Algorithm(config.Algorithm),
+		Digits:    config.Digits,
+		Period:    config.Period,
+		Window:    s.Defaults.Window,
+	}
+	if opts.Type == totp.TypeHOTP {
+		opts.Window = s.HOTPLookAhead
+	}
+
+	return opts
+}
+
+// OTPVerifier checks codes against a user's decrypted secret and records the
+// accepted counter, so each code is accepted at most once even within its
+// validity window.
+type OTPVerifier struct {
+	repository Repository
+	secrets    *TwoFactorSecrets
+	settings   OTPSettings
+}
+
+func NewOTPVerifier(repository Repository, secrets *TwoFactorSecrets, settings OTPSettings) *OTPVerifier {
+	return &OTPVerifier{
+		repository: repository,
+		secrets:    secrets,
+		settings:   settings,
+	}
+}
+
+func (v *OTPVerifier) Verify(ctx context.Context, user *domain.User, code string) (bool, error) {
+	if !user.TwoFactorSecret.Valid {
+		return false, nil
+	}
+
+	secret, err := v.secrets.Open(ctx, user)
+	if err != nil {
+		return false, err
+	}
+
+	counter, ok := totp.Verify(secret, code, v.settings.optionsFor(user), lastOTPCounter(user))
+	if !ok {
+		return false, nil
+	}
+
+	return v.repository.AcceptTwoFactorCounter(ctx, user.ID, counter)
+}
+
+// Resync re-aligns a drifted HOTP token from two consecutive codes.
+func (v *OTPVerifier) Resync(ctx context.Context, user *domain.User, code, nextCode string) (bool, error) {
+	if !user.TwoFactorSecret.Valid {
+		return false, nil
+	}
+
+	secret, err := v.secrets.Open(ctx, user)
+	if err != nil {
+		return false, err
+	}
+
+	counter, ok := totp.ResyncHOTP(secret, code, nextCode, v.settings.optionsFor(user), lastOTPCounter(user), v.settings.HOTPResyncWindow)
+	if !ok {
+		return false, nil
+	}
+
+	return v.repository.AcceptTwoFactorCounter(ctx, user.ID, counter)
+}
+
+func lastOTPCounter(user *domain.User) int64 {
+	if user.TwoFactorLastCounter.Valid {
+		return user.TwoFactorLastCounter.Int64
+	}
+	return -1
+}
@@ -0,0 +1,404 @@
+package identity
+
+import (
+	"auction/domain"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Second factors advertised in the 202 response of /login.
+const (
+	secondFactorOTP      = "otp"
+	secondFactorWebAuthn = "webauthn"
+)
+
+const (
+	webAuthnPurposeRegistration = "registration"
+	webAuthnPurposeSecondFactor = "second_factor"
+	webAuthnPurposePasswordless = "passwordless"
+)
+
+// PasskeySettings describes the relying party the browser binds credentials
+// to. Origins must list every origin the ceremonies are started from.
+type PasskeySettings struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+	SessionTTL    time.Duration
+}
+
+// Passkeys runs the WebAuthn registration and assertion ceremonies. The
+// challenge of each begin call is stored server side and consumed by the
+// matching finish call, so every challenge can be answered at most once.
+type Passkeys struct {
+	webAuthn    *webauthn.WebAuthn
+	repository  Repository
+	credentials CredentialRepository
+	sessionTTL  time.Duration
+}
+
+func NewPasskeys(repository Repository, credentials CredentialRepository, settings PasskeySettings) (*Passkeys, error) {
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: settings.SessionTTL, TimeoutUVD: settings.SessionTTL}
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:                  settings.RPID,
+		RPDisplayName:         settings.RPDisplayName,
+		RPOrigins:             settings.RPOrigins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Passkeys{
+		webAuthn:    w,
+		repository:  repository,
+		credentials: credentials,
+		sessionTTL:  settings.SessionTTL,
+	}, nil
+}
+
+// HasCredentials reports whether the user registered at least one passkey,
+// which makes WebAuthn available as a second factor.
+func (p *Passkeys) HasCredentials(ctx context.Context, user *domain.User) (bool, error) {
+	credentials, err := p.credentials.ListCredentials(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	return len(credentials) > 0, nil
+}
+
+// BeginRegistration returns the creation options for a new credential and the
+// id of the session that FinishRegistration must be called with.
+func (p *Passkeys) BeginRegistration(ctx context.Context, user *domain.User) (*protocol.CredentialCreation, string, error) {
+	account, err := p.loadUser(ctx, user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(account.credentials))
+	for _, credential := range account.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := p.webAuthn.BeginRegistration(
+		account,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := p.saveSession(ctx, user.ID, webAuthnPurposeRegistration, session)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return creation, sessionID, nil
+}
+
+// FinishRegistration verifies the attestation response and stores the new
+// credential. It returns false when the session is unknown or the response
+// does not verify.
+func (p *Passkeys) FinishRegistration(ctx context.Context, user *domain.User, sessionID, name string, response []byte) (*domain.Credential, bool, error) {
+	session, ok, err := p.consumeSession(ctx, sessionID, webAuthnPurposeRegistration, user.ID)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	account, err := p.loadUser(ctx, user)
+	if err != nil {
+		return nil, false, err
+	}
+
+	credential, err := p.webAuthn.CreateCredential(account, *session, parsed)
+	if err != nil {
+		zap.L().Warn("WebAuthn registration rejected", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, false, nil
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, false, err
+	}
+
+	id, err := p.credentials.CreateCredential(ctx, user.ID, credential.ID, name, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &domain.Credential{
+		ID:           id,
+		UserID:       user.ID,
+		CredentialID: credential.ID,
+		Name:         name,
+		Data:         data,
+		CreatedAt:    time.Now(),
+	}, true, nil
+}
+
+// BeginLogin starts an assertion restricted to the user's own credentials, as
+// used for the second factor after a password.
+func (p *Passkeys) BeginLogin(ctx context.Context, user *domain.User) (*protocol.CredentialAssertion, string, error) {
+	account, err := p.loadUser(ctx, user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	assertion, session, err := p.webAuthn.BeginLogin(account)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := p.saveSession(ctx, user.ID, webAuthnPurposeSecondFactor, session)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return assertion, sessionID, nil
+}
+
+// FinishLogin verifies an assertion started with BeginLogin.
+func (p *Passkeys) FinishLogin(ctx context.Context, user *domain.User, sessionID string, response []byte) (bool, error) {
+	session, ok, err := p.consumeSession(ctx, sessionID, webAuthnPurposeSecondFactor, user.ID)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return false, nil
+	}
+
+	account, err := p.loadUser(ctx, user)
+	if err != nil {
+		return false, err
+	}
+
+	credential, err := p.webAuthn.ValidateLogin(account, *session, parsed)
+	if err != nil {
+		zap.L().Warn("WebAuthn assertion rejected", zap.String("user_id", user.ID), zap.Error(err))
+		return false, nil
+	}
+
+	return p.recordUsage(ctx, account, credential)
+}
+
+// BeginPasswordlessLogin starts a discoverable assertion: the authenticator
+// picks the credential and tells us the user. User verification is required
+// because the passkey is the only factor.
+func (p *Passkeys) BeginPasswordlessLogin(ctx context.Context) (*protocol.CredentialAssertion, string, error) {
+	assertion, session, err := p.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := p.saveSession(ctx, "", webAuthnPurposePasswordless, session)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return assertion, sessionID, nil
+}
+
+// FinishPasswordlessLogin verifies a discoverable assertion and returns the
+// user it belongs to, or nil when it does not verify.
+func (p *Passkeys) FinishPasswordlessLogin(ctx context.Context, sessionID string, response []byte) (*domain.User, error) {
+	session, ok, err := p.consumeSession(ctx, sessionID, webAuthnPurposePasswordless, "")
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil
+	}
+
+	var lookupErr error
+	resolved, credential, err := p.webAuthn.ValidatePasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		account, err := p.findUserByCredential(ctx, rawID, userHandle)
+		if err != nil {
+			if !errors.Is(err, errPasskeyUnknown) {
+				lookupErr = err
+			}
+			return nil, err
+		}
+		return account, nil
+	}, *session, parsed)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	if err != nil {
+		zap.L().Warn("WebAuthn passwordless assertion rejected", zap.Error(err))
+		return nil, nil
+	}
+
+	account := resolved.(*webAuthnUser)
+	ok, err = p.recordUsage(ctx, account, credential)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return account.user, nil
+}
+
+var errPasskeyUnknown = errors.New("identity: unknown passkey")
+
+func (p *Passkeys) findUserByCredential(ctx context.Context, rawID, userHandle []byte) (*webAuthnUser, error) {
+	credential, err := p.credentials.FindCredentialByCredentialID(ctx, rawID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errPasskeyUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal([]byte(credential.UserID), userHandle) {
+		return nil, errPasskeyUnknown
+	}
+
+	user, err := p.repository.FindByID(ctx, credential.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errPasskeyUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.loadUser(ctx, user)
+}
+
+// recordUsage persists the new signature counter. A counter that did not
+// increase means the authenticator may have been cloned, so the assertion is
+// rejected.
+func (p *Passkeys) recordUsage(ctx context.Context, account *webAuthnUser, credential *webauthn.Credential) (bool, error) {
+	if credential.Authenticator.CloneWarning {
+		zap.L().Warn("WebAuthn signature counter did not increase", zap.String("user_id", account.user.ID))
+		return false, nil
+	}
+
+	id, ok := account.rowIDs[string(credential.ID)]
+	if !ok {
+		return false, nil
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return false, err
+	}
+
+	if err := p.credentials.UpdateCredentialUsage(ctx, id, data); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p *Passkeys) saveSession(ctx context.Context, userID, purpose string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	return p.credentials.CreateWebAuthnSession(ctx, userID, purpose, data, time.Now().Add(p.sessionTTL))
+}
+
+// consumeSession loads and deletes a ceremony session. It returns false when
+// the session does not exist, has expired, or belongs to another user.
+func (p *Passkeys) consumeSession(ctx context.Context, id, purpose, userID string) (*webauthn.SessionData, bool, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, false, nil
+	}
+
+	stored, err := p.credentials.ConsumeWebAuthnSession(ctx, id, purpose)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if stored.UserID.String != userID {
+		return nil, false, nil
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(stored.Data, &session); err != nil {
+		return nil, false, err
+	}
+
+	return &session, true, nil
+}
+
+func (p *Passkeys) loadUser(ctx context.Context, user *domain.User) (*webAuthnUser, error) {
+	rows, err := p.credentials.ListCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &webAuthnUser{
+		user:        user,
+		credentials: make([]webauthn.Credential, 0, len(rows)),
+		rowIDs:      make(map[string]string, len(rows)),
+	}
+
+	for _, row := range rows {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(row.Data, &credential); err != nil {
+			return nil, err
+		}
+
+		account.credentials = append(account.credentials, credential)
+		account.rowIDs[string(credential.ID)] = row.ID
+	}
+
+	return account, nil
+}
+
+// webAuthnUser adapts domain.User to webauthn.User. The user handle is the
+// user ID, which carries no personal information as the spec requires.
+type webAuthnUser struct {
+	user        *domain.User
+	credentials []webauthn.Credential
+	rowIDs      map[string]string
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.user.ID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.user.Name != "" {
+		return u.user.Name
+	}
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
@@ -0,0 +1,426 @@
+package identity
+
+import (
+	"auction/domain"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	testRPID   = "auction.test"
+	testOrigin = "https://auction.test"
+)
+
+// softAuthenticator is a software WebAuthn authenticator holding one ES256
+// credential. It answers ceremonies the way a browser would pass them on:
+// none attestation, user presence and verification always asserted.
+type softAuthenticator struct {
+	t            *testing.T
+	origin       string
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{t: t, origin: testOrigin, key: key, credentialID: credentialID}
+}
+
+// authenticatorData flags: user present, user verified, attested credential
+// data included.
+const (
+	flagUserPresent   = 0x01
+	flagUserVerified  = 0x04
+	flagAttestedCreds = 0x40
+)
+
+func (a *softAuthenticator) authenticatorData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func (a *softAuthenticator) clientData(ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	data, err := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    a.origin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+// create answers navigator.credentials.create with a none attestation.
+func (a *softAuthenticator) create(creation *protocol.CredentialCreation) []byte {
+	a.t.Helper()
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	public, err := a.key.PublicKey.ECDH()
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	point := public.Bytes()
+
+	coseKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: point[1:33],
+		YCoord: point[33:],
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, coseKey...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authenticatorData(flagUserPresent|flagUserVerified|flagAttestedCreds, attested),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    encode(a.clientData("webauthn.create", creation.Response.Challenge)),
+		"attestationObject": encode(attestationObject),
+	})
+}
+
+// get answers navigator.credentials.get, increasing the signature counter.
+func (a *softAuthenticator) get(assertion *protocol.CredentialAssertion) []byte {
+	a.t.Helper()
+	a.signCount++
+
+	authData := a.authenticatorData(flagUserPresent|flagUserVerified, nil)
+	clientData := a.clientData("webauthn.get", assertion.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+
+	digest := sha256.Sum256(append(slices.Clone(authData), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) credential(response map[string]any) []byte {
+	data, err := json.Marshal(map[string]any{
+		"id":       encode(a.credentialID),
+		"rawId":    encode(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func newTestPasskeys(t *testing.T) (*Passkeys, *memoryStore) {
+	t.Helper()
+
+	store := newMemoryStore()
+	passkeys, err := NewPasskeys(store, store, PasskeySettings{
+		RPID:          testRPID,
+		RPDisplayName: "Auction",
+		RPOrigins:     []string{testOrigin},
+		SessionTTL:    time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return passkeys, store
+}
+
+// registerPasskey runs a registration ceremony for the user and fails the
+// test unless it succeeds.
+func registerPasskey(t *testing.T, passkeys *Passkeys, user *domain.User, authenticator *softAuthenticator) {
+	t.Helper()
+	ctx := context.Background()
+
+	creation, sessionID, err := passkeys.BeginRegistration(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, ok, err := passkeys.FinishRegistration(ctx, user, sessionID, "Laptop", authenticator.create(creation))
+	if err != nil || !ok {
+		t.Fatalf("registration failed: ok=%v err=%v", ok, err)
+	}
+	if credential.Name != "Laptop" || credential.UserID != user.ID {
+		t.Fatalf("unexpected credential %+v", credential)
+	}
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	ctx := context.Background()
+	passkeys, store := newTestPasskeys(t)
+	user := &domain.User{Email: "bidder@example.com", Name: "Bidder"}
+	user.ID = store.addUser(*user)
+
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, passkeys, user, authenticator)
+
+	hasPasskeys, err := passkeys.HasCredentials(ctx, user)
+	if err != nil || !hasPasskeys {
+		t.Fatalf("HasCredentials = %v, %v", hasPasskeys, err)
+	}
+
+	t.Run("session answered twice", func(t *testing.T) {
+		creation, sessionID, err := passkeys.BeginRegistration(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := newSoftAuthenticator(t).create(creation)
+
+		if _, ok, err := passkeys.FinishRegistration(ctx, user, sessionID, "Phone", response); err != nil || !ok {
+			t.Fatalf("first answer rejected: ok=%v err=%v", ok, err)
+		}
+		if _, ok, _ := passkeys.FinishRegistration(ctx, user, sessionID, "Phone", response); ok {
+			t.Fatal("accepted the same session twice")
+		}
+	})
+
+	t.Run("foreign origin", func(t *testing.T) {
+		creation, sessionID, err := passkeys.BeginRegistration(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		phishing := newSoftAuthenticator(t)
+		phishing.origin = "https://auction.example"
+
+		if _, ok, _ := passkeys.FinishRegistration(ctx, user, sessionID, "Phone", phishing.create(creation)); ok {
+			t.Fatal("accepted a credential created for another origin")
+		}
+	})
+
+	t.Run("session of another user", func(t *testing.T) {
+		other := &domain.User{Email: "other@example.com"}
+		other.ID = store.addUser(*other)
+
+		creation, sessionID, err := passkeys.BeginRegistration(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, _ := passkeys.FinishRegistration(ctx, other, sessionID, "Phone", newSoftAuthenticator(t).create(creation)); ok {
+			t.Fatal("finished another user's registration")
+		}
+	})
+}
+
+func TestPasskeySecondFactorAssertion(t *testing.T) {
+	ctx := context.Background()
+	passkeys, store := newTestPasskeys(t)
+	user := &domain.User{Email: "bidder@example.com"}
+	user.ID = store.addUser(*user)
+
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, passkeys, user, authenticator)
+
+	assert := func() (string, []byte) {
+		t.Helper()
+		assertion, sessionID, err := passkeys.BeginLogin(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sessionID, authenticator.get(assertion)
+	}
+
+	sessionID, response := assert()
+	if ok, err := passkeys.FinishLogin(ctx, user, sessionID, response); err != nil || !ok {
+		t.Fatalf("assertion rejected: ok=%v err=%v", ok, err)
+	}
+
+	if ok, _ := passkeys.FinishLogin(ctx, user, sessionID, response); ok {
+		t.Fatal("accepted an assertion for a consumed session")
+	}
+
+	t.Run("cloned authenticator", func(t *testing.T) {
+		// A clone still at the last counter signs a fresh challenge.
+		assertion, sessionID, err := passkeys.BeginLogin(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator.signCount--
+		clone := authenticator.get(assertion)
+
+		if ok, _ := passkeys.FinishLogin(ctx, user, sessionID, clone); ok {
+			t.Fatal("accepted an assertion whose counter did not increase")
+		}
+	})
+
+	t.Run("unregistered authenticator", func(t *testing.T) {
+		assertion, sessionID, err := passkeys.BeginLogin(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stranger := newSoftAuthenticator(t)
+		stranger.userHandle = []byte(user.ID)
+
+		if ok, _ := passkeys.FinishLogin(ctx, user, sessionID, stranger.get(assertion)); ok {
+			t.Fatal("accepted an assertion from an unregistered credential")
+		}
+	})
+}
+
+func TestPasskeyPasswordlessLogin(t *testing.T) {
+	ctx := context.Background()
+	passkeys, store := newTestPasskeys(t)
+	user := &domain.User{Email: "bidder@example.com"}
+	user.ID = store.addUser(*user)
+
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, passkeys, user, authenticator)
+
+	assertion, sessionID, err := passkeys.BeginPasswordlessLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := passkeys.FinishPasswordlessLogin(ctx, sessionID, authenticator.get(assertion))
+	if err != nil || resolved == nil {
+		t.Fatalf("passwordless login failed: user=%v err=%v", resolved, err)
+	}
+	if resolved.ID != user.ID {
+		t.Fatalf("resolved user %s, want %s", resolved.ID, user.ID)
+	}
+
+	t.Run("user handle of another user", func(t *testing.T) {
+		other := &domain.User{Email: "other@example.com"}
+		other.ID = store.addUser(*other)
+
+		assertion, sessionID, err := passkeys.BeginPasswordlessLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator.userHandle = []byte(other.ID)
+		defer func() { authenticator.userHandle = []byte(user.ID) }()
+
+		if resolved, _ := passkeys.FinishPasswordlessLogin(ctx, sessionID, authenticator.get(assertion)); resolved != nil {
+			t.Fatalf("signed in as %s with another user's credential", resolved.Email)
+		}
+	})
+}
+
+func TestLoginSecondFactors(t *testing.T) {
+	ctx := context.Background()
+	passkeys, store := newTestPasskeys(t)
+	h := &LoginHandler{repository: store, passkeys: passkeys}
+
+	tests := []struct {
+		name       string
+		otp        bool
+		passkey    bool
+		optedIn    bool
+		wantMethod []string
+		wantErr    error
+	}{
+		{"nothing enabled", false, false, false, nil, nil},
+		{"passkey registered only", false, true, false, nil, nil},
+		{"passkey opted in", false, true, true, []string{secondFactorWebAuthn}, nil},
+		{"opted in without passkey", false, false, true, nil, errPasskeyFactorMissing},
+		{"otp and opted in without passkey", true, false, true, nil, errPasskeyFactorMissing},
+		{"otp", true, false, false, []string{secondFactorOTP}, nil},
+		{"otp and passkey", true, true, false, []string{secondFactorOTP, secondFactorWebAuthn}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &domain.User{
+				Email:               tt.name + "@example.com",
+				TwoFactorEnabled:    tt.otp,
+				TwoFactorVerified:   tt.otp,
+				PasskeySecondFactor: tt.optedIn,
+			}
+			user.ID = store.addUser(*user)
+			if tt.passkey {
+				registerPasskey(t, passkeys, user, newSoftAuthenticator(t))
+			}
+
+			methods, err := h.secondFactors(ctx, user)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(methods, tt.wantMethod) {
+				t.Fatalf("got %v, want %v", methods, tt.wantMethod)
+			}
+		})
+	}
+}
+
+func TestDeleteLastPasskeyOfSecondFactor(t *testing.T) {
+	passkeys, store := newTestPasskeys(t)
+	h := NewDeletePasskeyHandler(store, store)
+
+	user := &domain.User{Email: "bidder@example.com", PasskeySecondFactor: true}
+	user.ID = store.addUser(*user)
+	ctx := context.WithValue(context.Background(), "UserID", user.ID)
+
+	registerPasskey(t, passkeys, user, newSoftAuthenticator(t))
+	registerPasskey(t, passkeys, user, newSoftAuthenticator(t))
+	credentials, err := store.ListCredentials(ctx, user.ID)
+	if err != nil || len(credentials) != 2 {
+		t.Fatalf("got %d credentials, %v", len(credentials), err)
+	}
+
+	_, err = h.Handle(ctx, &DeletePasskeyRequest{ID: credentials[0].ID})
+	assertHTTPError(t, err, "identity.delete_passkey.no_content")
+
+	_, err = h.Handle(ctx, &DeletePasskeyRequest{ID: credentials[1].ID})
+	assertHTTPError(t, err, "identity.delete_passkey.last_second_factor")
+
+	if err := store.SetPasskeySecondFactor(ctx, user.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.Handle(ctx, &DeletePasskeyRequest{ID: credentials[1].ID})
+	assertHTTPError(t, err, "identity.delete_passkey.no_content")
+}
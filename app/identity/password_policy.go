@@ -0,0 +1,40 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"auction/pkg/password"
+	"strings"
+)
+
+// checkPasswordPolicy turns policy violations into a 400 whose details list
+// every rule the password breaks, so clients can show them all at once.
+func checkPasswordPolicy(policy *password.Policy, code, plain, email, name string) error {
+	violations := policy.Check(plain, email, name)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return httperror.BadRequest(code, "Password does not meet the password policy", violations)
+}
+
+// verifyPassword checks plain against the stored hash and returns the
+// plaintext that matched. Hashes stored while registration still trimmed
+// surrounding whitespace (password_trimmed) also match the trimmed input, as
+// they always did; nothing is rewritten, so the stored password stays the one
+// the user registered with. Every check of the current password goes
+// through here, so it accepts what the login accepts.
+func verifyPassword(hasher password.Hasher, user *domain.User, plain string) (string, bool, error) {
+	valid, err := hasher.Verify(plain, user.Password)
+	if err != nil || valid || !user.PasswordTrimmed {
+		return plain, valid, err
+	}
+
+	trimmed := strings.TrimSpace(plain)
+	if trimmed == plain || trimmed == "" {
+		return plain, false, nil
+	}
+
+	valid, err = hasher.Verify(trimmed, user.Password)
+	return trimmed, valid, err
+}
@@ -0,0 +1,84 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/mailer"
+	"auction/pkg/securetoken"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PasswordResets issues and redeems the single-use tokens mailed by
+// /password/forgot. Only the SHA-256 of a token is stored.
+type PasswordResets struct {
+	repository PasswordResetRepository
+	mailer     mailer.Mailer
+	resetURL   string
+	ttl        time.Duration
+}
+
+func NewPasswordResets(repository PasswordResetRepository, mailer mailer.Mailer, resetURL string, ttl time.Duration) *PasswordResets {
+	return &PasswordResets{
+		repository: repository,
+		mailer:     mailer,
+		resetURL:   resetURL,
+		ttl:        ttl,
+	}
+}
+
+// Start stores a new reset token for the user and mails the link.
+func (p *PasswordResets) Start(ctx context.Context, user *domain.User) error {
+	token, err := securetoken.Generate()
+	if err != nil {
+		return err
+	}
+
+	if err := p.repository.CreatePasswordResetToken(ctx, user.ID, securetoken.Hash(token), time.Now().Add(p.ttl)); err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(p.resetURL, token)
+	if err != nil {
+		return err
+	}
+
+	return sendMail(ctx, p.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your account. Open the link below to choose a new one:\n\n%s\n\nThe link works once and expires in %s. If you did not ask for this, you can ignore this email.\n",
+			user.Name, link, describeDuration(p.ttl),
+		),
+	})
+}
+
+// Lookup returns the ID of the user a token was issued to without consuming
+// it, so the new password can be validated before the link is spent.
+func (p *PasswordResets) Lookup(ctx context.Context, token string) (string, bool, error) {
+	reset, err := p.repository.FindPasswordResetToken(ctx, securetoken.Hash(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return reset.UserID, true, nil
+}
+
+// Redeem consumes a token and returns the ID of the user it was issued to.
+// It returns false for unknown, used or expired tokens.
+func (p *PasswordResets) Redeem(ctx context.Context, token string) (string, bool, error) {
+	reset, err := p.repository.ConsumePasswordResetToken(ctx, securetoken.Hash(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return reset.UserID, true, nil
+}
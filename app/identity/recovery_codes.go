@@ -0,0 +1,30 @@
+package identity
+
+import (
+	"auction/pkg/securetoken"
+	"auction/pkg/totp"
+	"context"
+)
+
+const recoveryCodeCount = 10
+
+// issueRecoveryCodes replaces the user's recovery codes with a fresh set.
+// Only hashes are stored; the plaintext codes are returned to be shown once.
+func issueRecoveryCodes(ctx context.Context, repository RecoveryCodeRepository, userID string) ([]string, error) {
+	codes := totp.GenerateRecoveryCodes(recoveryCodeCount)
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := repository.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	return securetoken.Hash(totp.NormalizeRecoveryCode(code))
+}
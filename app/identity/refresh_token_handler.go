@@ -0,0 +1,92 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"errors"
+	"strings"
+)
+
+type RefreshTokenHandler struct {
+	repository  Repository
+	tokenIssuer *TokenIssuer
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshTokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func NewRefreshTokenHandler(repository Repository, tokenIssuer *TokenIssuer) *RefreshTokenHandler {
+	return &RefreshTokenHandler{
+		repository:  repository,
+		tokenIssuer: tokenIssuer,
+	}
+}
+
+// Handle rotates a first-party refresh token. Tokens issued to OIDC clients
+// are only accepted by /token.
+func (h *RefreshTokenHandler) Handle(ctx context.Context, req *RefreshTokenRequest) (*RefreshTokenResponse, error) {
+	req.RefreshToken = strings.TrimSpace(req.RefreshToken)
+	if req.RefreshToken == "" {
+		return nil, httperror.BadRequest(
+			"identity.refresh_token.invalid_payload",
+			"Refresh token field is required",
+			nil,
+		)
+	}
+
+	stored, err := h.tokenIssuer.redeem(ctx, req.RefreshToken, "")
+	switch {
+	case errors.Is(err, errInvalidRefreshToken):
+		return nil, invalidRefreshToken()
+	case errors.Is(err, errRefreshTokenReused):
+		return nil, httperror.Unauthorized(
+			"identity.refresh_token.reused",
+			"Refresh token has already been used",
+			nil,
+		)
+	case err != nil:
+		return nil, httperror.InternalServerError(
+			"identity.refresh_token.rotation_failed",
+			"Internal server error",
+			nil,
+		)
+	}
+
+	user, err := h.repository.FindByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, invalidRefreshToken()
+	}
+
+	pair, err := h.tokenIssuer.issue(ctx, user, stored.FamilyID, stored.Methods(), ClientGrant{})
+	if errors.Is(err, errUserDisabled) {
+		return nil, invalidRefreshToken()
+	}
+	if err != nil {
+		return nil, httperror.InternalServerError(
+			"identity.refresh_token.token_generation_failed",
+			"Failed to generate token",
+			nil,
+		)
+	}
+
+	return &RefreshTokenResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}, nil
+}
+
+func invalidRefreshToken() error {
+	return httperror.Unauthorized(
+		"identity.refresh_token.invalid",
+		"Refresh token is invalid or expired",
+		nil,
+	)
+}
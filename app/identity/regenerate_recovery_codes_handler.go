@@ -0,0 +1,48 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+)
+
+type RegenerateRecoveryCodesHandler struct {
+	repository             Repository
+	recoveryCodeRepository RecoveryCodeRepository
+}
+
+type RegenerateRecoveryCodesRequest struct {
+}
+
+type RegenerateRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func NewRegenerateRecoveryCodesHandler(repository Repository, recoveryCodeRepository RecoveryCodeRepository) *RegenerateRecoveryCodesHandler {
+	return &RegenerateRecoveryCodesHandler{
+		repository:             repository,
+		recoveryCodeRepository: recoveryCodeRepository,
+	}
+}
+
+func (r RegenerateRecoveryCodesHandler) Handle(ctx context.Context, _ *RegenerateRecoveryCodesRequest) (*RegenerateRecoveryCodesResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	user, err := r.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.NotFound("identity.regenerate_recovery_codes.not_found", "User not found", nil)
+	}
+
+	if !user.TwoFactorEnabled || !user.TwoFactorVerified {
+		return nil, httperror.BadRequest("identity.regenerate_recovery_codes.two_factor_disabled", "Two factor not enabled", nil)
+	}
+
+	recoveryCodes, err := issueRecoveryCodes(ctx, r.recoveryCodeRepository, user.ID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.regenerate_recovery_codes.server_error", "Internal server error", nil)
+	}
+
+	return &RegenerateRecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
@@ -2,23 +2,30 @@ package identity
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"strings"
 
+	"auction/domain"
 	"auction/pkg/httperror"
+	"auction/pkg/password"
 
 	"github.com/lib/pq"
+	"go.uber.org/zap"
 )
 
 type RegisterHandler struct {
-	repository Repository
+	repository        Repository
+	hasher            password.Hasher
+	policy            *password.Policy
+	emailVerification *EmailVerification
 }
 
-func NewRegisterHandler(repository Repository) *RegisterHandler {
+func NewRegisterHandler(repository Repository, hasher password.Hasher, policy *password.Policy, emailVerification *EmailVerification) *RegisterHandler {
 	return &RegisterHandler{
-		repository: repository,
+		repository:        repository,
+		hasher:            hasher,
+		policy:            policy,
+		emailVerification: emailVerification,
 	}
 }
 
@@ -36,12 +43,8 @@ type RegisterResponse struct {
 
 func (h *RegisterHandler) Handle(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
 	req.Email = strings.TrimSpace(req.Email)
-	req.Password = strings.TrimSpace(req.Password)
 	req.Name = strings.TrimSpace(req.Name)
 
-	hash := sha256.Sum256([]byte(req.Password))
-	hashedPassword := hex.EncodeToString(hash[:])
-
 	if req.Email == "" {
 		return nil, httperror.BadRequest("identity.register.email_required", "Email field is required", nil)
 	}
@@ -54,6 +57,19 @@ func (h *RegisterHandler) Handle(ctx context.Context, req *RegisterRequest) (*Re
 		return nil, httperror.BadRequest("identity.register.name_required", "Name field is required", nil)
 	}
 
+	if err := checkPasswordPolicy(h.policy, "identity.register.weak_password", req.Password, req.Email, req.Name); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := h.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, httperror.InternalServerError(
+			"identity.register.hash_failed",
+			"An error occurred during registration",
+			nil,
+		)
+	}
+
 	id, err := h.repository.Create(ctx, req.Email, hashedPassword, req.Name)
 	if err != nil {
 		if isUniqueViolation(err) {
@@ -70,6 +86,14 @@ func (h *RegisterHandler) Handle(ctx context.Context, req *RegisterRequest) (*Re
 			nil,
 		)
 	}
+
+	// The account exists either way; a failed send is retried through
+	// /email/resend.
+	user := &domain.User{ID: id, Email: req.Email, Name: req.Name}
+	if err := h.emailVerification.Send(ctx, user); err != nil {
+		zap.L().Warn("Failed to send verification email", zap.String("user_id", id), zap.Error(err))
+	}
+
 	return &RegisterResponse{ID: id, Email: req.Email, Name: req.Name}, nil
 }
 
@@ -2,7 +2,9 @@ package identity
 
 import (
 	"auction/domain"
+	"auction/pkg/envelope"
 	"context"
+	"time"
 )
 
 type Repository interface {
@@ -10,8 +12,166 @@ type Repository interface {
 	FindByEmail(ctx context.Context, email string) (*domain.User, error)
 	Create(ctx context.Context, email string, password string, name string) (string, error)
 	Update(ctx context.Context, id string, email string, name string) error
-	EnableTwoFactor(ctx context.Context, id string, twoFactorSecret string) error
+	// UpdatePassword stores the hash of a password the user just chose, which
+	// clears password_trimmed.
+	UpdatePassword(ctx context.Context, id string, password string) error
+	// RehashPassword replaces the hash of an unchanged password, e.g. with a
+	// stronger algorithm, and leaves password_trimmed as it is.
+	RehashPassword(ctx context.Context, id string, password string) error
+	EnableTwoFactor(ctx context.Context, id string, twoFactorSecret envelope.Sealed, config domain.TwoFactorConfig) error
 	DisableTwoFactor(ctx context.Context, id string) error
+	// SetPasskeySecondFactor turns the requirement of a passkey after the
+	// password on or off.
+	SetPasskeySecondFactor(ctx context.Context, id string, enabled bool) error
+	// SetDisabled sets or clears disabled_at and reports whether the account
+	// changed state.
+	SetDisabled(ctx context.Context, id string, disabled bool) (bool, error)
 	MarkTwoFactorVerified(ctx context.Context, id string) error
-	SetRecoveryCodes(ctx context.Context, id string, recoveryCodes string) error
+	// AcceptTwoFactorCounter records the time step of an accepted OTP. It
+	// returns false when an equal or later step was already recorded, which
+	// means the code is being replayed.
+	AcceptTwoFactorCounter(ctx context.Context, id string, counter int64) (bool, error)
+	// MarkEmailVerified sets email_verified_at if the user's address still
+	// equals email, and reports whether it did.
+	MarkEmailVerified(ctx context.Context, id string, email string) (bool, error)
+	// SetPendingEmail records the address a user asked to switch to; an
+	// empty email clears it.
+	SetPendingEmail(ctx context.Context, id string, email string) error
+	// ConfirmPendingEmail makes the pending address the user's verified email
+	// if it still equals email, and reports whether it did. Outstanding
+	// password reset tokens, mailed to the old address, are invalidated.
+	ConfirmPendingEmail(ctx context.Context, id string, email string) (bool, error)
+}
+
+type RecoveryCodeRepository interface {
+	// ReplaceRecoveryCodes discards every existing code of the user and stores
+	// the given hashes as the new set.
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	ListRecoveryCodes(ctx context.Context, userID string) ([]domain.RecoveryCode, error)
+	// ConsumeRecoveryCode marks an unused code as used and reports whether one
+	// matched.
+	ConsumeRecoveryCode(ctx context.Context, userID string, codeHash string) (bool, error)
+}
+
+type RefreshTokenRepository interface {
+	// CreateRefreshToken stores a token of the family. clientID and scope are
+	// empty for first-party sign-ins.
+	CreateRefreshToken(ctx context.Context, userID string, familyID string, tokenHash string, amr string, clientID string, scope string, expiresAt time.Time) error
+	FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error)
+	// MarkRefreshTokenUsed atomically consumes an unused token. It returns false
+	// when the token had already been used, which callers must treat as reuse.
+	MarkRefreshTokenUsed(ctx context.Context, id string) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID string) error
+}
+
+type TwoFactorSecretRepository interface {
+	FindUsersWithTwoFactorSecret(ctx context.Context) ([]domain.User, error)
+	// UpdateTwoFactorSecret replaces the stored secret only if it still equals
+	// previous, so a concurrent re-enrollment is never overwritten, and
+	// reports whether it did.
+	UpdateTwoFactorSecret(ctx context.Context, id string, previous envelope.Sealed, secret envelope.Sealed) (bool, error)
+}
+
+type CredentialRepository interface {
+	CreateCredential(ctx context.Context, userID string, credentialID []byte, name string, data []byte) (string, error)
+	ListCredentials(ctx context.Context, userID string) ([]domain.Credential, error)
+	FindCredentialByCredentialID(ctx context.Context, credentialID []byte) (*domain.Credential, error)
+	// UpdateCredentialUsage stores the credential after a successful assertion,
+	// which carries the new signature counter, and sets last_used_at.
+	UpdateCredentialUsage(ctx context.Context, id string, data []byte) error
+	// DeleteCredential removes a credential owned by the user and reports
+	// whether one matched.
+	DeleteCredential(ctx context.Context, userID string, id string) (bool, error)
+	CreateWebAuthnSession(ctx context.Context, userID string, purpose string, data []byte, expiresAt time.Time) (string, error)
+	// ConsumeWebAuthnSession deletes and returns an unexpired session of the
+	// given purpose, so every challenge can be answered at most once.
+	ConsumeWebAuthnSession(ctx context.Context, id string, purpose string) (*domain.WebAuthnSession, error)
+}
+
+type PasswordResetRepository interface {
+	CreatePasswordResetToken(ctx context.Context, userID string, tokenHash string, expiresAt time.Time) error
+	// FindPasswordResetToken returns an unused, unexpired token without
+	// consuming it, or sql.ErrNoRows.
+	FindPasswordResetToken(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error)
+	// ConsumePasswordResetToken marks an unused, unexpired token as used and
+	// invalidates every other outstanding token of the same user. It returns
+	// sql.ErrNoRows when no such token exists.
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error)
+}
+
+type SessionRepository interface {
+	// RecordSession creates the session or, on refresh, stores the jti of its
+	// newest access token and the client it was refreshed from.
+	RecordSession(ctx context.Context, session domain.Session) error
+	// ListActiveSessions returns the user's sessions whose refresh token
+	// family is still usable, most recently seen first.
+	ListActiveSessions(ctx context.Context, userID string) ([]domain.Session, error)
+	// RevokeSession deletes a session owned by the user together with its
+	// refresh tokens and reports whether one matched.
+	RevokeSession(ctx context.Context, userID string, id string) (bool, error)
+	// FindSession returns a session owned by the user, or sql.ErrNoRows.
+	FindSession(ctx context.Context, userID string, id string) (*domain.Session, error)
+}
+
+type OAuthRepository interface {
+	// CreateOAuthClient stores the client and returns its generated id.
+	CreateOAuthClient(ctx context.Context, client domain.OAuthClient) (string, error)
+	FindOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error)
+	ListOAuthClients(ctx context.Context) ([]domain.OAuthClient, error)
+	// DeleteOAuthClient removes the client with its codes and refresh tokens
+	// and reports whether one matched.
+	DeleteOAuthClient(ctx context.Context, id string) (bool, error)
+	CreateAuthorizationCode(ctx context.Context, code domain.AuthorizationCode) error
+	// FindAuthorizationCode returns an unexpired code whether or not it was
+	// used, or sql.ErrNoRows.
+	FindAuthorizationCode(ctx context.Context, codeHash string) (*domain.AuthorizationCode, error)
+	// ConsumeAuthorizationCode marks an unused code as used. It returns false
+	// when the code had already been used, which callers must treat as replay.
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (bool, error)
+}
+
+type TrustedDeviceRepository interface {
+	CreateTrustedDevice(ctx context.Context, device domain.TrustedDevice) error
+	// FindTrustedDevice returns the user's unexpired device with the given
+	// token hash, or sql.ErrNoRows.
+	FindTrustedDevice(ctx context.Context, userID string, tokenHash string) (*domain.TrustedDevice, error)
+	TouchTrustedDevice(ctx context.Context, id string) error
+	ListTrustedDevices(ctx context.Context, userID string) ([]domain.TrustedDevice, error)
+	// DeleteTrustedDevice forgets a device owned by the user and reports
+	// whether one matched.
+	DeleteTrustedDevice(ctx context.Context, userID string, id string) (bool, error)
+	DeleteUserTrustedDevices(ctx context.Context, userID string) error
+}
+
+type LoginThrottleRepository interface {
+	// RecordLoginAttempt counts an attempt of an account or IP as a failure
+	// before its credentials are checked, restarting from one when the
+	// previous failure is older than window, and locks the subject for
+	// delay(failures). Both happen atomically, so concurrent attempts see the
+	// lock. A subject that is already locked is not counted; the returned
+	// bool reports whether the attempt was.
+	RecordLoginAttempt(ctx context.Context, scope string, subject string, window time.Duration, delay func(failures int) time.Duration) (*domain.LoginThrottle, bool, error)
+	// ForgiveLoginAttempt takes back one counted attempt whose credentials
+	// turned out to be right. A lock it caused stays in place.
+	ForgiveLoginAttempt(ctx context.Context, scope string, subject string) error
+	// ClearLoginThrottle forgets the failures of an account or IP and reports
+	// whether any were recorded.
+	ClearLoginThrottle(ctx context.Context, scope string, subject string) (bool, error)
+}
+
+type AuditRepository interface {
+	RecordAuditEvent(ctx context.Context, event domain.AuditEvent) error
+}
+
+type RoleRepository interface {
+	ListRoles(ctx context.Context) ([]domain.Role, error)
+	FindRole(ctx context.Context, name string) (*domain.Role, error)
+	ListUserRoles(ctx context.Context, userID string) ([]domain.Role, error)
+	// AssignUserRole grants the role and reports whether the user did not
+	// hold it yet.
+	AssignUserRole(ctx context.Context, userID string, role string) (bool, error)
+	// RemoveUserRole takes the role away and reports whether the user held
+	// it.
+	RemoveUserRole(ctx context.Context, userID string, role string) (bool, error)
 }
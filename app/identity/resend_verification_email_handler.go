@@ -0,0 +1,47 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type ResendVerificationEmailHandler struct {
+	repository        Repository
+	emailVerification *EmailVerification
+}
+
+type ResendVerificationEmailRequest struct {
+}
+
+type ResendVerificationEmailResponse struct {
+}
+
+func NewResendVerificationEmailHandler(repository Repository, emailVerification *EmailVerification) *ResendVerificationEmailHandler {
+	return &ResendVerificationEmailHandler{
+		repository:        repository,
+		emailVerification: emailVerification,
+	}
+}
+
+func (r ResendVerificationEmailHandler) Handle(ctx context.Context, _ *ResendVerificationEmailRequest) (*ResendVerificationEmailResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	user, err := r.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.NotFound("identity.resend_verification_email.not_found", "User not found", nil)
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		return nil, httperror.Conflict("identity.resend_verification_email.already_verified", "Email already verified", nil)
+	}
+
+	if err := r.emailVerification.Send(ctx, user); err != nil {
+		zap.L().Error("Failed to send verification email", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, httperror.InternalServerError("identity.resend_verification_email.send_failed", "Failed to send verification email", nil)
+	}
+
+	return nil, httperror.Accepted("identity.resend_verification_email.accepted", "Verification email sent", nil)
+}
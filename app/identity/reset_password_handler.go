@@ -0,0 +1,116 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/mailer"
+	"auction/pkg/password"
+	"auction/pkg/revocation"
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type ResetPasswordHandler struct {
+	repository             Repository
+	refreshTokenRepository RefreshTokenRepository
+	auditRepository        AuditRepository
+	passwordResets         *PasswordResets
+	hasher                 password.Hasher
+	policy                 *password.Policy
+	revocations            revocation.Store
+	mailer                 mailer.Mailer
+	trustedDevices         *TrustedDevices
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ResetPasswordResponse struct {
+}
+
+func NewResetPasswordHandler(repository Repository, refreshTokenRepository RefreshTokenRepository, auditRepository AuditRepository, passwordResets *PasswordResets, hasher password.Hasher, policy *password.Policy, revocations revocation.Store, mailer mailer.Mailer, trustedDevices *TrustedDevices) *ResetPasswordHandler {
+	return &ResetPasswordHandler{
+		repository:             repository,
+		refreshTokenRepository: refreshTokenRepository,
+		auditRepository:        auditRepository,
+		passwordResets:         passwordResets,
+		hasher:                 hasher,
+		policy:                 policy,
+		revocations:            revocations,
+		mailer:                 mailer,
+		trustedDevices:         trustedDevices,
+	}
+}
+
+// Handle sets the new password and signs the user out everywhere, since the
+// reset may be a response to a compromised account.
+func (h ResetPasswordHandler) Handle(ctx context.Context, req *ResetPasswordRequest) (*ResetPasswordResponse, error) {
+	req.Token = strings.TrimSpace(req.Token)
+
+	if req.Token == "" {
+		return nil, httperror.BadRequest("identity.reset_password.invalid_token", "Reset link is invalid or expired", nil)
+	}
+
+	if req.Password == "" {
+		return nil, httperror.BadRequest("identity.reset_password.password_required", "Password field is required", nil)
+	}
+
+	userID, ok, err := h.passwordResets.Lookup(ctx, req.Token)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.reset_password.internal_server_error", "Internal server error", nil)
+	}
+	if !ok {
+		return nil, httperror.BadRequest("identity.reset_password.invalid_token", "Reset link is invalid or expired", nil)
+	}
+
+	user, err := h.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.BadRequest("identity.reset_password.invalid_token", "Reset link is invalid or expired", nil)
+	}
+
+	// A rejected password leaves the link usable for another attempt.
+	if err := checkPasswordPolicy(h.policy, "identity.reset_password.weak_password", req.Password, user.Email, user.Name); err != nil {
+		return nil, err
+	}
+
+	redeemedID, ok, err := h.passwordResets.Redeem(ctx, req.Token)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.reset_password.internal_server_error", "Internal server error", nil)
+	}
+	if !ok || redeemedID != user.ID {
+		return nil, httperror.BadRequest("identity.reset_password.invalid_token", "Reset link is invalid or expired", nil)
+	}
+
+	hashedPassword, err := h.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.reset_password.internal_server_error", "Internal server error", nil)
+	}
+
+	if err := h.repository.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return nil, httperror.InternalServerError("identity.reset_password.internal_server_error", "Internal server error", nil)
+	}
+
+	if err := revocation.RevokeUserTokens(ctx, h.revocations, user.ID); err != nil {
+		return nil, httperror.InternalServerError("identity.reset_password.internal_server_error", "Internal server error", nil)
+	}
+
+	if err := h.refreshTokenRepository.RevokeUserRefreshTokens(ctx, user.ID); err != nil {
+		return nil, httperror.InternalServerError("identity.reset_password.internal_server_error", "Internal server error", nil)
+	}
+
+	// Remembered devices must pass the second factor again.
+	if err := h.trustedDevices.ForgetAll(ctx, user.ID); err != nil {
+		return nil, httperror.InternalServerError("identity.reset_password.internal_server_error", "Internal server error", nil)
+	}
+
+	recordAudit(ctx, h.auditRepository, user.ID, auditPasswordReset, nil)
+
+	if err := sendPasswordChangedNotice(ctx, h.mailer, user); err != nil {
+		zap.L().Warn("Failed to send password change notice", zap.String("user_id", user.ID), zap.Error(err))
+	}
+
+	return nil, httperror.NoContent("identity.reset_password.no_content", "No content", nil)
+}
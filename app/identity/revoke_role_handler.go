@@ -0,0 +1,47 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"errors"
+)
+
+type RevokeRoleHandler struct {
+	roles *Roles
+}
+
+type RevokeRoleRequest struct {
+	ID   string `params:"id"`
+	Role string `params:"role"`
+}
+
+type RevokeRoleResponse struct {
+}
+
+func NewRevokeRoleHandler(roles *Roles) *RevokeRoleHandler {
+	return &RevokeRoleHandler{
+		roles: roles,
+	}
+}
+
+// Handle takes the role away and revokes the user's access tokens, which
+// still carry its permissions.
+func (r RevokeRoleHandler) Handle(ctx context.Context, req *RevokeRoleRequest) (*RevokeRoleResponse, error) {
+	adminID := ctx.Value("UserID").(string)
+
+	revoked, err := r.roles.Revoke(ctx, req.ID, req.Role, adminID)
+	if errors.Is(err, errUnknownUser) {
+		return nil, httperror.NotFound("identity.revoke_role.user_not_found", "User not found", nil)
+	}
+	if errors.Is(err, errUnknownRole) {
+		return nil, httperror.NotFound("identity.revoke_role.role_not_found", "Role not found", nil)
+	}
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.revoke_role.internal_server_error", "Internal server error", nil)
+	}
+	if !revoked {
+		return nil, httperror.NotFound("identity.revoke_role.not_assigned", "User does not hold the role", nil)
+	}
+
+	return nil, httperror.NoContent("identity.revoke_role.no_content", "No content", nil)
+}
@@ -0,0 +1,59 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/revocation"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RevokeSessionHandler struct {
+	sessionRepository SessionRepository
+	auditRepository   AuditRepository
+	revocations       revocation.Store
+}
+
+type RevokeSessionRequest struct {
+	ID string `params:"id"`
+}
+
+type RevokeSessionResponse struct {
+}
+
+func NewRevokeSessionHandler(sessionRepository SessionRepository, auditRepository AuditRepository, revocations revocation.Store) *RevokeSessionHandler {
+	return &RevokeSessionHandler{
+		sessionRepository: sessionRepository,
+		auditRepository:   auditRepository,
+		revocations:       revocations,
+	}
+}
+
+// Handle signs a session out immediately: its refresh tokens are revoked and
+// its sid is denylisted for as long as an access token of it can be valid.
+func (r RevokeSessionHandler) Handle(ctx context.Context, req *RevokeSessionRequest) (*RevokeSessionResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	if _, err := uuid.Parse(req.ID); err != nil {
+		return nil, httperror.NotFound("identity.revoke_session.not_found", "Session not found", nil)
+	}
+
+	revoked, err := r.sessionRepository.RevokeSession(ctx, userID, req.ID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.revoke_session.internal_server_error", "Internal server error", nil)
+	}
+	if !revoked {
+		return nil, httperror.NotFound("identity.revoke_session.not_found", "Session not found", nil)
+	}
+
+	if err := r.revocations.RevokeToken(ctx, req.ID, time.Now().Add(jwt.AccessTokenTTL())); err != nil {
+		return nil, httperror.InternalServerError("identity.revoke_session.internal_server_error", "Internal server error", nil)
+	}
+
+	recordAudit(ctx, r.auditRepository, userID, auditSessionRevoked, map[string]any{"session_id": req.ID})
+
+	return nil, httperror.NoContent("identity.revoke_session.no_content", "No content", nil)
+}
@@ -0,0 +1,50 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type RevokeTrustedDeviceHandler struct {
+	trustedDeviceRepository TrustedDeviceRepository
+	auditRepository         AuditRepository
+}
+
+type RevokeTrustedDeviceRequest struct {
+	ID string `params:"id"`
+}
+
+type RevokeTrustedDeviceResponse struct {
+}
+
+func NewRevokeTrustedDeviceHandler(trustedDeviceRepository TrustedDeviceRepository, auditRepository AuditRepository) *RevokeTrustedDeviceHandler {
+	return &RevokeTrustedDeviceHandler{
+		trustedDeviceRepository: trustedDeviceRepository,
+		auditRepository:         auditRepository,
+	}
+}
+
+// Handle forgets a remembered device, so its next login asks for the second
+// factor again. Sessions already running on it are not affected.
+func (r RevokeTrustedDeviceHandler) Handle(ctx context.Context, req *RevokeTrustedDeviceRequest) (*RevokeTrustedDeviceResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	if _, err := uuid.Parse(req.ID); err != nil {
+		return nil, httperror.NotFound("identity.revoke_trusted_device.not_found", "Device not found", nil)
+	}
+
+	deleted, err := r.trustedDeviceRepository.DeleteTrustedDevice(ctx, userID, req.ID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.revoke_trusted_device.internal_server_error", "Internal server error", nil)
+	}
+	if !deleted {
+		return nil, httperror.NotFound("identity.revoke_trusted_device.not_found", "Device not found", nil)
+	}
+
+	recordAudit(ctx, r.auditRepository, userID, auditDeviceRevoked, map[string]any{"device_id": req.ID})
+
+	return nil, httperror.NoContent("identity.revoke_trusted_device.no_content", "No content", nil)
+}
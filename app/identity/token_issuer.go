@@ -0,0 +1,189 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/jwt"
+	"auction/pkg/revocation"
+	"auction/pkg/securetoken"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// TokenPair is the access/refresh token combination returned by every
+// endpoint that completes an authentication.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+// ClientGrant narrows the tokens of a session started by an OIDC client to
+// that client and the space-separated scope the user authorized. The zero
+// value is a first-party session.
+type ClientGrant struct {
+	ClientID string
+	Scope    string
+}
+
+var (
+	errInvalidRefreshToken = errors.New("identity: refresh token is invalid or expired")
+	errRefreshTokenReused  = errors.New("identity: refresh token has already been used")
+)
+
+type TokenIssuer struct {
+	repository        RefreshTokenRepository
+	sessionRepository SessionRepository
+	roleRepository    RoleRepository
+	revocations       revocation.Store
+	refreshTokenTTL   time.Duration
+}
+
+func NewTokenIssuer(repository RefreshTokenRepository, sessionRepository SessionRepository, roleRepository RoleRepository, revocations revocation.Store, refreshTokenTTL time.Duration) *TokenIssuer {
+	return &TokenIssuer{
+		repository:        repository,
+		sessionRepository: sessionRepository,
+		roleRepository:    roleRepository,
+		revocations:       revocations,
+		refreshTokenTTL:   refreshTokenTTL,
+	}
+}
+
+// Issue starts a new session, and with it a refresh token family, for the
+// user. amr lists the authentication methods the user just completed.
+func (i *TokenIssuer) Issue(ctx context.Context, user *domain.User, amr []string) (*TokenPair, error) {
+	return i.issue(ctx, user, uuid.New().String(), amr, ClientGrant{})
+}
+
+// issue mints an access token and a refresh token that belongs to familyID.
+// Rotation reuses the family so a replayed token can revoke all descendants.
+// The family is also the session: its id is the sid claim, and the session
+// row follows the newest access token and the client that refreshed it.
+// Roles are resolved on every issue, so a refresh picks up role changes.
+// Tokens issued to a client carry no roles or permissions: the client is
+// limited to the scope the user authorized. Disabled accounts get
+// errUserDisabled.
+func (i *TokenIssuer) issue(ctx context.Context, user *domain.User, familyID string, amr []string, grant ClientGrant) (*TokenPair, error) {
+	if user.IsDisabled() {
+		return nil, errUserDisabled
+	}
+
+	var roles, permissions []string
+	if grant.ClientID == "" {
+		var err error
+		if roles, permissions, err = i.resolveRoles(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	accessToken, claims, err := jwt.CreateAccessToken(user, jwt.AccessTokenOptions{
+		SessionID:   familyID,
+		ClientID:    grant.ClientID,
+		Scope:       grant.Scope,
+		AMR:         amr,
+		Roles:       roles,
+		Permissions: permissions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := securetoken.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(i.refreshTokenTTL)
+	err = i.repository.CreateRefreshToken(ctx, user.ID, familyID, securetoken.Hash(refreshToken), strings.Join(amr, " "), grant.ClientID, grant.Scope, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	clientIP, _ := ctx.Value("ClientIP").(string)
+	userAgent, _ := ctx.Value("UserAgent").(string)
+	err = i.sessionRepository.RecordSession(ctx, domain.Session{
+		ID:        familyID,
+		UserID:    user.ID,
+		JTI:       claims.ID,
+		IPAddress: nullString(clientIP),
+		UserAgent: nullString(userAgent),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(jwt.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// resolveRoles returns the names of the user's roles and the union of their
+// permissions.
+func (i *TokenIssuer) resolveRoles(ctx context.Context, userID string) ([]string, []string, error) {
+	userRoles, err := i.roleRepository.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var roles, permissions []string
+	for _, role := range userRoles {
+		roles = append(roles, role.Name)
+		for _, permission := range role.PermissionNames() {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	slices.Sort(permissions)
+
+	return roles, permissions, nil
+}
+
+// redeem consumes a refresh token so that it can be rotated. The token must
+// belong to clientID, or to a first-party session when clientID is empty.
+// A token that comes back after use was stolen or replayed, so its whole
+// family and the session's access tokens are revoked and
+// errRefreshTokenReused returned.
+func (i *TokenIssuer) redeem(ctx context.Context, refreshToken, clientID string) (*domain.RefreshToken, error) {
+	stored, err := i.repository.FindRefreshTokenByHash(ctx, securetoken.Hash(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.RevokedAt.Valid || stored.IsExpired(time.Now()) || stored.ClientID.String != clientID {
+		return nil, errInvalidRefreshToken
+	}
+
+	consumed, err := i.repository.MarkRefreshTokenUsed(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !consumed {
+		zap.L().Warn("Refresh token reuse detected", zap.String("user_id", stored.UserID), zap.String("family_id", stored.FamilyID))
+
+		if err := i.repository.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+			zap.L().Error("Failed to revoke refresh token family", zap.String("family_id", stored.FamilyID), zap.Error(err))
+		}
+		// The family id is the sid, so this also ends the access token
+		// already minted from the family.
+		if err := i.revocations.RevokeToken(ctx, stored.FamilyID, time.Now().Add(jwt.AccessTokenTTL())); err != nil {
+			zap.L().Error("Failed to revoke session tokens", zap.String("family_id", stored.FamilyID), zap.Error(err))
+		}
+
+		return nil, errRefreshTokenReused
+	}
+
+	return stored, nil
+}
@@ -0,0 +1,78 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/jwt"
+	"auction/pkg/securetoken"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// TrustedDevices remembers devices on which the user completed a 2FA
+// challenge. The device keeps a signed token bound to the user; only its
+// SHA-256 is stored, so a database leak cannot be replayed, and deleting the
+// row revokes the token before it expires.
+type TrustedDevices struct {
+	repository TrustedDeviceRepository
+}
+
+func NewTrustedDevices(repository TrustedDeviceRepository) *TrustedDevices {
+	return &TrustedDevices{
+		repository: repository,
+	}
+}
+
+// Remember stores the requesting device and returns the token it must
+// present to /login.
+func (t *TrustedDevices) Remember(ctx context.Context, user *domain.User) (string, error) {
+	token, claims, err := jwt.CreateTrustedDeviceToken(user, uuid.New().String())
+	if err != nil {
+		return "", err
+	}
+
+	clientIP, _ := ctx.Value("ClientIP").(string)
+	userAgent, _ := ctx.Value("UserAgent").(string)
+	err = t.repository.CreateTrustedDevice(ctx, domain.TrustedDevice{
+		ID:        claims.ID,
+		UserID:    user.ID,
+		TokenHash: securetoken.Hash(token),
+		IPAddress: nullString(clientIP),
+		UserAgent: nullString(userAgent),
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Recognize reports whether token was issued to user by Remember and the
+// device has neither expired nor been revoked.
+func (t *TrustedDevices) Recognize(ctx context.Context, user *domain.User, token string) (bool, error) {
+	claims, err := jwt.DecodeTrustedDeviceToken(token)
+	if err != nil || claims.Subject != user.ID {
+		return false, nil
+	}
+
+	device, err := t.repository.FindTrustedDevice(ctx, user.ID, securetoken.Hash(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := t.repository.TouchTrustedDevice(ctx, device.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ForgetAll revokes every remembered device of the user.
+func (t *TrustedDevices) ForgetAll(ctx context.Context, userID string) error {
+	return t.repository.DeleteUserTrustedDevices(ctx, userID)
+}
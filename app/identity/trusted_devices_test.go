@@ -0,0 +1,51 @@
+package identity
+
+import (
+	"context"
+	"testing"
+)
+
+// Turning 2FA off and logging out everywhere forget remembered devices, so
+// they cannot skip a second factor turned on again later.
+func TestTrustedDevicesForgotten(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(ctx context.Context, f *oauthFixture, devices *TrustedDevices) error
+		want   string
+	}{
+		{"2fa disabled", func(ctx context.Context, f *oauthFixture, devices *TrustedDevices) error {
+			_, err := NewDisableTwoFactorHandler(f.store, devices).Handle(ctx, &DisableTwoFactorRequest{})
+			return err
+		}, "identity.enable_two_factor.no_content"},
+		{"logout-all", func(ctx context.Context, f *oauthFixture, devices *TrustedDevices) error {
+			_, err := NewLogoutAllHandler(f.store, f.revocations, devices).Handle(ctx, &LogoutAllRequest{})
+			return err
+		}, "identity.logout_all.no_content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOAuthFixture(t)
+			devices := NewTrustedDevices(f.store)
+			ctx := context.WithValue(context.Background(), "UserID", f.userID)
+
+			user, err := f.store.FindByID(ctx, f.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := devices.Remember(ctx, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recognized, err := devices.Recognize(ctx, user, token); err != nil || !recognized {
+				t.Fatalf("Recognize = (%v, %v) before forgetting", recognized, err)
+			}
+
+			assertHTTPError(t, tt.handle(ctx, f, devices), tt.want)
+
+			if recognized, err := devices.Recognize(ctx, user, token); err != nil || recognized {
+				t.Fatalf("Recognize = (%v, %v) after forgetting", recognized, err)
+			}
+		})
+	}
+}
@@ -3,35 +3,76 @@ package identity
 import (
 	"auction/pkg/httperror"
 	"auction/pkg/jwt"
+	"auction/pkg/revocation"
 	"context"
+	"encoding/json"
+	"errors"
 	"strings"
-	"auction/pkg/totp"
+
+	"go.uber.org/zap"
 )
 
 type TwoFactorChallengeHandler struct {
-	repository Repository
+	repository             Repository
+	recoveryCodeRepository RecoveryCodeRepository
+	tokenIssuer            *TokenIssuer
+	revocations            revocation.Store
+	otpVerifier            *OTPVerifier
+	passkeys               *Passkeys
+	lockout                *Lockout
+	trustedDevices         *TrustedDevices
 }
 
 type TwoFactorChallengeRequest struct {
-	Code string `json:"code"`
-	Jwt string `json:"jwt"`
+	Code         string `json:"code"`
+	NextCode     string `json:"next_code"`
+	RecoveryCode string `json:"recovery_code"`
+	Jwt          string `json:"jwt"`
+	// WebAuthnSessionID and WebAuthn answer the assertion started by
+	// /2fa/webauthn/begin.
+	WebAuthnSessionID string          `json:"webauthn_session_id"`
+	WebAuthn          json.RawMessage `json:"webauthn"`
+	// RememberDevice asks for a device_token that skips the second factor
+	// on this device in later logins.
+	RememberDevice bool `json:"remember_device"`
 }
 
 type TwoFactorChallengeResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	DeviceToken  string `json:"device_token,omitempty"`
 }
 
-func NewTwoFactorChallengeHandler(repository Repository) *TwoFactorChallengeHandler {
+func NewTwoFactorChallengeHandler(repository Repository, recoveryCodeRepository RecoveryCodeRepository, tokenIssuer *TokenIssuer, revocations revocation.Store, otpVerifier *OTPVerifier, passkeys *Passkeys, lockout *Lockout, trustedDevices *TrustedDevices) *TwoFactorChallengeHandler {
 	return &TwoFactorChallengeHandler{
-		repository: repository,
+		repository:             repository,
+		recoveryCodeRepository: recoveryCodeRepository,
+		tokenIssuer:            tokenIssuer,
+		revocations:            revocations,
+		otpVerifier:            otpVerifier,
+		passkeys:               passkeys,
+		lockout:                lockout,
+		trustedDevices:         trustedDevices,
 	}
 }
 
 func (t TwoFactorChallengeHandler) Handle(ctx context.Context, req *TwoFactorChallengeRequest) (*TwoFactorChallengeResponse, error) {
 	req.Code = strings.TrimSpace(req.Code)
+	req.NextCode = strings.TrimSpace(req.NextCode)
+	req.RecoveryCode = strings.TrimSpace(req.RecoveryCode)
 	req.Jwt = strings.TrimSpace(req.Jwt)
+	req.WebAuthnSessionID = strings.TrimSpace(req.WebAuthnSessionID)
 
-	claims, err := jwt.Decode(req.Jwt)
+	claims, err := jwt.DecodeMFAToken(req.Jwt)
+	if err != nil {
+		return nil, httperror.Unauthorized("identity.two_factor_challenge.invalid_token", "MFA token missing or invalid", nil)
+	}
+
+	err = revocation.Check(ctx, t.revocations, claims.ID, claims.SessionID, claims.Subject, claims.IssuedAt.Time)
+	if errors.Is(err, revocation.ErrRevoked) {
+		return nil, httperror.Unauthorized("identity.two_factor_challenge.invalid_token", "MFA token missing or invalid", nil)
+	}
 	if err != nil {
 		return nil, httperror.InternalServerError("identity.two_factor_challenge.internal_server_error", "Internal server error", nil)
 	}
@@ -41,16 +82,82 @@ func (t TwoFactorChallengeHandler) Handle(ctx context.Context, req *TwoFactorCha
 		return nil, httperror.NotFound("identity.two_factor_challenge.not_found", "User not found", nil)
 	}
 
-	if !user.TwoFactorSecret.Valid || !totp.VerifyOTP(user.TwoFactorSecret.String, req.Code, 0, 0, 0) {
-		return nil, httperror.BadRequest("identity.two_factor_challenge.invalid_code", "Invalid code", nil)
+	attempt, err := t.lockout.Attempt(ctx, user.ID, "identity.two_factor_challenge")
+	if err != nil {
+		return nil, err
+	}
+
+	secondFactor := jwt.AMROTP
+	if req.RecoveryCode != "" {
+		consumed, err := t.recoveryCodeRepository.ConsumeRecoveryCode(ctx, user.ID, hashRecoveryCode(req.RecoveryCode))
+		if err != nil {
+			return nil, httperror.InternalServerError("identity.two_factor_challenge.internal_server_error", "Internal server error", nil)
+		}
+		if !consumed {
+			attempt.Fail(ctx)
+			return nil, httperror.BadRequest("identity.two_factor_challenge.invalid_recovery_code", "Invalid recovery code", nil)
+		}
+
+		secondFactor = jwt.AMRRecoveryCode
+	} else if len(req.WebAuthn) > 0 {
+		passed, err := t.passkeys.FinishLogin(ctx, user, req.WebAuthnSessionID, req.WebAuthn)
+		if err != nil {
+			return nil, httperror.InternalServerError("identity.two_factor_challenge.internal_server_error", "Internal server error", nil)
+		}
+		if !passed {
+			attempt.Fail(ctx)
+			return nil, httperror.BadRequest("identity.two_factor_challenge.invalid_passkey", "Passkey could not be verified", nil)
+		}
+
+		secondFactor = jwt.AMRHardwareKey
+	} else {
+		var passed bool
+		if req.NextCode != "" {
+			// Two consecutive HOTP codes resynchronise a drifted token.
+			passed, err = t.otpVerifier.Resync(ctx, user, req.Code, req.NextCode)
+		} else {
+			passed, err = t.otpVerifier.Verify(ctx, user, req.Code)
+		}
+		if err != nil {
+			return nil, httperror.InternalServerError("identity.two_factor_challenge.internal_server_error", "Internal server error", nil)
+		}
+		if !passed {
+			attempt.Fail(ctx)
+			return nil, httperror.BadRequest("identity.two_factor_challenge.invalid_code", "Invalid code", nil)
+		}
+	}
+
+	attempt.Pass(ctx)
+
+	// The MFA token is single-use: once it has been exchanged it must not be
+	// usable for another round of guesses or a second access token.
+	if err := t.revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, httperror.InternalServerError("identity.two_factor_challenge.internal_server_error", "Internal server error", nil)
 	}
 
-	token, err := jwt.CreateToken(user)
+	pair, err := t.tokenIssuer.Issue(ctx, user, []string{jwt.AMRPassword, secondFactor})
+	if errors.Is(err, errUserDisabled) {
+		return nil, accountDisabled("identity.two_factor_challenge")
+	}
 	if err != nil {
 		return nil, httperror.InternalServerError("identity.two_factor_challenge.internal_server_error", "Internal server error", nil)
 	}
 
-	return &TwoFactorChallengeResponse{
-		Token: token,
-	}, nil
+	t.lockout.Succeed(ctx, user.ID)
+
+	res := &TwoFactorChallengeResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}
+
+	// The user is signed in either way; without a device token they are
+	// simply asked for the second factor again next time.
+	if req.RememberDevice {
+		if res.DeviceToken, err = t.trustedDevices.Remember(ctx, user); err != nil {
+			zap.L().Warn("Failed to remember device", zap.String("user_id", user.ID), zap.Error(err))
+		}
+	}
+
+	return res, nil
 }
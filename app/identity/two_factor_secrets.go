@@ -0,0 +1,91 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/envelope"
+	"context"
+)
+
+// TwoFactorSecrets encrypts OTP secrets at rest. Each secret has its own data
+// key, wrapped by the key provider's current master key.
+type TwoFactorSecrets struct {
+	provider envelope.KeyProvider
+}
+
+func NewTwoFactorSecrets(provider envelope.KeyProvider) *TwoFactorSecrets {
+	return &TwoFactorSecrets{
+		provider: provider,
+	}
+}
+
+func (s *TwoFactorSecrets) Seal(ctx context.Context, userID, secret string) (envelope.Sealed, error) {
+	return envelope.Seal(ctx, s.provider, []byte(secret), []byte(userID))
+}
+
+// Open returns the user's plaintext secret. Rows written before encryption was
+// introduced have no key version and are returned as stored.
+func (s *TwoFactorSecrets) Open(ctx context.Context, user *domain.User) (string, error) {
+	if !user.TwoFactorKeyVersion.Valid {
+		return user.TwoFactorSecret.String, nil
+	}
+
+	plaintext, err := envelope.Open(ctx, s.provider, sealedTwoFactorSecret(user), []byte(user.ID))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether the secret is stored in plaintext or under a
+// master key other than the current one.
+func (s *TwoFactorSecrets) NeedsRotation(user *domain.User) bool {
+	return !user.TwoFactorKeyVersion.Valid || user.TwoFactorKeyVersion.String != s.provider.CurrentVersion()
+}
+
+// Rotate re-encrypts every stored secret that NeedsRotation under a fresh
+// data key wrapped by the current master key. It returns the number of rows
+// rewritten; rows another writer changed in the meantime are left alone and
+// not counted.
+func (s *TwoFactorSecrets) Rotate(ctx context.Context, repository TwoFactorSecretRepository) (int, error) {
+	users, err := repository.FindUsersWithTwoFactorSecret(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for i := range users {
+		user := &users[i]
+		if !s.NeedsRotation(user) {
+			continue
+		}
+
+		secret, err := s.Open(ctx, user)
+		if err != nil {
+			return rotated, err
+		}
+
+		sealed, err := s.Seal(ctx, user.ID, secret)
+		if err != nil {
+			return rotated, err
+		}
+
+		updated, err := repository.UpdateTwoFactorSecret(ctx, user.ID, sealedTwoFactorSecret(user), sealed)
+		if err != nil {
+			return rotated, err
+		}
+		if updated {
+			rotated++
+		}
+	}
+
+	return rotated, nil
+}
+
+func sealedTwoFactorSecret(user *domain.User) envelope.Sealed {
+	return envelope.Sealed{
+		Ciphertext: user.TwoFactorSecret.String,
+		DataKey:    user.TwoFactorDataKey.String,
+		KeyVersion: user.TwoFactorKeyVersion.String,
+	}
+}
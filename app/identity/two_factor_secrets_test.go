@@ -0,0 +1,81 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/envelope"
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// racingSecretRepository hands out users and refuses the update of those in
+// changed, as if they had re-enrolled while the rotation ran.
+type racingSecretRepository struct {
+	users   []domain.User
+	changed map[string]bool
+	updated map[string]envelope.Sealed
+}
+
+func (r *racingSecretRepository) FindUsersWithTwoFactorSecret(context.Context) ([]domain.User, error) {
+	return r.users, nil
+}
+
+func (r *racingSecretRepository) UpdateTwoFactorSecret(_ context.Context, id string, _, secret envelope.Sealed) (bool, error) {
+	if r.changed[id] {
+		return false, nil
+	}
+	r.updated[id] = secret
+	return true, nil
+}
+
+func TestTwoFactorSecretsRotateCountsOnlyUpdatedRows(t *testing.T) {
+	ctx := context.Background()
+	provider, err := envelope.NewLocalKeyProvider("v2", map[string][]byte{
+		"v1": bytes.Repeat([]byte{1}, 32),
+		"v2": bytes.Repeat([]byte{2}, 32),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := NewTwoFactorSecrets(provider)
+
+	current, err := secrets.Seal(ctx, "current", "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintextUser := func(id string) domain.User {
+		return domain.User{ID: id, TwoFactorSecret: sql.NullString{String: "JBSWY3DPEHPK3PXP", Valid: true}}
+	}
+	repository := &racingSecretRepository{
+		users: []domain.User{
+			plaintextUser("plaintext"),
+			plaintextUser("raced"),
+			{
+				ID:                  "current",
+				TwoFactorSecret:     sql.NullString{String: current.Ciphertext, Valid: true},
+				TwoFactorDataKey:    sql.NullString{String: current.DataKey, Valid: true},
+				TwoFactorKeyVersion: sql.NullString{String: current.KeyVersion, Valid: true},
+			},
+		},
+		changed: map[string]bool{"raced": true},
+		updated: make(map[string]envelope.Sealed),
+	}
+
+	rotated, err := secrets.Rotate(ctx, repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated != 1 {
+		t.Fatalf("rotated %d, want 1", rotated)
+	}
+
+	sealed, ok := repository.updated["plaintext"]
+	if !ok || sealed.KeyVersion != "v2" {
+		t.Fatalf("plaintext secret not sealed under v2: %+v", repository.updated)
+	}
+	plaintext, err := envelope.Open(ctx, provider, sealed, []byte("plaintext"))
+	if err != nil || string(plaintext) != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("rotated secret opens to %q, %v", plaintext, err)
+	}
+}
@@ -0,0 +1,148 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"auction/pkg/password"
+	"context"
+	"database/sql"
+	"errors"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const maxProfileFieldLength = 255
+
+type UpdateProfileHandler struct {
+	repository        Repository
+	hasher            password.Hasher
+	otpVerifier       *OTPVerifier
+	emailVerification *EmailVerification
+}
+
+// UpdateProfileRequest only changes the fields that are present. Changing the
+// email requires the current password, and the OTP code when 2FA is enabled,
+// and takes effect once the new address is confirmed through /email/verify.
+type UpdateProfileRequest struct {
+	Name            *string `json:"name"`
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password"`
+	Code            string  `json:"code"`
+}
+
+type UpdateProfileResponse struct {
+	GetUserResponse
+}
+
+func NewUpdateProfileHandler(repository Repository, hasher password.Hasher, otpVerifier *OTPVerifier, emailVerification *EmailVerification) *UpdateProfileHandler {
+	return &UpdateProfileHandler{
+		repository:        repository,
+		hasher:            hasher,
+		otpVerifier:       otpVerifier,
+		emailVerification: emailVerification,
+	}
+}
+
+func (u UpdateProfileHandler) Handle(ctx context.Context, req *UpdateProfileRequest) (*UpdateProfileResponse, error) {
+	val := ctx.Value("UserID")
+	userID := val.(string)
+
+	user, err := u.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.NotFound("identity.update_profile.not_found", "User not found", nil)
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxProfileFieldLength {
+			return nil, httperror.BadRequest("identity.update_profile.invalid_name", "Name must be between 1 and 255 characters", nil)
+		}
+
+		if name != user.Name {
+			if err := u.repository.Update(ctx, user.ID, user.Email, name); err != nil {
+				return nil, httperror.InternalServerError("identity.update_profile.internal_server_error", "Internal server error", nil)
+			}
+			user.Name = name
+		}
+	}
+
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if !validEmail(email) {
+			return nil, httperror.BadRequest("identity.update_profile.invalid_email", "Email is invalid", nil)
+		}
+
+		if !strings.EqualFold(email, user.Email) {
+			if err := u.requestEmailChange(ctx, user, email, req.CurrentPassword, strings.TrimSpace(req.Code)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	user, err = u.repository.FindByID(ctx, user.ID)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.update_profile.internal_server_error", "Internal server error", nil)
+	}
+
+	return &UpdateProfileResponse{GetUserResponse: userProfile(user)}, nil
+}
+
+// requestEmailChange asks for the same proof as a password change: whoever
+// controls the email can reset the password, so moving it takes over the
+// account.
+func (u UpdateProfileHandler) requestEmailChange(ctx context.Context, user *domain.User, email, currentPassword, code string) error {
+	if currentPassword == "" {
+		return httperror.BadRequest("identity.update_profile.password_required", "Current password is required to change the email", nil)
+	}
+
+	_, valid, err := verifyPassword(u.hasher, user, currentPassword)
+	if err != nil || !valid {
+		return httperror.BadRequest("identity.update_profile.invalid_password", "Current password is incorrect", nil)
+	}
+
+	if user.TwoFactorEnabled && user.TwoFactorVerified {
+		if code == "" {
+			return httperror.BadRequest("identity.update_profile.code_required", "Two factor code is required to change the email", nil)
+		}
+
+		passed, err := u.otpVerifier.Verify(ctx, user, code)
+		if err != nil {
+			return httperror.InternalServerError("identity.update_profile.internal_server_error", "Internal server error", nil)
+		}
+		if !passed {
+			return httperror.BadRequest("identity.update_profile.invalid_code", "Invalid code", nil)
+		}
+	}
+
+	_, err = u.repository.FindByEmail(ctx, email)
+	if err == nil {
+		return httperror.Conflict("identity.update_profile.email_exists", "Email already exists", nil)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return httperror.InternalServerError("identity.update_profile.internal_server_error", "Internal server error", nil)
+	}
+
+	if err := u.repository.SetPendingEmail(ctx, user.ID, email); err != nil {
+		return httperror.InternalServerError("identity.update_profile.internal_server_error", "Internal server error", nil)
+	}
+
+	if err := u.emailVerification.SendChange(ctx, user, email); err != nil {
+		zap.L().Error("Failed to send email change confirmation", zap.String("user_id", user.ID), zap.Error(err))
+		return httperror.InternalServerError("identity.update_profile.send_failed", "Failed to send confirmation email", nil)
+	}
+
+	return nil
+}
+
+// validEmail accepts a bare addr-spec such as "bob@example.com".
+func validEmail(email string) bool {
+	if email == "" || len(email) > maxProfileFieldLength {
+		return false
+	}
+
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
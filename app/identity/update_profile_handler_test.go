@@ -0,0 +1,40 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/password"
+	"context"
+	"testing"
+)
+
+// Users whose password was registered trimmed confirm an email change with
+// the same input the login accepts.
+func TestUpdateProfileEmailChangeTrimmedPassword(t *testing.T) {
+	store := newMemoryStore()
+	hasher := password.NewArgon2idHasher(password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1})
+	h := NewUpdateProfileHandler(store, hasher, nil, nil)
+
+	hashed, err := hasher.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := store.addUser(domain.User{Email: "bidder@example.com", Password: hashed, PasswordTrimmed: true})
+	store.addUser(domain.User{Email: "taken@example.com"})
+	ctx := context.WithValue(context.Background(), "UserID", id)
+
+	email := "taken@example.com"
+	tests := []struct {
+		name     string
+		password string
+		want     string
+	}{
+		{"padded password", " hunter2 ", "identity.update_profile.email_exists"},
+		{"wrong password", " hunter3 ", "identity.update_profile.invalid_password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := h.Handle(ctx, &UpdateProfileRequest{Email: &email, CurrentPassword: tt.password})
+			assertHTTPError(t, err, tt.want)
+		})
+	}
+}
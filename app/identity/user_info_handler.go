@@ -0,0 +1,64 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"context"
+	"slices"
+	"strings"
+)
+
+type UserInfoHandler struct {
+	repository Repository
+}
+
+type UserInfoRequest struct {
+}
+
+// UserInfoResponse holds the OpenID Connect standard claims the token's
+// scope grants: name for profile, email and email_verified for email.
+type UserInfoResponse struct {
+	Subject       string `json:"sub"`
+	Name          string `json:"name,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+func NewUserInfoHandler(repository Repository) *UserInfoHandler {
+	return &UserInfoHandler{
+		repository: repository,
+	}
+}
+
+// Handle answers from the stored user rather than the token, so a changed
+// name or address shows up before the token is refreshed. First-party
+// tokens carry no scope and see every claim.
+func (u UserInfoHandler) Handle(ctx context.Context, _ *UserInfoRequest) (*UserInfoResponse, error) {
+	userID := ctx.Value("UserID").(string)
+	clientID, _ := ctx.Value("ClientID").(string)
+	scope, _ := ctx.Value("Scope").(string)
+
+	scopes := strings.Fields(scope)
+	if clientID == "" {
+		scopes = SupportedScopes
+	}
+	if !slices.Contains(scopes, scopeOpenID) {
+		return nil, httperror.Forbidden("identity.user_info.insufficient_scope", "Token was not issued with the openid scope", nil)
+	}
+
+	user, err := u.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, httperror.NotFound("identity.user_info.not_found", "User not found", nil)
+	}
+
+	res := &UserInfoResponse{Subject: user.ID}
+	if slices.Contains(scopes, scopeProfile) {
+		res.Name = user.Name
+	}
+	if slices.Contains(scopes, scopeEmail) {
+		verified := user.EmailVerifiedAt.Valid
+		res.Email = user.Email
+		res.EmailVerified = &verified
+	}
+
+	return res, nil
+}
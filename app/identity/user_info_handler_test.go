@@ -0,0 +1,70 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserInfoScopes(t *testing.T) {
+	store := newMemoryStore()
+	userID := store.addUser(domain.User{
+		Email:           "bidder@example.com",
+		Name:            "Bidder",
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	handler := NewUserInfoHandler(store)
+
+	tests := []struct {
+		name      string
+		clientID  string
+		scope     string
+		wantName  bool
+		wantEmail bool
+	}{
+		{"openid only", "client-1", "openid", false, false},
+		{"profile", "client-1", "openid profile", true, false},
+		{"email", "client-1", "openid email", false, true},
+		{"profile and email", "client-1", "openid profile email", true, true},
+		{"first-party", "", "", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), "UserID", userID)
+			ctx = context.WithValue(ctx, "ClientID", tt.clientID)
+			ctx = context.WithValue(ctx, "Scope", tt.scope)
+
+			res, err := handler.Handle(ctx, &UserInfoRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Subject != userID {
+				t.Errorf("sub = %q", res.Subject)
+			}
+			if got := res.Name != ""; got != tt.wantName {
+				t.Errorf("name present = %v, want %v", got, tt.wantName)
+			}
+			if got := res.Email != "" || res.EmailVerified != nil; got != tt.wantEmail {
+				t.Errorf("email present = %v, want %v", got, tt.wantEmail)
+			}
+		})
+	}
+
+	t.Run("client token without openid", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), "UserID", userID)
+		ctx = context.WithValue(ctx, "ClientID", "client-1")
+		ctx = context.WithValue(ctx, "Scope", "profile email")
+
+		_, err := handler.Handle(ctx, &UserInfoRequest{})
+		var httpErr *httperror.Error
+		if !errors.As(err, &httpErr) || httpErr.Code != "identity.user_info.insufficient_scope" {
+			t.Fatalf("got %v, want insufficient_scope", err)
+		}
+	})
+}
@@ -3,38 +3,149 @@ package identity
 import (
 	"auction/pkg/httperror"
 	"auction/pkg/jwt"
+	"auction/pkg/revocation"
 	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	errInvalidBearerToken = errors.New("identity: bearer token missing or invalid")
+	errBearerTokenRevoked = errors.New("identity: bearer token has been revoked")
 )
 
 type ValidateHandler struct {
-	repository Repository
+	revocations revocation.Store
+	policies    *ForwardAuthPolicies
+	headers     []ForwardAuthHeader
 }
 
+// ValidateHandlerRequest is a forward-auth request: the reverse proxy passes
+// the client's Authorization header along with the method and URI of the
+// request it is about to forward.
 type ValidateHandlerRequest struct {
+	Authorization   string `reqHeader:"Authorization"`
+	ForwardedMethod string `reqHeader:"X-Forwarded-Method"`
+	ForwardedURI    string `reqHeader:"X-Forwarded-Uri"`
 }
 
+// ValidateHandlerResponse carries the token's claims, or none for an
+// anonymous request to a public route. Headers are set on the response for
+// the proxy to copy onto the upstream request.
 type ValidateHandlerResponse struct {
-	Claims jwt.Claims `json:"claims"`
+	Claims  *jwt.Claims       `json:"claims,omitempty"`
+	Headers map[string]string `json:"-"`
 }
 
-func NewValidateHandler(repository Repository) *ValidateHandler {
+func NewValidateHandler(revocations revocation.Store, policies *ForwardAuthPolicies, headers []ForwardAuthHeader) *ValidateHandler {
 	return &ValidateHandler{
-		repository: repository,
+		revocations: revocations,
+		policies:    policies,
+		headers:     headers,
 	}
 }
 
-func (g ValidateHandler) Handle(ctx context.Context, _ *ValidateHandlerRequest) (*ValidateHandlerResponse, error) {
-	val := ctx.Value("Jwt")
-	jwtString := val.(string)
+func (g ValidateHandler) Handle(ctx context.Context, req *ValidateHandlerRequest) (*ValidateHandlerResponse, error) {
+	rule := g.policies.match(req.ForwardedMethod, req.ForwardedURI)
 
-	claims, err := jwt.Decode(jwtString)
+	claims, token, err := g.authenticate(ctx, req.Authorization)
+	if rule.Public && (errors.Is(err, errInvalidBearerToken) || errors.Is(err, errBearerTokenRevoked)) {
+		return &ValidateHandlerResponse{}, nil
+	}
+	if errors.Is(err, errInvalidBearerToken) {
+		return nil, httperror.Unauthorized("identity.validate.unauthorized", "Authorization token missing or invalid", nil)
+	}
+	if errors.Is(err, errBearerTokenRevoked) {
+		return nil, httperror.Unauthorized("identity.validate.revoked", "Token has been revoked", nil)
+	}
 	if err != nil {
+		zap.L().Error("Failed to check token revocation", zap.Error(err))
 		return nil, httperror.InternalServerError("identity.validate.server_error", "Internal server error", nil)
 	}
 
-	ctx = context.WithValue(ctx, "UserName", claims.Name)
+	if err := checkForwardAuthRule(rule, claims); err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(g.headers))
+	for _, header := range g.headers {
+		if value := forwardAuthValues[header.Value](claims, token); value != "" {
+			headers[header.Header] = value
+		}
+	}
 
 	return &ValidateHandlerResponse{
-		Claims: *claims,
+		Claims:  claims,
+		Headers: headers,
 	}, nil
 }
+
+// authenticate accepts the same tokens as a Bearer route that also admits
+// machine tokens.
+func (g ValidateHandler) authenticate(ctx context.Context, authorization string) (*jwt.Claims, string, error) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, "", errInvalidBearerToken
+	}
+
+	claims, err := jwt.DecodeAccessToken(token)
+	if errors.Is(err, jwt.ErrWrongTokenType) {
+		claims, err = jwt.DecodeMachineToken(token)
+	}
+	if err != nil {
+		return nil, "", errInvalidBearerToken
+	}
+
+	err = revocation.Check(ctx, g.revocations, claims.ID, claims.SessionID, claims.Subject, claims.IssuedAt.Time)
+	if errors.Is(err, revocation.ErrRevoked) {
+		return nil, "", errBearerTokenRevoked
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return claims, token, nil
+}
+
+// checkForwardAuthRule applies a rule to an authenticated token. A required
+// scope must be in the token, so first-party tokens, which carry none, never
+// satisfy a scope rule. Permissions belong to the user, so tokens issued to a
+// client never satisfy a permission rule.
+func checkForwardAuthRule(rule ForwardAuthRule, claims *jwt.Claims) error {
+	machine := claims.Type == jwt.TokenTypeMachine
+
+	if machine && (rule.UserOnly || len(rule.Permissions) > 0 || rule.SecondFactor || rule.VerifiedEmail) {
+		return httperror.Forbidden("identity.validate.machine_token", "Machine tokens cannot access this route", nil)
+	}
+
+	if claims.ClientID != "" && len(rule.Permissions) > 0 {
+		return httperror.Forbidden("identity.validate.client_token", "Tokens issued to a client cannot access this route", nil)
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	for _, scope := range rule.Scopes {
+		if !slices.Contains(scopes, scope) {
+			return httperror.Forbidden("identity.validate.insufficient_scope", "Token lacks a required scope", map[string]any{"scope": scope})
+		}
+	}
+
+	for _, permission := range rule.Permissions {
+		if !slices.Contains(claims.Permissions, permission) {
+			return httperror.Forbidden("identity.validate.missing_permission", "Missing permission "+permission, map[string]any{"permission": permission})
+		}
+	}
+
+	if rule.SecondFactor && !hasSecondFactor(claims.AMR) {
+		return httperror.Forbidden("identity.validate.second_factor_required", "Sign in with a second factor to access this route", nil)
+	}
+
+	if rule.VerifiedEmail && (claims.EmailVerified == nil || !*claims.EmailVerified) {
+		return httperror.Forbidden("identity.validate.email_not_verified", "Verify your email address to access this route", nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package identity
+
+import (
+	"auction/domain"
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/revocation"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testForwardAuthPolicies = `
+GET  /public         public
+GET  /user           user
+GET  /scope          scope=bids:write
+GET  /permission     permission=bids:create
+GET  /2fa            2fa
+GET  /verified-email verified_email
+`
+
+// TestValidateRuleMatrix runs every kind of token against every kind of
+// rule. want is the error code, or "" when the request passes.
+func TestValidateRuleMatrix(t *testing.T) {
+	useTestKeys(t)
+
+	policies, err := ParseForwardAuthPolicies(strings.NewReader(testForwardAuthPolicies))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewValidateHandler(revocation.NewMemoryStore(), policies, nil)
+
+	verified := &domain.User{
+		ID:              "6a0f3bd4-5d1e-4c55-9a7f-0d3c1e0f7b21",
+		Email:           "bidder@example.com",
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	unverified := &domain.User{ID: "0b8e4c2a-7f3d-4e1b-8a6c-5d9f2e7a1c34", Email: "new@example.com"}
+
+	token := func(user *domain.User, options jwt.AccessTokenOptions) string {
+		t.Helper()
+		token, _, err := jwt.CreateAccessToken(user, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	machineToken, _, err := jwt.CreateMachineToken("c2a1e5f4-3b7d-4a9e-8f6c-1d2e3f4a5b6c", "bids:write")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[string]string{
+		"none": "",
+		"first-party": token(verified, jwt.AccessTokenOptions{
+			AMR:         []string{jwt.AMRPassword, jwt.AMROTP},
+			Permissions: []string{"bids:create"},
+		}),
+		"first-party, password only": token(unverified, jwt.AccessTokenOptions{
+			AMR: []string{jwt.AMRPassword},
+		}),
+		"client": token(verified, jwt.AccessTokenOptions{
+			ClientID:    "7d4c3b2a-1e0f-4a9b-8c7d-6e5f4a3b2c1d",
+			Scope:       "openid bids:write",
+			AMR:         []string{jwt.AMRPassword, jwt.AMROTP},
+			Permissions: []string{"bids:create"},
+		}),
+		"machine": machineToken,
+	}
+
+	const (
+		ok              = ""
+		unauthorized    = "identity.validate.unauthorized"
+		machineRefused  = "identity.validate.machine_token"
+		clientRefused   = "identity.validate.client_token"
+		noScope         = "identity.validate.insufficient_scope"
+		noPermission    = "identity.validate.missing_permission"
+		noSecondFactor  = "identity.validate.second_factor_required"
+		emailUnverified = "identity.validate.email_not_verified"
+	)
+
+	tests := []struct {
+		path string
+		want map[string]string
+	}{
+		{"/public", map[string]string{
+			"none": ok, "first-party": ok, "first-party, password only": ok, "client": ok, "machine": ok,
+		}},
+		{"/user", map[string]string{
+			"none": unauthorized, "first-party": ok, "first-party, password only": ok, "client": ok, "machine": machineRefused,
+		}},
+		{"/scope", map[string]string{
+			"none": unauthorized, "first-party": noScope, "first-party, password only": noScope, "client": ok, "machine": ok,
+		}},
+		{"/permission", map[string]string{
+			"none": unauthorized, "first-party": ok, "first-party, password only": noPermission, "client": clientRefused, "machine": machineRefused,
+		}},
+		{"/2fa", map[string]string{
+			"none": unauthorized, "first-party": ok, "first-party, password only": noSecondFactor, "client": ok, "machine": machineRefused,
+		}},
+		{"/verified-email", map[string]string{
+			"none": unauthorized, "first-party": ok, "first-party, password only": emailUnverified, "client": ok, "machine": machineRefused,
+		}},
+	}
+
+	for _, tt := range tests {
+		for name, want := range tt.want {
+			t.Run(strings.TrimPrefix(tt.path, "/")+"/"+name, func(t *testing.T) {
+				req := &ValidateHandlerRequest{ForwardedMethod: "GET", ForwardedURI: tt.path}
+				if tokens[name] != "" {
+					req.Authorization = "Bearer " + tokens[name]
+				}
+
+				_, err := handler.Handle(context.Background(), req)
+				if want == ok {
+					if err != nil {
+						t.Fatalf("refused: %v", err)
+					}
+					return
+				}
+
+				var httpErr *httperror.Error
+				if !errors.As(err, &httpErr) || httpErr.Code != want {
+					t.Fatalf("got %v, want %s", err, want)
+				}
+			})
+		}
+	}
+}
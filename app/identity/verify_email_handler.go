@@ -0,0 +1,84 @@
+package identity
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type VerifyEmailHandler struct {
+	repository        Repository
+	emailVerification *EmailVerification
+}
+
+// VerifyEmailRequest takes the token from either the registration mail or the
+// mail sent to a new address requested through PATCH /me.
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type VerifyEmailResponse struct {
+}
+
+func NewVerifyEmailHandler(repository Repository, emailVerification *EmailVerification) *VerifyEmailHandler {
+	return &VerifyEmailHandler{
+		repository:        repository,
+		emailVerification: emailVerification,
+	}
+}
+
+func (v VerifyEmailHandler) Handle(ctx context.Context, req *VerifyEmailRequest) (*VerifyEmailResponse, error) {
+	req.Token = strings.TrimSpace(req.Token)
+
+	if claims, err := jwt.DecodeEmailChangeToken(req.Token); err == nil {
+		return v.confirmChange(ctx, claims)
+	}
+
+	claims, err := jwt.DecodeEmailVerificationToken(req.Token)
+	if err != nil {
+		return nil, httperror.BadRequest("identity.verify_email.invalid_token", "Verification link is invalid or expired", nil)
+	}
+
+	// The token names the address it was mailed to; it is rejected once the
+	// user's email has changed.
+	verified, err := v.repository.MarkEmailVerified(ctx, claims.Subject, claims.Email)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.verify_email.internal_server_error", "Internal server error", nil)
+	}
+	if !verified {
+		return nil, httperror.BadRequest("identity.verify_email.invalid_token", "Verification link is invalid or expired", nil)
+	}
+
+	return nil, httperror.NoContent("identity.verify_email.no_content", "No content", nil)
+}
+
+// confirmChange switches the user to the pending address named by the token.
+// A newer PATCH /me replaces the pending address and so voids older links.
+func (v VerifyEmailHandler) confirmChange(ctx context.Context, claims *jwt.Claims) (*VerifyEmailResponse, error) {
+	user, err := v.repository.FindByID(ctx, claims.Subject)
+	if err != nil {
+		return nil, httperror.BadRequest("identity.verify_email.invalid_token", "Verification link is invalid or expired", nil)
+	}
+
+	confirmed, err := v.repository.ConfirmPendingEmail(ctx, user.ID, claims.Email)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, httperror.Conflict("identity.verify_email.email_exists", "Email already exists", nil)
+		}
+		return nil, httperror.InternalServerError("identity.verify_email.internal_server_error", "Internal server error", nil)
+	}
+	if !confirmed {
+		return nil, httperror.BadRequest("identity.verify_email.invalid_token", "Verification link is invalid or expired", nil)
+	}
+
+	previousEmail := user.Email
+	user.Email = claims.Email
+	if err := v.emailVerification.NotifyChanged(ctx, user, previousEmail); err != nil {
+		zap.L().Warn("Failed to notify previous email address", zap.String("user_id", user.ID), zap.Error(err))
+	}
+
+	return nil, httperror.NoContent("identity.verify_email.no_content", "No content", nil)
+}
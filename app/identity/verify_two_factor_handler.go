@@ -2,14 +2,14 @@ package identity
 
 import (
 	"auction/pkg/httperror"
-	"auction/pkg/totp"
 	"context"
-	"encoding/json"
 	"strings"
 )
 
 type VerifyTwoFactorHandler struct {
-	repository Repository
+	repository             Repository
+	recoveryCodeRepository RecoveryCodeRepository
+	otpVerifier            *OTPVerifier
 }
 
 type VerifyTwoFactorRequest struct {
@@ -20,9 +20,11 @@ type VerifyTwoFactorResponse struct {
 	RecoveryCodes []string `json:"recovery_codes"`
 }
 
-func NewVerifyTwoFactorHandler(repository Repository) *VerifyTwoFactorHandler {
+func NewVerifyTwoFactorHandler(repository Repository, recoveryCodeRepository RecoveryCodeRepository, otpVerifier *OTPVerifier) *VerifyTwoFactorHandler {
 	return &VerifyTwoFactorHandler{
-		repository: repository,
+		repository:             repository,
+		recoveryCodeRepository: recoveryCodeRepository,
+		otpVerifier:            otpVerifier,
 	}
 }
 
@@ -41,7 +43,10 @@ func (v VerifyTwoFactorHandler) Handle(ctx context.Context, req *VerifyTwoFactor
 		return nil, httperror.BadRequest("identity.verify_two_factor.invalid_code", "Two factor not enabled", nil)
 	}
 
-	passed := totp.VerifyOTP(user.TwoFactorSecret.String, req.Code, 0, 0, 0)
+	passed, err := v.otpVerifier.Verify(ctx, user, req.Code)
+	if err != nil {
+		return nil, httperror.InternalServerError("identity.verify_two_factor.server_error", "Internal server error", nil)
+	}
 	if !passed {
 		return nil, httperror.BadRequest("identity.verify_two_factor.invalid_code", "Invalid code", nil)
 	}
@@ -51,13 +56,7 @@ func (v VerifyTwoFactorHandler) Handle(ctx context.Context, req *VerifyTwoFactor
 		return nil, httperror.InternalServerError("identity.verify_two_factor.server_error", "Internal server error", nil)
 	}
 
-	recoveryCodes := totp.GenerateRecoveryCodes(10)
-	rawRecoveryCodes, err := json.Marshal(recoveryCodes)
-	if err != nil {
-		return nil, httperror.InternalServerError("identity.verify_two_factor.server_error", "Internal server error", nil)
-	}
-
-	err = v.repository.SetRecoveryCodes(ctx, user.ID, string(rawRecoveryCodes))
+	recoveryCodes, err := issueRecoveryCodes(ctx, v.recoveryCodeRepository, user.ID)
 	if err != nil {
 		return nil, httperror.InternalServerError("identity.verify_two_factor.server_error", "Internal server error", nil)
 	}
@@ -65,4 +64,4 @@ func (v VerifyTwoFactorHandler) Handle(ctx context.Context, req *VerifyTwoFactor
 	return &VerifyTwoFactorResponse{
 		RecoveryCodes: recoveryCodes,
 	}, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"auction/app/identity"
+	"auction/domain"
+	"auction/infra/postgres"
+	"auction/pkg/breach"
+	"auction/pkg/config"
+	"auction/pkg/envelope"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, appConfig *config.AppConfig, args []string) error
+}
+
+var commands = map[string]command{
+	"build-breach-index": {
+		usage: "compile HIBP SHA-1 hash lists into BREACHED_PASSWORDS_FILE",
+		run:   buildBreachIndex,
+	},
+	"create-client": {
+		usage: "register an OpenID Connect client and print its id and secret",
+		run:   createClient,
+	},
+	"list-clients": {
+		usage: "list the registered OpenID Connect clients",
+		run:   listClients,
+	},
+	"delete-client": {
+		usage: "remove OpenID Connect clients and end their sessions",
+		run:   deleteClients,
+	},
+	"list-roles": {
+		usage: "list the roles and their permissions, or those of a user (-user)",
+		run:   listRoles,
+	},
+	"grant-role": {
+		usage: "give an account (by email) one or more roles",
+		run:   grantRoles,
+	},
+	"revoke-role": {
+		usage: "take roles away from an account (by email) and revoke its access tokens",
+		run:   revokeRoles,
+	},
+	"unlock-account": {
+		usage: "lift the login lockout of an account (by email) or a client IP (-ip)",
+		run:   unlockAccount,
+	},
+	"disable-account": {
+		usage: "stop accounts (by email) from signing in and end their sessions",
+		run:   disableAccounts,
+	},
+	"enable-account": {
+		usage: "let disabled accounts (by email) sign in again",
+		run:   enableAccounts,
+	},
+	"reencrypt-secrets": {
+		usage: "re-encrypt stored OTP secrets under the current master key",
+		run:   reencryptSecrets,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(context.Background(), config.Read(), os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: identityctl <command> [args]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].usage)
+	}
+}
+
+func newRepository(appConfig *config.AppConfig) *postgres.PgRepository {
+	return postgres.NewPgRepository(
+		appConfig.PostgresHost,
+		appConfig.PostgresDatabase,
+		appConfig.PostgresUsername,
+		appConfig.PostgresPassword,
+		appConfig.PostgresPort,
+	)
+}
+
+// reencryptSecrets encrypts legacy plaintext OTP secrets and moves secrets
+// wrapped by an older master key to SECRET_MASTER_KEY_VERSION. Keep the old
+// key configured until this has completed.
+func reencryptSecrets(ctx context.Context, appConfig *config.AppConfig, _ []string) error {
+	masterKeys, err := envelope.LoadLocalKeyProvider(appConfig.SecretMasterKeyVersion, appConfig.SecretMasterKeys, appConfig.SecretMasterKeysFile)
+	if err != nil {
+		return err
+	}
+
+	repository := newRepository(appConfig)
+	defer repository.Close()
+
+	rotated, err := identity.NewTwoFactorSecrets(masterKeys).Rotate(ctx, repository)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("re-encrypted %d secret(s) under master key %q\n", rotated, masterKeys.CurrentVersion())
+	return nil
+}
+
+// buildBreachIndex compiles a sorted HIBP SHA-1 corpus into the index read by
+// the password policy. The input is either the full "HASH:COUNT" list or a
+// directory of range files named after their five-character prefix. The
+// index is written next to the target and renamed into place when complete.
+func buildBreachIndex(_ context.Context, appConfig *config.AppConfig, args []string) error {
+	flags := flag.NewFlagSet("build-breach-index", flag.ContinueOnError)
+	output := flags.String("out", appConfig.BreachedPasswordsFile, "index file to write")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: identityctl build-breach-index [-out index.bin] <hash-list.txt|range-dir>...")
+	}
+	if *output == "" {
+		return errors.New("no output file; pass -out or set BREACHED_PASSWORDS_FILE")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(*output), ".breach-index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	writer, err := breach.NewWriter(tmp)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range flags.Args() {
+		if err := addBreachSource(writer, source); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), *output); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %d hash(es) to %s\n", writer.Written(), *output)
+	return nil
+}
+
+func addBreachSource(writer *breach.Writer, source string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return writer.AddRangeDir(source)
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := writer.AddHashList(file); err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	return nil
+}
+
+// unlockAccount clears the recorded login failures of accounts given by
+// email, or of client IPs with -ip. Account unlocks are audited.
+func unlockAccount(ctx context.Context, appConfig *config.AppConfig, args []string) error {
+	flags := flag.NewFlagSet("unlock-account", flag.ContinueOnError)
+	byIP := flags.Bool("ip", false, "arguments are client IP addresses instead of emails")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: identityctl unlock-account [-ip] <email|ip>...")
+	}
+
+	repository := newRepository(appConfig)
+	defer repository.Close()
+
+	lockout := identity.NewLockout(repository, repository, identity.LockoutSettings{})
+	ctx = context.WithValue(ctx, "UserAgent", "identityctl")
+
+	for _, subject := range flags.Args() {
+		var cleared bool
+		if *byIP {
+			var err error
+			if cleared, err = lockout.UnlockIP(ctx, subject); err != nil {
+				return err
+			}
+		} else {
+			user, err := repository.FindByEmail(ctx, subject)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("no account with email %q", subject)
+			}
+			if err != nil {
+				return err
+			}
+
+			if cleared, err = lockout.Unlock(ctx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		if cleared {
+			fmt.Printf("unlocked %s\n", subject)
+		} else {
+			fmt.Printf("%s had no recorded failures\n", subject)
+		}
+	}
+
+	return nil
+}
+
+// disableAccounts disables accounts given by email and revokes their tokens.
+func disableAccounts(ctx context.Context, appConfig *config.AppConfig, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: identityctl disable-account <email>...")
+	}
+
+	return changeAccounts(ctx, appConfig, args, func(accounts *identity.Accounts, userID string) (string, error) {
+		disabled, err := accounts.Disable(ctx, userID)
+		if err != nil || !disabled {
+			return "already disabled", err
+		}
+		return "disabled", nil
+	})
+}
+
+// enableAccounts re-enables accounts given by email.
+func enableAccounts(ctx context.Context, appConfig *config.AppConfig, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: identityctl enable-account <email>...")
+	}
+
+	return changeAccounts(ctx, appConfig, args, func(accounts *identity.Accounts, userID string) (string, error) {
+		enabled, err := accounts.Enable(ctx, userID)
+		if err != nil || !enabled {
+			return "not disabled", err
+		}
+		return "enabled", nil
+	})
+}
+
+func changeAccounts(ctx context.Context, appConfig *config.AppConfig, emails []string, change func(accounts *identity.Accounts, userID string) (string, error)) error {
+	repository := newRepository(appConfig)
+	defer repository.Close()
+
+	accounts := identity.NewAccounts(repository, repository, repository, repository)
+	ctx = context.WithValue(ctx, "UserAgent", "identityctl")
+
+	for _, email := range emails {
+		user, err := findUserByEmail(ctx, repository, email)
+		if err != nil {
+			return err
+		}
+
+		outcome, err := change(accounts, user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s\n", email, outcome)
+	}
+	return nil
+}
+
+// listRoles prints every role, or with -user the roles of one account.
+func listRoles(ctx context.Context, appConfig *config.AppConfig, args []string) error {
+	flags := flag.NewFlagSet("list-roles", flag.ContinueOnError)
+	email := flags.String("user", "", "email of the account whose roles to list")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	repository := newRepository(appConfig)
+	defer repository.Close()
+
+	var roles []domain.Role
+	if *email != "" {
+		user, err := findUserByEmail(ctx, repository, *email)
+		if err != nil {
+			return err
+		}
+		if roles, err = repository.ListUserRoles(ctx, user.ID); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if roles, err = repository.ListRoles(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, role := range roles {
+		name := role.Name
+		if role.IsDefault {
+			name += " (default)"
+		}
+		fmt.Printf("%-20s  %s\n", name, role.Description)
+		fmt.Printf("    permissions: %s\n", role.Permissions)
+	}
+	return nil
+}
+
+// grantRoles gives an account roles. They show up in its tokens on the next
+// refresh.
+func grantRoles(ctx context.Context, appConfig *config.AppConfig, args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: identityctl grant-role <email> <role>...")
+	}
+
+	return changeRoles(ctx, appConfig, args[0], args[1:], func(roles *identity.Roles, userID, role string) (string, error) {
+		granted, err := roles.Grant(ctx, userID, role, "")
+		if err != nil || !granted {
+			return "already held", err
+		}
+		return "granted", nil
+	})
+}
+
+// revokeRoles takes roles away from an account. Its access tokens are
+// revoked so the permissions stop applying before they expire.
+func revokeRoles(ctx context.Context, appConfig *config.AppConfig, args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: identityctl revoke-role <email> <role>...")
+	}
+
+	return changeRoles(ctx, appConfig, args[0], args[1:], func(roles *identity.Roles, userID, role string) (string, error) {
+		revoked, err := roles.Revoke(ctx, userID, role, "")
+		if err != nil || !revoked {
+			return "not held", err
+		}
+		return "revoked", nil
+	})
+}
+
+func changeRoles(ctx context.Context, appConfig *config.AppConfig, email string, names []string, change func(roles *identity.Roles, userID, role string) (string, error)) error {
+	repository := newRepository(appConfig)
+	defer repository.Close()
+
+	user, err := findUserByEmail(ctx, repository, email)
+	if err != nil {
+		return err
+	}
+
+	roles := identity.NewRoles(repository, repository, repository, repository)
+	ctx = context.WithValue(ctx, "UserAgent", "identityctl")
+
+	for _, name := range names {
+		if _, err := repository.FindRole(ctx, name); errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no role named %q", name)
+		} else if err != nil {
+			return err
+		}
+
+		outcome, err := change(roles, user.ID, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s  %s: %s\n", email, name, outcome)
+	}
+	return nil
+}
+
+func findUserByEmail(ctx context.Context, repository *postgres.PgRepository, email string) (*domain.User, error) {
+	user, err := repository.FindByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no account with email %q", email)
+	}
+	return user, err
+}
+
+// createClient registers an OpenID Connect client, or with -service a
+// client for the client_credentials grant. The secret is printed once; only
+// its hash is stored.
+func createClient(ctx context.Context, appConfig *config.AppConfig, args []string) error {
+	flags := flag.NewFlagSet("create-client", flag.ContinueOnError)
+	public := flags.Bool("public", false, "browser or mobile app without a client secret")
+	service := flags.Bool("service", false, "service authenticating as itself with client_credentials")
+	scopes := flags.String("scopes", "", `space-separated scopes the client may request (default "`+strings.Join(identity.SupportedScopes, " ")+`")`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var minArgs int
+	if *service {
+		minArgs = 1
+	} else {
+		minArgs = 2
+	}
+	if flags.NArg() < minArgs || (*service && (*public || flags.NArg() > 1)) {
+		return errors.New(`usage: identityctl create-client [-public] [-scopes "openid profile email"] <name> <redirect-uri>...
+       identityctl create-client -service -scopes "bids:read bids:write" <name>`)
+	}
+
+	repository := newRepository(appConfig)
+	defer repository.Close()
+
+	clients := identity.NewOAuthClients(repository)
+
+	var client *domain.OAuthClient
+	var secret string
+	var err error
+	if *service {
+		client, secret, err = clients.RegisterService(ctx, flags.Arg(0), strings.Fields(*scopes))
+	} else {
+		client, secret, err = clients.Register(ctx, flags.Arg(0), flags.Args()[1:], strings.Fields(*scopes), *public)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("client_id:     %s\n", client.ID)
+	if secret != "" {
+		fmt.Printf("client_secret: %s\n", secret)
+		fmt.Println("store the secret now, it cannot be shown again")
+	}
+	return nil
+}
+
+func listClients(ctx context.Context, appConfig *config.AppConfig, _ []string) error {
+	repository := newRepository(appConfig)
+	defer repository.Close()
+
+	clients, err := repository.ListOAuthClients(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, client := range clients {
+		kind := "confidential"
+		if client.IsPublic() {
+			kind = "public"
+		} else if client.AllowsGrantType("client_credentials") {
+			kind = "service"
+		}
+		fmt.Printf("%s  %-12s  %s\n", client.ID, kind, client.Name)
+		fmt.Printf("    scopes:        %s\n", client.Scopes)
+		if client.RedirectURIs != "" {
+			fmt.Printf("    redirect URIs: %s\n", client.RedirectURIs)
+		}
+	}
+	return nil
+}
+
+// deleteClients removes clients by id. Their authorization codes and refresh
+// tokens go with them, so the sessions they started cannot be refreshed.
+func deleteClients(ctx context.Context, appConfig *config.AppConfig, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: identityctl delete-client <client-id>...")
+	}
+
+	repository := newRepository(appConfig)
+	defer repository.Close()
+
+	clients := identity.NewOAuthClients(repository)
+	for _, id := range args {
+		if _, err := clients.Find(ctx, id); errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no client with id %q", id)
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := repository.DeleteOAuthClient(ctx, id); err != nil {
+			return err
+		}
+		fmt.Printf("deleted %s\n", id)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+)
+
+type AuditEvent struct {
+	ID        string         `json:"id" db:"id"`
+	UserID    sql.NullString `json:"user_id" db:"user_id"`
+	Event     string         `json:"event" db:"event"`
+	IPAddress sql.NullString `json:"ip_address" db:"ip_address"`
+	UserAgent sql.NullString `json:"user_agent" db:"user_agent"`
+	Metadata  []byte         `json:"metadata" db:"metadata"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type AuthorizationCode struct {
+	CodeHash      string       `json:"-" db:"code_hash"`
+	ClientID      string       `json:"client_id" db:"client_id"`
+	UserID        string       `json:"user_id" db:"user_id"`
+	SessionID     string       `json:"session_id" db:"session_id"`
+	RedirectURI   string       `json:"redirect_uri" db:"redirect_uri"`
+	Scope         string       `json:"scope" db:"scope"`
+	Nonce         string       `json:"nonce" db:"nonce"`
+	CodeChallenge string       `json:"-" db:"code_challenge"`
+	AMR           string       `json:"amr" db:"amr"`
+	AuthTime      time.Time    `json:"auth_time" db:"auth_time"`
+	ExpiresAt     time.Time    `json:"expires_at" db:"expires_at"`
+	UsedAt        sql.NullTime `json:"used_at" db:"used_at"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+}
+
+func (c *AuthorizationCode) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+// Methods returns the authentication methods of the sign-in the code was
+// issued for.
+func (c *AuthorizationCode) Methods() []string {
+	return strings.Fields(c.AMR)
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Credential is a registered WebAuthn public key credential. Data is the
+// JSON-encoded webauthn.Credential and is only interpreted by app/identity.
+type Credential struct {
+	ID           string       `json:"id" db:"id"`
+	UserID       string       `json:"user_id" db:"user_id"`
+	CredentialID []byte       `json:"-" db:"credential_id"`
+	Name         string       `json:"name" db:"name"`
+	Data         []byte       `json:"-" db:"data"`
+	CreatedAt    time.Time    `json:"created_at" db:"created_at"`
+	LastUsedAt   sql.NullTime `json:"last_used_at" db:"last_used_at"`
+}
+
+// WebAuthnSession is the server side state of a registration or assertion
+// ceremony between its begin and finish calls.
+type WebAuthnSession struct {
+	ID        string         `json:"id" db:"id"`
+	UserID    sql.NullString `json:"user_id" db:"user_id"`
+	Purpose   string         `json:"purpose" db:"purpose"`
+	Data      []byte         `json:"-" db:"data"`
+	ExpiresAt time.Time      `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
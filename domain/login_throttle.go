@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+)
+
+type LoginThrottle struct {
+	Scope         string       `json:"scope" db:"scope"`
+	Subject       string       `json:"subject" db:"subject"`
+	Failures      int          `json:"failures" db:"failures"`
+	LastFailureAt time.Time    `json:"last_failure_at" db:"last_failure_at"`
+	LockedUntil   sql.NullTime `json:"locked_until" db:"locked_until"`
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type OAuthClient struct {
+	ID           string         `json:"id" db:"id"`
+	Name         string         `json:"name" db:"name"`
+	SecretHash   sql.NullString `json:"-" db:"secret_hash"`
+	RedirectURIs string         `json:"redirect_uris" db:"redirect_uris"`
+	Scopes       string         `json:"scopes" db:"scopes"`
+	GrantTypes   string         `json:"grant_types" db:"grant_types"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+}
+
+// IsPublic reports whether the client has no secret, as browser and mobile
+// apps cannot keep one.
+func (c *OAuthClient) IsPublic() bool {
+	return !c.SecretHash.Valid
+}
+
+// AllowsRedirectURI reports whether uri exactly equals a registered redirect
+// URI.
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	for _, registered := range strings.Fields(c.RedirectURIs) {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsGrantType reports whether the client may use grantType at the token
+// endpoint.
+func (c *OAuthClient) AllowsGrantType(grantType string) bool {
+	for _, allowed := range strings.Fields(c.GrantTypes) {
+		if allowed == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScope reports whether the client may request scope.
+func (c *OAuthClient) AllowsScope(scope string) bool {
+	for _, allowed := range strings.Fields(c.Scopes) {
+		if allowed == scope {
+			return true
+		}
+	}
+	return false
+}
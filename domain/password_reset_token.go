@@ -0,0 +1,15 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+)
+
+type PasswordResetToken struct {
+	ID        string       `json:"id" db:"id"`
+	UserID    string       `json:"user_id" db:"user_id"`
+	TokenHash string       `json:"-" db:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at" db:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at" db:"used_at"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
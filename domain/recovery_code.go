@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+)
+
+type RecoveryCode struct {
+	ID        string       `json:"id" db:"id"`
+	UserID    string       `json:"user_id" db:"user_id"`
+	CodeHash  string       `json:"-" db:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at" db:"used_at"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
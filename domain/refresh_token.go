@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type RefreshToken struct {
+	ID        string         `json:"id" db:"id"`
+	UserID    string         `json:"user_id" db:"user_id"`
+	FamilyID  string         `json:"family_id" db:"family_id"`
+	TokenHash string         `json:"-" db:"token_hash"`
+	AMR       string         `json:"amr" db:"amr"`
+	ClientID  sql.NullString `json:"client_id" db:"client_id"`
+	Scope     string         `json:"scope" db:"scope"`
+	ExpiresAt time.Time      `json:"expires_at" db:"expires_at"`
+	UsedAt    sql.NullTime   `json:"used_at" db:"used_at"`
+	RevokedAt sql.NullTime   `json:"revoked_at" db:"revoked_at"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
+
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// Methods returns the authentication methods completed when the family was
+// started, so rotated access tokens keep the same amr claim.
+func (t *RefreshToken) Methods() []string {
+	return strings.Fields(t.AMR)
+}
@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Role bundles permissions. Permissions holds the names of the role's
+// permissions, space-separated.
+type Role struct {
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	IsDefault   bool      `json:"is_default" db:"is_default"`
+	Permissions string    `json:"permissions" db:"permissions"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+func (r *Role) PermissionNames() []string {
+	return strings.Fields(r.Permissions)
+}
@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Session struct {
+	ID         string         `json:"id" db:"id"`
+	UserID     string         `json:"user_id" db:"user_id"`
+	JTI        string         `json:"jti" db:"jti"`
+	IPAddress  sql.NullString `json:"ip_address" db:"ip_address"`
+	UserAgent  sql.NullString `json:"user_agent" db:"user_agent"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time      `json:"last_seen_at" db:"last_seen_at"`
+}
@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+)
+
+type TrustedDevice struct {
+	ID         string         `json:"id" db:"id"`
+	UserID     string         `json:"user_id" db:"user_id"`
+	TokenHash  string         `json:"-" db:"token_hash"`
+	IPAddress  sql.NullString `json:"ip_address" db:"ip_address"`
+	UserAgent  sql.NullString `json:"user_agent" db:"user_agent"`
+	ExpiresAt  time.Time      `json:"expires_at" db:"expires_at"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	LastUsedAt sql.NullTime   `json:"last_used_at" db:"last_used_at"`
+}
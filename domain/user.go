@@ -1,26 +1,54 @@
 package domain
 
 import (
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"time"
 )
 
 type User struct {
-	ID                     string         `json:"id" db:"id"`
-	Email                  string         `json:"email" db:"email"`
-	Password               string         `json:"password" db:"password"`
-	Name                   string         `json:"name" db:"name"`
-	TwoFactorSecret        sql.NullString `json:"two_factor_secret" db:"two_factor_secret"`
-	TwoFactorVerified      bool           `json:"two_factor_verified" db:"two_factor_verified"`
-	TwoFactorEnabled       bool           `json:"two_factor_enabled" db:"two_factor_enabled"`
-	TwoFactorRecoveryCodes sql.NullString `json:"two_factor_recovery_codes" db:"two_factor_recovery_codes"`
-	CreatedAt              time.Time      `json:"created_at" db:"created_at"`
-	UpdatedAt              time.Time      `json:"updated_at" db:"updated_at"`
+	ID                   string         `json:"id" db:"id"`
+	Email                string         `json:"email" db:"email"`
+	Password             string         `json:"password" db:"password"`
+	PasswordTrimmed      bool           `json:"-" db:"password_trimmed"`
+	Name                 string         `json:"name" db:"name"`
+	EmailVerifiedAt      sql.NullTime   `json:"email_verified_at" db:"email_verified_at"`
+	PendingEmail         sql.NullString `json:"pending_email" db:"pending_email"`
+	TwoFactorSecret      sql.NullString `json:"-" db:"two_factor_secret"`
+	TwoFactorDataKey     sql.NullString `json:"-" db:"two_factor_data_key"`
+	TwoFactorKeyVersion  sql.NullString `json:"-" db:"two_factor_key_version"`
+	TwoFactorVerified    bool           `json:"two_factor_verified" db:"two_factor_verified"`
+	TwoFactorEnabled     bool           `json:"two_factor_enabled" db:"two_factor_enabled"`
+	TwoFactorLastCounter sql.NullInt64  `json:"-" db:"two_factor_last_counter"`
+	TwoFactorType        string         `json:"two_factor_type" db:"two_factor_type"`
+	TwoFactorAlgorithm   string         `json:"two_factor_algorithm" db:"two_factor_algorithm"`
+	TwoFactorDigits      int            `json:"two_factor_digits" db:"two_factor_digits"`
+	TwoFactorPeriod      int            `json:"two_factor_period" db:"two_factor_period"`
+	PasskeySecondFactor  bool           `json:"passkey_second_factor" db:"passkey_second_factor"`
+	TokensRevokedBefore  sql.NullTime   `json:"-" db:"tokens_revoked_before"`
+	DisabledAt           sql.NullTime   `json:"-" db:"disabled_at"`
+	CreatedAt            time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time      `json:"updated_at" db:"updated_at"`
 }
 
-func (u *User) ValidatePassword(password string) bool {
-	hash := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(hash[:]) == u.Password
-}
\ No newline at end of file
+// IsDisabled reports whether an operator has disabled the account.
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt.Valid
+}
+
+// TwoFactorConfig is the per-user OTP configuration stored next to the secret.
+// Period only applies to time-based (totp) codes.
+type TwoFactorConfig struct {
+	Type      string
+	Algorithm string
+	Digits    int
+	Period    int
+}
+
+func (u *User) TwoFactorConfig() TwoFactorConfig {
+	return TwoFactorConfig{
+		Type:      u.TwoFactorType,
+		Algorithm: u.TwoFactorAlgorithm,
+		Digits:    u.TwoFactorDigits,
+		Period:    u.TwoFactorPeriod,
+	}
+}
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
+
+-- Carry over codes that were stored as a plaintext JSON array before hashing.
+INSERT INTO recovery_codes (user_id, code_hash)
+SELECT u.id, encode(sha256(convert_to(code, 'UTF8')), 'hex')
+FROM users u, json_array_elements_text(u.two_factor_recovery_codes::json) AS code
+WHERE u.two_factor_recovery_codes IS NOT NULL AND u.two_factor_recovery_codes <> ''
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN IF EXISTS two_factor_recovery_codes;
@@ -0,0 +1,6 @@
+ALTER TABLE users ALTER COLUMN two_factor_secret TYPE VARCHAR(128);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_type VARCHAR(8) NOT NULL DEFAULT 'totp';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_algorithm VARCHAR(8) NOT NULL DEFAULT 'SHA1';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_digits SMALLINT NOT NULL DEFAULT 6;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_period SMALLINT NOT NULL DEFAULT 30;
@@ -0,0 +1,6 @@
+-- two_factor_secret now holds base64 AES-GCM ciphertext. Rows without a key
+-- version are legacy plaintext until `identityctl reencrypt-secrets` runs.
+ALTER TABLE users ALTER COLUMN two_factor_secret TYPE TEXT;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_data_key TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_key_version VARCHAR(32);
@@ -0,0 +1,24 @@
+-- WebAuthn credentials (passkeys and security keys). data holds the
+-- credential as serialised by go-webauthn, including the public key and the
+-- signature counter; credential_id is duplicated for lookups.
+CREATE TABLE IF NOT EXISTS credentials (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    credential_id BYTEA UNIQUE NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_credentials_user_id ON credentials (user_id);
+
+-- Challenges issued by a begin call, consumed once by the matching finish call.
+CREATE TABLE IF NOT EXISTS webauthn_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users (id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    data JSONB NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+-- NULL until the user follows the link mailed at registration.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+-- New address requested through PATCH /me. It replaces email only once the
+-- link mailed to it is followed; until then the old address stays active.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email VARCHAR(255);
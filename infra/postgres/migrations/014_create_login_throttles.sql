@@ -0,0 +1,12 @@
+-- Failed sign-in attempts per account (subject = user id) and per client IP.
+-- locked_until holds both the backoff delay and the temporary lockout.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope VARCHAR(16) NOT NULL,
+    subject VARCHAR(64) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (scope, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failure_at ON login_throttles (last_failure_at);
@@ -0,0 +1,14 @@
+-- Devices a user chose to remember after a 2FA challenge. The id is the jti
+-- of the signed device token, of which only the SHA-256 is stored.
+CREATE TABLE IF NOT EXISTS trusted_devices (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_trusted_devices_user_id ON trusted_devices (user_id);
@@ -0,0 +1,11 @@
+-- Applications that sign users in through the OpenID Connect endpoints.
+-- Public clients (browser and mobile apps) have no secret and rely on PKCE.
+-- redirect_uris and scopes are space-separated lists.
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    secret_hash CHAR(64),
+    redirect_uris TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT 'openid profile email',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
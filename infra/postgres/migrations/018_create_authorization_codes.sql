@@ -0,0 +1,17 @@
+-- Authorization codes are stored hashed and kept after use until they
+-- expire, so a replayed code can end the session it started.
+CREATE TABLE IF NOT EXISTS authorization_codes (
+    code_hash CHAR(64) PRIMARY KEY,
+    client_id UUID NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    session_id UUID NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL,
+    amr TEXT NOT NULL,
+    auth_time TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
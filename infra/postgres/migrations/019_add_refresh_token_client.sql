@@ -0,0 +1,4 @@
+-- Refresh tokens issued to an OIDC client can only be redeemed by that
+-- client, and keep the scope the user authorized.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS client_id UUID REFERENCES oauth_clients (id) ON DELETE CASCADE;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Service clients use the client_credentials grant instead of signing users
+-- in. grant_types is a space-separated list.
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS grant_types TEXT NOT NULL DEFAULT 'authorization_code refresh_token';
@@ -0,0 +1,67 @@
+-- Role-based access control. Roles bundle permissions; users hold roles.
+-- Access tokens carry the resolved roles and permissions, so changes apply
+-- once the user's tokens are refreshed. New users get every default role.
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    is_default BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(128) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(64) NOT NULL REFERENCES roles (name) ON DELETE CASCADE ON UPDATE CASCADE,
+    permission VARCHAR(128) NOT NULL REFERENCES permissions (name) ON DELETE CASCADE ON UPDATE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR(64) NOT NULL REFERENCES roles (name) ON DELETE CASCADE ON UPDATE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role ON user_roles (role);
+
+INSERT INTO roles (name, description, is_default) VALUES
+    ('bidder', 'Places bids on auctions', true),
+    ('seller', 'Lists items for auction', false),
+    ('moderator', 'Reviews auctions and bids and suspends listings', false),
+    ('admin', 'Manages users and their roles', false)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('bids:read', 'View own bids'),
+    ('bids:create', 'Place bids'),
+    ('auctions:create', 'Create auctions'),
+    ('auctions:update', 'Edit and close own auctions'),
+    ('auctions:moderate', 'Suspend or remove any auction'),
+    ('bids:moderate', 'Cancel any bid'),
+    ('users:read', 'View user accounts'),
+    ('roles:assign', 'Grant and revoke user roles')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('bidder', 'bids:read'),
+    ('bidder', 'bids:create'),
+    ('seller', 'auctions:create'),
+    ('seller', 'auctions:update'),
+    ('moderator', 'auctions:moderate'),
+    ('moderator', 'bids:moderate'),
+    ('moderator', 'users:read'),
+    ('admin', 'auctions:moderate'),
+    ('admin', 'bids:moderate'),
+    ('admin', 'users:read'),
+    ('admin', 'roles:assign')
+ON CONFLICT DO NOTHING;
+
+-- Existing accounts were all able to bid.
+INSERT INTO user_roles (user_id, role)
+SELECT id, 'bidder' FROM users
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,7 @@
+-- Passkeys are only required after the password once the user opts in.
+-- Accounts that already had a passkey were asked for one at every login, so
+-- they keep that behaviour until they turn it off.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passkey_second_factor BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users SET passkey_second_factor = true
+WHERE id IN (SELECT user_id FROM credentials);
@@ -0,0 +1,6 @@
+-- Registration used to trim surrounding whitespace from passwords. Hashes
+-- stored until then are flagged so login can still accept the untrimmed input
+-- for them; the flag clears once the user sets a new password.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_trimmed BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users SET password_trimmed = true;
@@ -0,0 +1,3 @@
+-- Disabled accounts cannot sign in or refresh, and introspection reports
+-- their tokens as inactive. NULL means the account is enabled.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
@@ -2,12 +2,20 @@ package postgres
 
 import (
 	"auction/domain"
+	"auction/pkg/envelope"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// PgRepository implements the identity repositories on PostgreSQL. Expired
+// and abandoned rows (WebAuthn ceremonies, login throttles, sessions,
+// trusted devices, authorization codes) are deleted by the method that
+// writes or lists that kind of row, so no separate cleanup job is needed.
 type PgRepository struct {
 	db *sqlx.DB
 }
@@ -44,34 +52,718 @@ func (r *PgRepository) FindByEmail(ctx context.Context, email string) (*domain.U
 
 func (r *PgRepository) Create(ctx context.Context, email, password, name string) (string, error) {
 	var id string
-	query := `INSERT INTO users (email, password, name) VALUES ($1, $2, $3) RETURNING id`
+	// New users get the default roles in the same statement, so no account
+	// exists without them.
+	query := `WITH created AS (
+			INSERT INTO users (email, password, name) VALUES ($1, $2, $3) RETURNING id
+		), assigned AS (
+			INSERT INTO user_roles (user_id, role) SELECT created.id, roles.name FROM created, roles WHERE roles.is_default
+		)
+		SELECT id FROM created`
 	err := r.db.GetContext(ctx, &id, query, email, password, name)
 	return id, err
 }
 
 func (r *PgRepository) Update(ctx context.Context, id, email, name string) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE users SET email = $1, name = $2 WHERE id = $3", email, name, id)
+	// A different address has not been verified yet.
+	query := `UPDATE users SET email = $1, name = $2, updated_at = NOW(),
+		email_verified_at = CASE WHEN email = $1 THEN email_verified_at ELSE NULL END
+		WHERE id = $3`
+	_, err := r.db.ExecContext(ctx, query, email, name, id)
 	return err
 }
 
-func (r *PgRepository) EnableTwoFactor(ctx context.Context, id, secret string) error {
-	query := `UPDATE users SET two_factor_enabled = TRUE, two_factor_secret = $1 WHERE id = $2`
-	_, err := r.db.ExecContext(ctx, query, secret, id)
+func (r *PgRepository) SetPendingEmail(ctx context.Context, id, email string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET pending_email = $1, updated_at = NOW() WHERE id = $2", nullString(email), id)
 	return err
 }
 
-func (r *PgRepository) DisableTwoFactor(ctx context.Context, id string) error {
-	query := `UPDATE users SET two_factor_enabled = FALSE, two_factor_secret = NULL, two_factor_verified = FALSE WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+func (r *PgRepository) ConfirmPendingEmail(ctx context.Context, id, email string) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET email = pending_email, pending_email = NULL, email_verified_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND pending_email = $2`
+	res, err := tx.ExecContext(ctx, query, id, email)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	query = `UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *PgRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET password = $1, password_trimmed = FALSE WHERE id = $2", password, id)
+	return err
+}
+
+func (r *PgRepository) RehashPassword(ctx context.Context, id, password string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET password = $1 WHERE id = $2", password, id)
+	return err
+}
+
+func (r *PgRepository) EnableTwoFactor(ctx context.Context, id string, secret envelope.Sealed, config domain.TwoFactorConfig) error {
+	// A new secret has to be verified again and starts from a fresh counter.
+	query := `UPDATE users SET two_factor_enabled = TRUE,
+		two_factor_verified = CASE WHEN two_factor_secret IS DISTINCT FROM $1 THEN FALSE ELSE two_factor_verified END,
+		two_factor_last_counter = CASE WHEN two_factor_secret IS DISTINCT FROM $1 THEN NULL ELSE two_factor_last_counter END,
+		two_factor_secret = $1, two_factor_data_key = $2, two_factor_key_version = $3,
+		two_factor_type = $4, two_factor_algorithm = $5, two_factor_digits = $6, two_factor_period = $7
+		WHERE id = $8`
+	_, err := r.db.ExecContext(ctx, query,
+		secret.Ciphertext, nullString(secret.DataKey), nullString(secret.KeyVersion),
+		config.Type, config.Algorithm, config.Digits, config.Period, id,
+	)
 	return err
 }
 
+func (r *PgRepository) SetPasskeySecondFactor(ctx context.Context, id string, enabled bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET passkey_second_factor = $1 WHERE id = $2", enabled, id)
+	return err
+}
+
+func (r *PgRepository) SetDisabled(ctx context.Context, id string, disabled bool) (bool, error) {
+	query := `UPDATE users SET disabled_at = CASE WHEN $1 THEN NOW() END, updated_at = NOW()
+		WHERE id = $2 AND (disabled_at IS NOT NULL) <> $1`
+	res, err := r.db.ExecContext(ctx, query, disabled, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) FindUsersWithTwoFactorSecret(ctx context.Context) ([]domain.User, error) {
+	var users []domain.User
+	err := r.db.SelectContext(ctx, &users, "SELECT * FROM users WHERE two_factor_secret IS NOT NULL ORDER BY id")
+	return users, err
+}
+
+func (r *PgRepository) UpdateTwoFactorSecret(ctx context.Context, id string, previous, secret envelope.Sealed) (bool, error) {
+	query := `UPDATE users SET two_factor_secret = $1, two_factor_data_key = $2, two_factor_key_version = $3
+		WHERE id = $4 AND two_factor_secret = $5`
+	res, err := r.db.ExecContext(ctx, query, secret.Ciphertext, secret.DataKey, secret.KeyVersion, id, previous.Ciphertext)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) DisableTwoFactor(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET two_factor_enabled = FALSE, two_factor_secret = NULL, two_factor_data_key = NULL,
+		two_factor_key_version = NULL, two_factor_verified = FALSE, two_factor_last_counter = NULL WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (r *PgRepository) MarkTwoFactorVerified(ctx context.Context, id string) error {
 	_, err := r.db.ExecContext(ctx, "UPDATE users SET two_factor_verified = TRUE WHERE id = $1", id)
 	return err
 }
 
-func (r *PgRepository) SetRecoveryCodes(ctx context.Context, id, codes string) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE users SET two_factor_recovery_codes = $1 WHERE id = $2", codes, id)
+func (r *PgRepository) AcceptTwoFactorCounter(ctx context.Context, id string, counter int64) (bool, error) {
+	query := `UPDATE users SET two_factor_last_counter = $1
+		WHERE id = $2 AND (two_factor_last_counter IS NULL OR two_factor_last_counter < $1)`
+	res, err := r.db.ExecContext(ctx, query, counter, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) MarkEmailVerified(ctx context.Context, id, email string) (bool, error) {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1 AND email = $2`
+	res, err := r.db.ExecContext(ctx, query, id, email)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *PgRepository) ListRecoveryCodes(ctx context.Context, userID string) ([]domain.RecoveryCode, error) {
+	var codes []domain.RecoveryCode
+	err := r.db.SelectContext(ctx, &codes, "SELECT * FROM recovery_codes WHERE user_id = $1 ORDER BY created_at, id", userID)
+	return codes, err
+}
+
+func (r *PgRepository) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	query := `UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) CreateRefreshToken(ctx context.Context, userID, familyID, tokenHash, amr, clientID, scope string, expiresAt time.Time) error {
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, amr, client_id, scope, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::UUID, $6, $7)`
+	_, err := r.db.ExecContext(ctx, query, userID, familyID, tokenHash, amr, clientID, scope, expiresAt)
+	return err
+}
+
+func (r *PgRepository) FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	err := r.db.GetContext(ctx, &token, "SELECT * FROM refresh_tokens WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PgRepository) MarkRefreshTokenUsed(ctx context.Context, id string) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, familyID)
+	return err
+}
+
+func (r *PgRepository) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (r *PgRepository) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, jti, expiresAt)
+	return err
+}
+
+func (r *PgRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > NOW())`
+	err := r.db.GetContext(ctx, &revoked, query, jti)
+	return revoked, err
+}
+
+func (r *PgRepository) RevokeUserTokensBefore(ctx context.Context, userID string, before time.Time) error {
+	query := `UPDATE users SET tokens_revoked_before = GREATEST(COALESCE(tokens_revoked_before, $1), $1) WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, before, userID)
+	return err
+}
+
+func (r *PgRepository) UserTokensRevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	var before sql.NullTime
+	err := r.db.GetContext(ctx, &before, "SELECT tokens_revoked_before FROM users WHERE id = $1", userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return before.Time, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (r *PgRepository) CreateCredential(ctx context.Context, userID string, credentialID []byte, name string, data []byte) (string, error) {
+	var id string
+	query := `INSERT INTO credentials (user_id, credential_id, name, data) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := r.db.QueryRowContext(ctx, query, userID, credentialID, name, data).Scan(&id)
+	return id, err
+}
+
+func (r *PgRepository) ListCredentials(ctx context.Context, userID string) ([]domain.Credential, error) {
+	var credentials []domain.Credential
+	err := r.db.SelectContext(ctx, &credentials, "SELECT * FROM credentials WHERE user_id = $1 ORDER BY created_at, id", userID)
+	return credentials, err
+}
+
+func (r *PgRepository) FindCredentialByCredentialID(ctx context.Context, credentialID []byte) (*domain.Credential, error) {
+	var credential domain.Credential
+	err := r.db.GetContext(ctx, &credential, "SELECT * FROM credentials WHERE credential_id = $1", credentialID)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *PgRepository) UpdateCredentialUsage(ctx context.Context, id string, data []byte) error {
+	query := `UPDATE credentials SET data = $1, last_used_at = NOW() WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, data, id)
 	return err
-}
\ No newline at end of file
+}
+
+func (r *PgRepository) DeleteCredential(ctx context.Context, userID, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM credentials WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) CreateWebAuthnSession(ctx context.Context, userID, purpose string, data []byte, expiresAt time.Time) (string, error) {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM webauthn_sessions WHERE expires_at <= NOW()"); err != nil {
+		return "", err
+	}
+
+	var id string
+	query := `INSERT INTO webauthn_sessions (user_id, purpose, data, expires_at) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := r.db.QueryRowContext(ctx, query, nullString(userID), purpose, data, expiresAt).Scan(&id)
+	return id, err
+}
+
+func (r *PgRepository) ConsumeWebAuthnSession(ctx context.Context, id, purpose string) (*domain.WebAuthnSession, error) {
+	var session domain.WebAuthnSession
+	query := `DELETE FROM webauthn_sessions WHERE id = $1 AND purpose = $2 AND expires_at > NOW() RETURNING *`
+	err := r.db.GetContext(ctx, &session, query, id, purpose)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *PgRepository) CreatePasswordResetToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	query := `INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`
+	_, err := r.db.ExecContext(ctx, query, userID, tokenHash, expiresAt)
+	return err
+}
+
+func (r *PgRepository) FindPasswordResetToken(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+	query := `SELECT * FROM password_reset_tokens WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()`
+	if err := r.db.GetContext(ctx, &token, query, tokenHash); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PgRepository) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var token domain.PasswordResetToken
+	query := `UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW() RETURNING *`
+	if err := tx.GetContext(ctx, &token, query, tokenHash); err != nil {
+		return nil, err
+	}
+
+	query = `UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`
+	if _, err := tx.ExecContext(ctx, query, token.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PgRepository) RecordAuditEvent(ctx context.Context, event domain.AuditEvent) error {
+	metadata := event.Metadata
+	if len(metadata) == 0 {
+		metadata = []byte("{}")
+	}
+
+	query := `INSERT INTO audit_events (user_id, event, ip_address, user_agent, metadata) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.ExecContext(ctx, query, event.UserID, event.Event, event.IPAddress, event.UserAgent, metadata)
+	return err
+}
+
+func (r *PgRepository) RecordLoginAttempt(ctx context.Context, scope, subject string, window time.Duration, delay func(failures int) time.Duration) (*domain.LoginThrottle, bool, error) {
+	query := `DELETE FROM login_throttles
+		WHERE last_failure_at < NOW() - $1 * INTERVAL '1 second' AND (locked_until IS NULL OR locked_until <= NOW())`
+	if _, err := r.db.ExecContext(ctx, query, window.Seconds()); err != nil {
+		return nil, false, err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// The upsert holds the row lock until commit, so a concurrent attempt
+	// waits here and then sees the lock set below.
+	var throttle domain.LoginThrottle
+	var locked bool
+	query = `INSERT INTO login_throttles AS t (scope, subject, failures) VALUES ($1, $2, 1)
+		ON CONFLICT (scope, subject) DO UPDATE SET
+			failures = CASE
+				WHEN t.locked_until > NOW() THEN t.failures
+				WHEN t.last_failure_at < NOW() - $3 * INTERVAL '1 second' THEN 1
+				ELSE t.failures + 1
+			END,
+			last_failure_at = CASE WHEN t.locked_until > NOW() THEN t.last_failure_at ELSE NOW() END
+		RETURNING scope, subject, failures, last_failure_at, locked_until, COALESCE(locked_until > NOW(), FALSE)`
+	err = tx.QueryRowxContext(ctx, query, scope, subject, window.Seconds()).Scan(
+		&throttle.Scope, &throttle.Subject, &throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil, &locked,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	if locked {
+		return &throttle, false, tx.Commit()
+	}
+
+	if d := delay(throttle.Failures); d > 0 {
+		query = `UPDATE login_throttles SET locked_until = NOW() + $3 * INTERVAL '1 second'
+			WHERE scope = $1 AND subject = $2 RETURNING locked_until`
+		if err := tx.GetContext(ctx, &throttle.LockedUntil, query, scope, subject, d.Seconds()); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return &throttle, true, tx.Commit()
+}
+
+func (r *PgRepository) ForgiveLoginAttempt(ctx context.Context, scope, subject string) error {
+	query := `UPDATE login_throttles SET failures = GREATEST(failures - 1, 0) WHERE scope = $1 AND subject = $2`
+	_, err := r.db.ExecContext(ctx, query, scope, subject)
+	return err
+}
+
+func (r *PgRepository) ClearLoginThrottle(ctx context.Context, scope, subject string) (bool, error) {
+	query := `DELETE FROM login_throttles WHERE scope = $1 AND subject = $2`
+	res, err := r.db.ExecContext(ctx, query, scope, subject)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// activeSession matches sessions whose refresh token family is still usable.
+const activeSession = `EXISTS (SELECT 1 FROM refresh_tokens rt WHERE rt.family_id = s.id
+	AND rt.used_at IS NULL AND rt.revoked_at IS NULL AND rt.expires_at > NOW())`
+
+func (r *PgRepository) RecordSession(ctx context.Context, session domain.Session) error {
+	query := `INSERT INTO sessions (id, user_id, jti, ip_address, user_agent) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET
+			jti = EXCLUDED.jti,
+			ip_address = EXCLUDED.ip_address,
+			user_agent = EXCLUDED.user_agent,
+			last_seen_at = NOW()`
+	_, err := r.db.ExecContext(ctx, query, session.ID, session.UserID, session.JTI, session.IPAddress, session.UserAgent)
+	return err
+}
+
+func (r *PgRepository) ListActiveSessions(ctx context.Context, userID string) ([]domain.Session, error) {
+	query := `DELETE FROM sessions s WHERE s.user_id = $1 AND NOT ` + activeSession
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return nil, err
+	}
+
+	var sessions []domain.Session
+	query = `SELECT s.* FROM sessions s WHERE s.user_id = $1 AND ` + activeSession + ` ORDER BY s.last_seen_at DESC`
+	if err := r.db.SelectContext(ctx, &sessions, query, userID); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *PgRepository) RevokeSession(ctx context.Context, userID, id string) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *PgRepository) FindSession(ctx context.Context, userID, id string) (*domain.Session, error) {
+	var session domain.Session
+	if err := r.db.GetContext(ctx, &session, "SELECT * FROM sessions WHERE id = $1 AND user_id = $2", id, userID); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *PgRepository) CreateTrustedDevice(ctx context.Context, device domain.TrustedDevice) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE expires_at <= NOW()"); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO trusted_devices (id, user_id, token_hash, ip_address, user_agent, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.ExecContext(ctx, query, device.ID, device.UserID, device.TokenHash, device.IPAddress, device.UserAgent, device.ExpiresAt)
+	return err
+}
+
+func (r *PgRepository) FindTrustedDevice(ctx context.Context, userID, tokenHash string) (*domain.TrustedDevice, error) {
+	var device domain.TrustedDevice
+	query := `SELECT * FROM trusted_devices WHERE user_id = $1 AND token_hash = $2 AND expires_at > NOW()`
+	if err := r.db.GetContext(ctx, &device, query, userID, tokenHash); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+func (r *PgRepository) TouchTrustedDevice(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE trusted_devices SET last_used_at = NOW() WHERE id = $1", id)
+	return err
+}
+
+func (r *PgRepository) ListTrustedDevices(ctx context.Context, userID string) ([]domain.TrustedDevice, error) {
+	var devices []domain.TrustedDevice
+	query := "SELECT * FROM trusted_devices WHERE user_id = $1 AND expires_at > NOW() ORDER BY created_at, id"
+	if err := r.db.SelectContext(ctx, &devices, query, userID); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (r *PgRepository) DeleteTrustedDevice(ctx context.Context, userID, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) DeleteUserTrustedDevices(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE user_id = $1", userID)
+	return err
+}
+
+func (r *PgRepository) CreateOAuthClient(ctx context.Context, client domain.OAuthClient) (string, error) {
+	var id string
+	query := `INSERT INTO oauth_clients (name, secret_hash, redirect_uris, scopes, grant_types) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err := r.db.GetContext(ctx, &id, query, client.Name, client.SecretHash, client.RedirectURIs, client.Scopes, client.GrantTypes)
+	return id, err
+}
+
+func (r *PgRepository) FindOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	var client domain.OAuthClient
+	if err := r.db.GetContext(ctx, &client, "SELECT * FROM oauth_clients WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *PgRepository) ListOAuthClients(ctx context.Context) ([]domain.OAuthClient, error) {
+	var clients []domain.OAuthClient
+	if err := r.db.SelectContext(ctx, &clients, "SELECT * FROM oauth_clients ORDER BY created_at, id"); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+func (r *PgRepository) DeleteOAuthClient(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM oauth_clients WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) CreateAuthorizationCode(ctx context.Context, code domain.AuthorizationCode) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM authorization_codes WHERE expires_at <= NOW()"); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO authorization_codes
+		(code_hash, client_id, user_id, session_id, redirect_uri, scope, nonce, code_challenge, amr, auth_time, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := r.db.ExecContext(ctx, query, code.CodeHash, code.ClientID, code.UserID, code.SessionID, code.RedirectURI,
+		code.Scope, code.Nonce, code.CodeChallenge, code.AMR, code.AuthTime, code.ExpiresAt)
+	return err
+}
+
+func (r *PgRepository) FindAuthorizationCode(ctx context.Context, codeHash string) (*domain.AuthorizationCode, error) {
+	var code domain.AuthorizationCode
+	query := "SELECT * FROM authorization_codes WHERE code_hash = $1 AND expires_at > NOW()"
+	if err := r.db.GetContext(ctx, &code, query, codeHash); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *PgRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (bool, error) {
+	query := `UPDATE authorization_codes SET used_at = NOW() WHERE code_hash = $1 AND used_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+const selectRoles = `SELECT r.name, r.description, r.is_default, r.created_at,
+	COALESCE(string_agg(rp.permission, ' ' ORDER BY rp.permission), '') AS permissions
+	FROM roles r LEFT JOIN role_permissions rp ON rp.role = r.name`
+
+func (r *PgRepository) ListRoles(ctx context.Context) ([]domain.Role, error) {
+	var roles []domain.Role
+	if err := r.db.SelectContext(ctx, &roles, selectRoles+" GROUP BY r.name ORDER BY r.name"); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *PgRepository) FindRole(ctx context.Context, name string) (*domain.Role, error) {
+	var role domain.Role
+	if err := r.db.GetContext(ctx, &role, selectRoles+" WHERE r.name = $1 GROUP BY r.name", name); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *PgRepository) ListUserRoles(ctx context.Context, userID string) ([]domain.Role, error) {
+	var roles []domain.Role
+	query := selectRoles + " JOIN user_roles ur ON ur.role = r.name WHERE ur.user_id = $1 GROUP BY r.name ORDER BY r.name"
+	if err := r.db.SelectContext(ctx, &roles, query, userID); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *PgRepository) AssignUserRole(ctx context.Context, userID, role string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, role)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PgRepository) RemoveUserRole(ctx context.Context, userID, role string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND role = $2", userID, role)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
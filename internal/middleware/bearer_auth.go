@@ -2,17 +2,32 @@ package middleware
 
 import (
 	"auction/pkg/httperror"
+	"auction/pkg/revocation"
 	"context"
+	"errors"
 	"strings"
 
 	jwtPkg "auction/pkg/jwt"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
 )
 
-func NewBearerAuthMiddleware(secret string) fiber.Handler {
-	tokenSecret := []byte(secret)
+// BearerPolicy selects which access tokens a route accepts.
+type BearerPolicy int
 
+const (
+	// UserTokens accepts tokens that act for a user, including those issued
+	// to OIDC clients on the user's behalf.
+	UserTokens BearerPolicy = iota
+	// UserOrMachineTokens also accepts the machine tokens services obtain
+	// with the client_credentials grant.
+	UserOrMachineTokens
+)
+
+// NewBearerAuthMiddleware authenticates the Bearer token. Machine tokens
+// carry no user, so routes with the UserTokens policy answer them with 403
+// and the context holds ClientID and Scope but no UserID.
+func NewBearerAuthMiddleware(revocations revocation.Store, policy BearerPolicy) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := strings.TrimSpace(c.Get("Authorization"))
 		if authHeader == "" {
@@ -26,18 +41,13 @@ func NewBearerAuthMiddleware(secret string) fiber.Handler {
 
 		tokenString := strings.TrimSpace(parts[1])
 
-		parsedToken, err := jwt.ParseWithClaims(tokenString, &jwtPkg.Claims{}, func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
+		claims, err := jwtPkg.DecodeAccessToken(tokenString)
+		if errors.Is(err, jwtPkg.ErrWrongTokenType) {
+			if claims, err = jwtPkg.DecodeMachineToken(tokenString); err == nil && policy == UserTokens {
+				return machineToken(c)
 			}
-			return tokenSecret, nil
-		})
-		if err != nil || parsedToken == nil || !parsedToken.Valid {
-			return unauthorized(c)
 		}
-
-		claims, ok := parsedToken.Claims.(*jwtPkg.Claims)
-		if !ok || claims.Subject == "" {
+		if err != nil {
 			return unauthorized(c)
 		}
 
@@ -46,9 +56,25 @@ func NewBearerAuthMiddleware(secret string) fiber.Handler {
 			userCtx = context.Background()
 		}
 
-		userCtx = context.WithValue(userCtx, "UserID", claims.Subject)
-		userCtx = context.WithValue(userCtx, "UserEmail", claims.Email)
+		err = revocation.Check(userCtx, revocations, claims.ID, claims.SessionID, claims.Subject, claims.IssuedAt.Time)
+		if errors.Is(err, revocation.ErrRevoked) {
+			return unauthorized(c)
+		}
+		if err != nil {
+			zap.L().Error("Failed to check token revocation", zap.Error(err))
+			return internalServerError(c)
+		}
+
+		if claims.Type != jwtPkg.TokenTypeMachine {
+			userCtx = context.WithValue(userCtx, "UserID", claims.Subject)
+			userCtx = context.WithValue(userCtx, "UserEmail", claims.Email)
+			userCtx = context.WithValue(userCtx, "SessionID", claims.SessionID)
+			userCtx = context.WithValue(userCtx, "Roles", claims.Roles)
+			userCtx = context.WithValue(userCtx, "Permissions", claims.Permissions)
+		}
 		userCtx = context.WithValue(userCtx, "Jwt", tokenString)
+		userCtx = context.WithValue(userCtx, "ClientID", claims.ClientID)
+		userCtx = context.WithValue(userCtx, "Scope", claims.Scope)
 
 		c.SetUserContext(userCtx)
 		return c.Next()
@@ -67,3 +93,29 @@ func unauthorized(c *fiber.Ctx) error {
 		"message": err.Message,
 	})
 }
+
+func machineToken(c *fiber.Ctx) error {
+	err := httperror.Forbidden(
+		"identity.auth.machine_token",
+		"Machine tokens cannot access user routes",
+		nil,
+	)
+
+	return c.Status(err.Status).JSON(fiber.Map{
+		"code":    err.Code,
+		"message": err.Message,
+	})
+}
+
+func internalServerError(c *fiber.Ctx) error {
+	err := httperror.InternalServerError(
+		"identity.auth.internal_server_error",
+		"Internal server error",
+		nil,
+	)
+
+	return c.Status(err.Status).JSON(fiber.Map{
+		"code":    err.Code,
+		"message": err.Message,
+	})
+}
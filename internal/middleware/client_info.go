@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// ClientInfoMiddleware stores the caller's IP address and User-Agent in the
+// user context as "ClientIP" and "UserAgent" for auditing. ignoredProxyHeader
+// is the client IP header when no proxy is trusted; the first request that
+// carries it logs an error, since every client behind that proxy then shares
+// its address for lockouts and rate limits.
+func ClientInfoMiddleware(ignoredProxyHeader string) fiber.Handler {
+	var warnOnce sync.Once
+
+	return func(c *fiber.Ctx) error {
+		userCtx := c.UserContext()
+		if userCtx == nil {
+			userCtx = context.Background()
+		}
+
+		if ignoredProxyHeader != "" && c.Get(ignoredProxyHeader) != "" {
+			warnOnce.Do(func() {
+				zap.L().Error("Request forwarded by an untrusted proxy; set TRUSTED_PROXIES or all clients share its IP",
+					zap.String("proxy_ip", c.IP()),
+					zap.String("header", ignoredProxyHeader),
+				)
+			})
+		}
+
+		userCtx = context.WithValue(userCtx, "ClientIP", c.IP())
+		userCtx = context.WithValue(userCtx, "UserAgent", c.Get(fiber.HeaderUserAgent))
+
+		c.SetUserContext(userCtx)
+		return c.Next()
+	}
+}
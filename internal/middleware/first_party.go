@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"auction/pkg/httperror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FirstPartyMiddleware rejects access tokens issued to OIDC clients. Those
+// may read /userinfo and pass /validate, but must not manage the account.
+// It runs after the bearer middleware.
+func FirstPartyMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if clientID, _ := c.UserContext().Value("ClientID").(string); clientID != "" {
+			err := httperror.Forbidden(
+				"identity.auth.client_token",
+				"Tokens issued to a client cannot manage the account",
+				nil,
+			)
+
+			return c.Status(err.Status).JSON(fiber.Map{
+				"code":    err.Code,
+				"message": err.Message,
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// NoStoreMiddleware keeps responses carrying tokens out of caches, as RFC
+// 6749 requires for the token endpoint.
+func NoStoreMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		c.Set(fiber.HeaderPragma, "no-cache")
+		return c.Next()
+	}
+}
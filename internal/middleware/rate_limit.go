@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"auction/pkg/httperror"
+	"auction/pkg/ratelimit"
+	"auction/pkg/securetoken"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// NewRateLimitMiddleware enforces the rules of one route. Every rule is
+// counted separately and the request is rejected when any of them is
+// exceeded. The RateLimit-* headers describe the most restrictive rule.
+// Rules keyed by email are skipped when the body carries none, and store
+// failures let the request through rather than taking sign-in down. The
+// client IP is the proxy's unless TRUSTED_PROXIES lists it, in which case ip
+// rules limit every client at once.
+func NewRateLimitMiddleware(limiter *ratelimit.Limiter, route string, rules []ratelimit.Rule) fiber.Handler {
+	if len(rules) == 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	policies := make([]string, len(rules))
+	for i, rule := range rules {
+		policies[i] = fmt.Sprintf("%d;w=%d", rule.Limit.Requests, int64(rule.Limit.Window.Seconds()))
+	}
+	policy := strings.Join(policies, ", ")
+
+	return func(c *fiber.Ctx) error {
+		var tightest *ratelimit.Result
+
+		for _, rule := range rules {
+			subject := rateLimitSubject(c, rule.By)
+			if subject == "" {
+				continue
+			}
+
+			// Hashing keeps client emails out of the shared store.
+			key := route + ":" + rule.By + ":" + securetoken.Hash(subject)
+			result, err := limiter.Allow(c.UserContext(), key, rule.Limit)
+			if err != nil {
+				zap.L().Error("Failed to check rate limit", zap.String("route", route), zap.Error(err))
+				continue
+			}
+
+			if tightest == nil || restricts(result, *tightest) {
+				tightest = &result
+			}
+		}
+
+		if tightest == nil {
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Policy", policy)
+		c.Set("RateLimit-Limit", strconv.Itoa(tightest.Limit.Requests))
+		c.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Set("RateLimit-Reset", strconv.FormatInt(seconds(tightest.Reset), 10))
+
+		if tightest.Allowed {
+			return c.Next()
+		}
+
+		retryAfter := seconds(tightest.RetryAfter)
+		c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+
+		err := httperror.TooManyRequests("request.rate_limited", "Too many requests, try again later", nil)
+		return c.Status(err.Status).JSON(fiber.Map{
+			"code":    err.Code,
+			"message": err.Message,
+			"details": fiber.Map{"retry_after": retryAfter},
+		})
+	}
+}
+
+// restricts reports whether a is more restrictive than b: a rejection beats
+// an allowance, a longer wait beats a shorter one, and fewer remaining
+// requests beat more.
+func restricts(a, b ratelimit.Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+func rateLimitSubject(c *fiber.Ctx, by string) string {
+	switch by {
+	case ratelimit.ByIP:
+		return c.IP()
+	case ratelimit.ByEmail:
+		return requestEmail(c)
+	case ratelimit.ByIPAndEmail:
+		if email := requestEmail(c); email != "" {
+			return c.IP() + "|" + email
+		}
+	}
+	return ""
+}
+
+// requestEmail reads the email field of the body without consuming it, so
+// the handler can still parse the request.
+func requestEmail(c *fiber.Ctx) string {
+	var body struct {
+		Email string `json:"email" form:"email"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(body.Email))
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
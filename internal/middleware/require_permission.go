@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"auction/pkg/httperror"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission lets a request through only when its access token grants
+// every one of the permissions. It runs after the bearer middleware; tokens
+// carry the permissions of the user's roles as of their issue.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, _ := c.UserContext().Value("Permissions").([]string)
+
+		for _, permission := range permissions {
+			if !slices.Contains(granted, permission) {
+				err := httperror.Forbidden(
+					"identity.auth.missing_permission",
+					"Missing permission "+permission,
+					nil,
+				)
+
+				return c.Status(err.Status).JSON(fiber.Map{
+					"code":    err.Code,
+					"message": err.Message,
+				})
+			}
+		}
+
+		return c.Next()
+	}
+}
@@ -4,17 +4,27 @@ import (
 	"auction/app/identity"
 	"auction/infra/postgres"
 	"auction/internal/middleware"
+	"auction/pkg/breach"
 	"auction/pkg/config"
+	"auction/pkg/envelope"
 	"auction/pkg/httperror"
+	"auction/pkg/jwt"
+	"auction/pkg/mailer"
+	"auction/pkg/password"
+	"auction/pkg/ratelimit"
+	"auction/pkg/revocation"
+	"auction/pkg/totp"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
@@ -29,49 +39,124 @@ func handle[R Request, Res Response](handler HandlerInterface[R, Res]) fiber.Han
 	return func(c *fiber.Ctx) error {
 		var req R
 
-		if err := c.BodyParser(&req); err != nil && !errors.Is(err, fiber.ErrUnprocessableEntity) {
-			return writeError(c, httperror.BadRequest(
-				"request.invalid_body",
-				"Invalid body",
-				fiber.Map{"error": err.Error()},
-			))
+		if err := parseRequest(c, &req); err != nil {
+			return writeError(c, err)
 		}
 
-		if err := c.ParamsParser(&req); err != nil {
-			return writeError(c, httperror.BadRequest(
-				"request.invalid_path_params",
-				"Invalid path params",
-				fiber.Map{"error": err.Error()},
-			))
+		ctx := c.UserContext()
+
+		res, err := handler.Handle(ctx, &req)
+		if err != nil {
+			return writeError(c, err)
 		}
 
-		if err := c.QueryParser(&req); err != nil {
-			return writeError(c, httperror.BadRequest(
-				"request.invalid_query_params",
-				"Invalid query params",
-				fiber.Map{"error": err.Error()},
-			))
+		return c.JSON(res)
+	}
+}
+
+// handleRedirect serves a handler whose response sends the browser on, as
+// /authorize must answer with a redirect rather than JSON.
+func handleRedirect[R Request](handler HandlerInterface[R, identity.AuthorizeResponse]) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req R
+
+		if err := parseRequest(c, &req); err != nil {
+			return writeError(c, err)
 		}
 
-		if err := c.ReqHeaderParser(&req); err != nil {
-			return writeError(c, httperror.BadRequest(
-				"request.invalid_headers",
-				"Invalid headers",
-				fiber.Map{"error": err.Error()},
-			))
+		res, err := handler.Handle(c.UserContext(), &req)
+		if err != nil {
+			return writeError(c, err)
 		}
 
-		ctx := c.UserContext()
+		return c.Redirect(res.RedirectTo, fiber.StatusFound)
+	}
+}
 
-		res, err := handler.Handle(ctx, &req)
+// handleIntrospection serves token introspection, whose answer resource
+// servers may cache for as long as the handler allows.
+func handleIntrospection[R Request](handler HandlerInterface[R, identity.IntrospectResponse]) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req R
+
+		if err := parseRequest(c, &req); err != nil {
+			return writeError(c, err)
+		}
+
+		res, err := handler.Handle(c.UserContext(), &req)
+		if err != nil {
+			return writeError(c, err)
+		}
+
+		if maxAge := int(res.CacheFor.Seconds()); maxAge > 0 {
+			c.Set(fiber.HeaderCacheControl, fmt.Sprintf("private, max-age=%d", maxAge))
+		} else {
+			c.Set(fiber.HeaderCacheControl, "no-store")
+		}
+
+		return c.JSON(res)
+	}
+}
+
+// handleForwardAuth answers a forward-auth request. The reverse proxy copies
+// the identity headers of a 2xx response onto the request it forwards.
+func handleForwardAuth[R Request](handler HandlerInterface[R, identity.ValidateHandlerResponse]) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req R
+
+		if err := parseRequest(c, &req); err != nil {
+			return writeError(c, err)
+		}
+
+		res, err := handler.Handle(c.UserContext(), &req)
 		if err != nil {
 			return writeError(c, err)
 		}
 
+		for header, value := range res.Headers {
+			c.Set(header, value)
+		}
+
 		return c.JSON(res)
 	}
 }
 
+func parseRequest(c *fiber.Ctx, req any) error {
+	if err := c.BodyParser(req); err != nil && !errors.Is(err, fiber.ErrUnprocessableEntity) {
+		return httperror.BadRequest(
+			"request.invalid_body",
+			"Invalid body",
+			fiber.Map{"error": err.Error()},
+		)
+	}
+
+	if err := c.ParamsParser(req); err != nil {
+		return httperror.BadRequest(
+			"request.invalid_path_params",
+			"Invalid path params",
+			fiber.Map{"error": err.Error()},
+		)
+	}
+
+	if err := c.QueryParser(req); err != nil {
+		return httperror.BadRequest(
+			"request.invalid_query_params",
+			"Invalid query params",
+			fiber.Map{"error": err.Error()},
+		)
+	}
+
+	if err := c.ReqHeaderParser(req); err != nil {
+		return httperror.BadRequest(
+			"request.invalid_headers",
+			"Invalid headers",
+			fiber.Map{"error": err.Error()},
+		)
+	}
+
+	return nil
+}
+
 func main() {
 	appConfig := config.Read()
 	defer zap.L().Sync()
@@ -83,6 +168,12 @@ func main() {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		Concurrency:  256 * 1024,
+		// Client IPs feed the login lockout and the audit trail, so the
+		// proxy header is only honoured from configured proxies.
+		EnableTrustedProxyCheck: appConfig.TrustedProxies != "",
+		TrustedProxies:          splitList(appConfig.TrustedProxies),
+		ProxyHeader:             proxyHeader(appConfig),
+		EnableIPValidation:      true,
 	})
 
 	pgRepository := postgres.NewPgRepository(
@@ -93,32 +184,212 @@ func main() {
 		appConfig.PostgresPort,
 	)
 
-	loginHandler := identity.NewLoginHandler(pgRepository)
-	registerHandler := identity.NewRegisterHandler(pgRepository)
-	twoFactorChallengeHandler := identity.NewTwoFactorChallengeHandler(pgRepository)
-	enableTwoFactorHandler := identity.NewEnableTwoFactorHandler(pgRepository)
-	disableTwoFactorHandler := identity.NewDisableTwoFactorHandler(pgRepository)
+	keyStore, err := loadKeyStore(appConfig)
+	if err != nil {
+		zap.L().Fatal("Failed to load JWT signing keys", zap.Error(err))
+	}
+	jwt.UseKeyStore(keyStore)
+
+	passwordHasher, err := password.NewHasher(appConfig)
+	if err != nil {
+		zap.L().Fatal("Failed to configure password hasher", zap.Error(err))
+	}
+
+	var breaches password.BreachChecker
+	if appConfig.BreachedPasswordsFile != "" {
+		breachIndex, err := breach.Open(appConfig.BreachedPasswordsFile)
+		if err != nil {
+			zap.L().Fatal("Failed to open breached password index", zap.Error(err))
+		}
+		defer breachIndex.Close()
+		breaches = breachIndex
+	}
+	passwordPolicy := password.NewPolicy(appConfig, breaches)
+
+	totpAlgorithm, err := totp.ParseAlgorithm(appConfig.TOTPAlgorithm)
+	if err != nil {
+		zap.L().Fatal("Invalid TOTP algorithm", zap.String("algorithm", appConfig.TOTPAlgorithm), zap.Error(err))
+	}
+
+	otpSettings := identity.OTPSettings{
+		Defaults: totp.Options{
+			Type:      totp.TypeTOTP,
+			Algorithm: totpAlgorithm,
+			Period:    appConfig.TOTPPeriod,
+			Digits:    appConfig.TOTPDigits,
+			Window:    appConfig.TOTPWindow,
+		},
+		HOTPLookAhead:    appConfig.HOTPLookAhead,
+		HOTPResyncWindow: appConfig.HOTPResyncWindow,
+	}
+
+	masterKeys, err := envelope.LoadLocalKeyProvider(appConfig.SecretMasterKeyVersion, appConfig.SecretMasterKeys, appConfig.SecretMasterKeysFile)
+	if err != nil {
+		zap.L().Fatal("Failed to load secret master keys", zap.Error(err))
+	}
+
+	mail, err := mailer.New(appConfig)
+	if err != nil {
+		zap.L().Fatal("Failed to configure mailer", zap.Error(err))
+	}
+	emailVerification := identity.NewEmailVerification(mail, appConfig.EmailVerificationURL)
+	passwordResets := identity.NewPasswordResets(pgRepository, mail, appConfig.PasswordResetURL, appConfig.PasswordResetTTL)
+
+	twoFactorSecrets := identity.NewTwoFactorSecrets(masterKeys)
+	otpVerifier := identity.NewOTPVerifier(pgRepository, twoFactorSecrets, otpSettings)
+
+	revocations := revocation.NewCachedStore(pgRepository, appConfig.RevocationCacheTTL)
+	tokenIssuer := identity.NewTokenIssuer(pgRepository, pgRepository, pgRepository, revocations, appConfig.RefreshTokenTTL)
+
+	passkeys, err := identity.NewPasskeys(pgRepository, pgRepository, identity.PasskeySettings{
+		RPID:          appConfig.WebAuthnRPID,
+		RPDisplayName: appConfig.WebAuthnRPDisplayName,
+		RPOrigins:     splitList(appConfig.WebAuthnRPOrigins),
+		SessionTTL:    appConfig.WebAuthnSessionTTL,
+	})
+	if err != nil {
+		zap.L().Fatal("Invalid WebAuthn relying party configuration", zap.Error(err))
+	}
+
+	trustedDevices := identity.NewTrustedDevices(pgRepository)
+	lockout := identity.NewLockout(pgRepository, pgRepository, lockoutSettings(appConfig))
+	roles := identity.NewRoles(pgRepository, pgRepository, pgRepository, revocations)
+
+	loginHandler := identity.NewLoginHandler(pgRepository, passwordHasher, tokenIssuer, passkeys, lockout, trustedDevices)
+	registerHandler := identity.NewRegisterHandler(pgRepository, passwordHasher, passwordPolicy, emailVerification)
+	refreshTokenHandler := identity.NewRefreshTokenHandler(pgRepository, tokenIssuer)
+	twoFactorChallengeHandler := identity.NewTwoFactorChallengeHandler(pgRepository, pgRepository, tokenIssuer, revocations, otpVerifier, passkeys, lockout, trustedDevices)
+	enableTwoFactorHandler := identity.NewEnableTwoFactorHandler(pgRepository, twoFactorSecrets, otpSettings)
+	disableTwoFactorHandler := identity.NewDisableTwoFactorHandler(pgRepository, trustedDevices)
 	getUserHandler := identity.NewGetUserHandler(pgRepository)
-	verifyTwoFactorHandler := identity.NewVerifyTwoFactorHandler(pgRepository)
-	getRecoveryCodesHandler := identity.NewGetRecoveryCodesHandler(pgRepository)
-	validateHandler := identity.NewValidateHandler(pgRepository)
+	verifyTwoFactorHandler := identity.NewVerifyTwoFactorHandler(pgRepository, pgRepository, otpVerifier)
+	getRecoveryCodesHandler := identity.NewGetRecoveryCodesHandler(pgRepository, pgRepository)
+	regenerateRecoveryCodesHandler := identity.NewRegenerateRecoveryCodesHandler(pgRepository, pgRepository)
+	listRolesHandler := identity.NewListRolesHandler(pgRepository)
+	listUserRolesHandler := identity.NewListUserRolesHandler(pgRepository, pgRepository)
+	grantRoleHandler := identity.NewGrantRoleHandler(roles)
+	revokeRoleHandler := identity.NewRevokeRoleHandler(roles)
+	forwardAuthPolicies, err := identity.LoadForwardAuthPolicies(appConfig.ForwardAuthPoliciesFile)
+	if err != nil {
+		zap.L().Fatal("Failed to load forward auth policies", zap.Error(err))
+	}
+	forwardAuthHeaders, err := identity.ParseForwardAuthHeaders(appConfig.ForwardAuthHeaders)
+	if err != nil {
+		zap.L().Fatal("Invalid forward auth headers", zap.Error(err))
+	}
+	validateHandler := identity.NewValidateHandler(revocations, forwardAuthPolicies, forwardAuthHeaders)
+	listSessionsHandler := identity.NewListSessionsHandler(pgRepository)
+	revokeSessionHandler := identity.NewRevokeSessionHandler(pgRepository, pgRepository, revocations)
+	listTrustedDevicesHandler := identity.NewListTrustedDevicesHandler(pgRepository)
+	revokeTrustedDeviceHandler := identity.NewRevokeTrustedDeviceHandler(pgRepository, pgRepository)
+	logoutHandler := identity.NewLogoutHandler(pgRepository, pgRepository, revocations)
+	logoutAllHandler := identity.NewLogoutAllHandler(pgRepository, revocations, trustedDevices)
+	jwksHandler := identity.NewJWKSHandler(keyStore)
+	forgotPasswordHandler := identity.NewForgotPasswordHandler(pgRepository, passwordResets)
+	resetPasswordHandler := identity.NewResetPasswordHandler(pgRepository, pgRepository, pgRepository, passwordResets, passwordHasher, passwordPolicy, revocations, mail, trustedDevices)
+	changePasswordHandler := identity.NewChangePasswordHandler(pgRepository, pgRepository, pgRepository, passwordHasher, passwordPolicy, otpVerifier, revocations, tokenIssuer, mail, trustedDevices)
+	updateProfileHandler := identity.NewUpdateProfileHandler(pgRepository, passwordHasher, otpVerifier, emailVerification)
+	verifyEmailHandler := identity.NewVerifyEmailHandler(pgRepository, emailVerification)
+	resendVerificationEmailHandler := identity.NewResendVerificationEmailHandler(pgRepository, emailVerification)
+	beginTwoFactorPasskeyHandler := identity.NewBeginTwoFactorPasskeyHandler(pgRepository, revocations, passkeys)
+	beginPasskeyLoginHandler := identity.NewBeginPasskeyLoginHandler(passkeys)
+	finishPasskeyLoginHandler := identity.NewFinishPasskeyLoginHandler(passkeys, tokenIssuer)
+	beginPasskeyRegistrationHandler := identity.NewBeginPasskeyRegistrationHandler(pgRepository, passkeys)
+	finishPasskeyRegistrationHandler := identity.NewFinishPasskeyRegistrationHandler(pgRepository, passkeys)
+	enablePasskeySecondFactorHandler := identity.NewEnablePasskeySecondFactorHandler(pgRepository, passkeys)
+	disablePasskeySecondFactorHandler := identity.NewDisablePasskeySecondFactorHandler(pgRepository)
+	listPasskeysHandler := identity.NewListPasskeysHandler(pgRepository)
+	deletePasskeyHandler := identity.NewDeletePasskeyHandler(pgRepository, pgRepository)
+
+	oauthClients := identity.NewOAuthClients(pgRepository)
+	openIDConfigurationHandler := identity.NewOpenIDConfigurationHandler(keyStore)
+	authorizeHandler := identity.NewAuthorizeHandler(oauthClients, appConfig.OIDCLoginURL)
+	approveAuthorizationHandler := identity.NewApproveAuthorizationHandler(oauthClients, pgRepository, pgRepository, appConfig.AuthorizationCodeTTL)
+	oauthTokenHandler := identity.NewOAuthTokenHandler(pgRepository, oauthClients, pgRepository, pgRepository, tokenIssuer, revocations)
+	introspectHandler := identity.NewIntrospectHandler(pgRepository, oauthClients, pgRepository, appConfig.RevocationCacheTTL)
+	userInfoHandler := identity.NewUserInfoHandler(pgRepository)
+
+	bearerAuth := middleware.NewBearerAuthMiddleware(revocations, middleware.UserTokens)
+
+	app.Use(middleware.ClientInfoMiddleware(ignoredProxyHeader(appConfig)))
+
+	rateLimiter, err := newRateLimiter(appConfig)
+	if err != nil {
+		zap.L().Fatal("Failed to configure rate limiting", zap.Error(err))
+	}
+	rateLimit := func(route, rules string) fiber.Handler {
+		parsed, err := ratelimit.ParseRules(rules)
+		if err != nil {
+			zap.L().Fatal("Invalid rate limit", zap.String("route", route), zap.Error(err))
+		}
+		return middleware.NewRateLimitMiddleware(rateLimiter, route, parsed)
+	}
 
-	bearerAuth := middleware.NewBearerAuthMiddleware(appConfig.JWTSecret)
+	// The passkey ceremonies share one budget, so a begin call counts
+	// towards the matching finish call.
+	passkeyLoginRateLimit := rateLimit("passkey_login", appConfig.RateLimitPasskeyLogin)
 
 	publicRoutes := app.Group("/")
-	publicRoutes.Post("/login", handle[identity.LoginRequest, identity.LoginResponse](loginHandler))
-	publicRoutes.Post("/register", handle[identity.RegisterRequest, identity.RegisterResponse](registerHandler))
-	publicRoutes.Post("/2fa/challenge", handle[identity.TwoFactorChallengeRequest, identity.TwoFactorChallengeResponse](twoFactorChallengeHandler))
+	publicRoutes.Get("/.well-known/jwks.json", handle[identity.JWKSRequest, identity.JWKSResponse](jwksHandler))
+	publicRoutes.Get("/.well-known/openid-configuration", handle[identity.OpenIDConfigurationRequest, identity.OpenIDConfigurationResponse](openIDConfigurationHandler))
+	publicRoutes.Get("/authorize", handleRedirect[identity.AuthorizeRequest](authorizeHandler))
+	publicRoutes.Post("/authorize", handleRedirect[identity.AuthorizeRequest](authorizeHandler))
+	publicRoutes.Post("/token", rateLimit("oauth_token", appConfig.RateLimitOAuthToken), middleware.NoStoreMiddleware(), handle[identity.OAuthTokenRequest, identity.OAuthTokenResponse](oauthTokenHandler))
+	publicRoutes.Post("/introspect", handleIntrospection[identity.IntrospectRequest](introspectHandler))
+	publicRoutes.Post("/login", rateLimit("login", appConfig.RateLimitLogin), handle[identity.LoginRequest, identity.LoginResponse](loginHandler))
+	publicRoutes.Post("/register", rateLimit("register", appConfig.RateLimitRegister), handle[identity.RegisterRequest, identity.RegisterResponse](registerHandler))
+	publicRoutes.Post("/password/forgot", rateLimit("password_forgot", appConfig.RateLimitPasswordForgot), handle[identity.ForgotPasswordRequest, identity.ForgotPasswordResponse](forgotPasswordHandler))
+	publicRoutes.Post("/password/reset", rateLimit("password_reset", appConfig.RateLimitPasswordReset), handle[identity.ResetPasswordRequest, identity.ResetPasswordResponse](resetPasswordHandler))
+	publicRoutes.Post("/email/verify", rateLimit("email_verify", appConfig.RateLimitEmailVerify), handle[identity.VerifyEmailRequest, identity.VerifyEmailResponse](verifyEmailHandler))
+	publicRoutes.Post("/token/refresh", rateLimit("token_refresh", appConfig.RateLimitTokenRefresh), handle[identity.RefreshTokenRequest, identity.RefreshTokenResponse](refreshTokenHandler))
+	publicRoutes.Post("/2fa/challenge", rateLimit("two_factor_challenge", appConfig.RateLimitTwoFactorChallenge), handle[identity.TwoFactorChallengeRequest, identity.TwoFactorChallengeResponse](twoFactorChallengeHandler))
+	publicRoutes.Post("/2fa/webauthn/begin", passkeyLoginRateLimit, handle[identity.BeginTwoFactorPasskeyRequest, identity.BeginTwoFactorPasskeyResponse](beginTwoFactorPasskeyHandler))
+	publicRoutes.Post("/webauthn/login/begin", passkeyLoginRateLimit, handle[identity.BeginPasskeyLoginRequest, identity.BeginPasskeyLoginResponse](beginPasskeyLoginHandler))
+	publicRoutes.Post("/webauthn/login/finish", passkeyLoginRateLimit, handle[identity.FinishPasskeyLoginRequest, identity.FinishPasskeyLoginResponse](finishPasskeyLoginHandler))
+
+	// /validate is the reverse proxy's forward-auth endpoint. It authenticates
+	// the token itself because public routes must pass without one.
+	app.Get("/validate", handleForwardAuth[identity.ValidateHandlerRequest](validateHandler))
 
 	privateRoutes := app.Group("/", bearerAuth)
-	privateRoutes.Get("/me", handle[identity.GetUserRequest, identity.GetUserResponse](getUserHandler))
-	privateRoutes.Get("/validate", middleware.SetResponseHeadersMiddleware(), handle[identity.ValidateHandlerRequest, identity.ValidateHandlerResponse](validateHandler))
-
-	tfaRoutes := privateRoutes.Group("/2fa")
+	privateRoutes.Get("/userinfo", handle[identity.UserInfoRequest, identity.UserInfoResponse](userInfoHandler))
+	privateRoutes.Post("/userinfo", handle[identity.UserInfoRequest, identity.UserInfoResponse](userInfoHandler))
+
+	// Everything below manages the account and only accepts first-party
+	// tokens, not those issued to OIDC clients.
+	accountRoutes := privateRoutes.Group("/", middleware.FirstPartyMiddleware())
+	accountRoutes.Get("/me", handle[identity.GetUserRequest, identity.GetUserResponse](getUserHandler))
+	accountRoutes.Patch("/me", handle[identity.UpdateProfileRequest, identity.UpdateProfileResponse](updateProfileHandler))
+	accountRoutes.Post("/me/password", handle[identity.ChangePasswordRequest, identity.ChangePasswordResponse](changePasswordHandler))
+	accountRoutes.Get("/me/sessions", handle[identity.ListSessionsRequest, identity.ListSessionsResponse](listSessionsHandler))
+	accountRoutes.Delete("/me/sessions/:id", handle[identity.RevokeSessionRequest, identity.RevokeSessionResponse](revokeSessionHandler))
+	accountRoutes.Get("/me/devices", handle[identity.ListTrustedDevicesRequest, identity.ListTrustedDevicesResponse](listTrustedDevicesHandler))
+	accountRoutes.Delete("/me/devices/:id", handle[identity.RevokeTrustedDeviceRequest, identity.RevokeTrustedDeviceResponse](revokeTrustedDeviceHandler))
+	accountRoutes.Post("/email/resend", handle[identity.ResendVerificationEmailRequest, identity.ResendVerificationEmailResponse](resendVerificationEmailHandler))
+	accountRoutes.Post("/logout", handle[identity.LogoutRequest, identity.LogoutResponse](logoutHandler))
+	accountRoutes.Post("/logout-all", handle[identity.LogoutAllRequest, identity.LogoutAllResponse](logoutAllHandler))
+	accountRoutes.Post("/authorize/approve", handle[identity.ApproveAuthorizationRequest, identity.ApproveAuthorizationResponse](approveAuthorizationHandler))
+
+	adminRoutes := accountRoutes.Group("/admin")
+	adminRoutes.Get("/roles", middleware.RequirePermission("roles:assign"), handle[identity.ListRolesRequest, identity.ListRolesResponse](listRolesHandler))
+	adminRoutes.Get("/users/:id/roles", middleware.RequirePermission("users:read"), handle[identity.ListUserRolesRequest, identity.ListUserRolesResponse](listUserRolesHandler))
+	adminRoutes.Put("/users/:id/roles/:role", middleware.RequirePermission("roles:assign"), handle[identity.GrantRoleRequest, identity.GrantRoleResponse](grantRoleHandler))
+	adminRoutes.Delete("/users/:id/roles/:role", middleware.RequirePermission("roles:assign"), handle[identity.RevokeRoleRequest, identity.RevokeRoleResponse](revokeRoleHandler))
+
+	webAuthnRoutes := accountRoutes.Group("/webauthn")
+	webAuthnRoutes.Post("/register/begin", handle[identity.BeginPasskeyRegistrationRequest, identity.BeginPasskeyRegistrationResponse](beginPasskeyRegistrationHandler))
+	webAuthnRoutes.Post("/register/finish", handle[identity.FinishPasskeyRegistrationRequest, identity.FinishPasskeyRegistrationResponse](finishPasskeyRegistrationHandler))
+	webAuthnRoutes.Get("/credentials", handle[identity.ListPasskeysRequest, identity.ListPasskeysResponse](listPasskeysHandler))
+	webAuthnRoutes.Delete("/credentials/:id", handle[identity.DeletePasskeyRequest, identity.DeletePasskeyResponse](deletePasskeyHandler))
+
+	tfaRoutes := accountRoutes.Group("/2fa")
 	tfaRoutes.Post("/enable", handle[identity.EnableTwoFactorRequest, identity.EnableTwoFactorResponse](enableTwoFactorHandler))
 	tfaRoutes.Post("/disable", handle[identity.DisableTwoFactorRequest, identity.DisableTwoFactorResponse](disableTwoFactorHandler))
+	tfaRoutes.Post("/webauthn/enable", handle[identity.EnablePasskeySecondFactorRequest, identity.EnablePasskeySecondFactorResponse](enablePasskeySecondFactorHandler))
+	tfaRoutes.Post("/webauthn/disable", handle[identity.DisablePasskeySecondFactorRequest, identity.DisablePasskeySecondFactorResponse](disablePasskeySecondFactorHandler))
 	tfaRoutes.Post("/verify", handle[identity.VerifyTwoFactorRequest, identity.VerifyTwoFactorResponse](verifyTwoFactorHandler))
 	tfaRoutes.Get("/recovery-codes", handle[identity.GetRecoveryCodesRequest, identity.GetRecoveryCodesResponse](getRecoveryCodesHandler))
+	tfaRoutes.Post("/recovery-codes/regenerate", handle[identity.RegenerateRecoveryCodesRequest, identity.RegenerateRecoveryCodesResponse](regenerateRecoveryCodesHandler))
 
 	// Start server in a goroutine
 	go func() {
@@ -133,6 +404,86 @@ func main() {
 	gracefulShutdown(app)
 }
 
+// loadKeyStore reads the signing keys from JWT_KEYS_DIR. Without a directory an
+// ephemeral Ed25519 key is generated, which is only suitable for development:
+// tokens stop verifying on restart and other replicas cannot validate them.
+func loadKeyStore(appConfig *config.AppConfig) (*jwt.KeyStore, error) {
+	if appConfig.JWTKeysDir != "" {
+		return jwt.LoadKeyStore(appConfig.JWTKeysDir, appConfig.JWTSigningKeyID)
+	}
+
+	zap.L().Warn("JWT_KEYS_DIR is not set, generating an ephemeral signing key")
+
+	key, err := jwt.GenerateEd25519Key("ephemeral")
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.NewKeyStore(key.ID, key)
+}
+
+// newRateLimiter counts requests in process, or in Redis when several
+// replicas must share the limits.
+func newRateLimiter(appConfig *config.AppConfig) (*ratelimit.Limiter, error) {
+	switch appConfig.RateLimitStore {
+	case "memory":
+		return ratelimit.NewLimiter(ratelimit.NewMemoryStore()), nil
+	case "redis":
+		options, err := redis.ParseURL(appConfig.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		return ratelimit.NewLimiter(ratelimit.NewRedisStore(redis.NewClient(options), "ratelimit:")), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", appConfig.RateLimitStore)
+	}
+}
+
+// proxyHeader returns the header carrying the client IP, which is only
+// trusted when TRUSTED_PROXIES is set.
+func proxyHeader(appConfig *config.AppConfig) string {
+	if appConfig.TrustedProxies == "" {
+		return ""
+	}
+	return appConfig.ProxyHeader
+}
+
+// ignoredProxyHeader returns the client IP header that is ignored because no
+// proxy is trusted, so its presence can be reported.
+func ignoredProxyHeader(appConfig *config.AppConfig) string {
+	if appConfig.TrustedProxies != "" {
+		return ""
+	}
+	return appConfig.ProxyHeader
+}
+
+func lockoutSettings(appConfig *config.AppConfig) identity.LockoutSettings {
+	return identity.LockoutSettings{
+		Account: identity.LockoutLimits{
+			FreeAttempts: appConfig.LockoutAccountFreeAttempts,
+			Threshold:    appConfig.LockoutAccountThreshold,
+		},
+		IP: identity.LockoutLimits{
+			FreeAttempts: appConfig.LockoutIPFreeAttempts,
+			Threshold:    appConfig.LockoutIPThreshold,
+		},
+		BaseDelay: appConfig.LockoutBaseDelay,
+		Duration:  appConfig.LockoutDuration,
+		Window:    appConfig.LockoutWindow,
+	}
+}
+
+// splitList parses a comma-separated setting, ignoring blank entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func gracefulShutdown(app *fiber.App) {
 	// Create channel for shutdown signals
 	sigChan := make(chan os.Signal, 1)
@@ -171,6 +522,21 @@ func writeError(c *fiber.Ctx, err error) error {
 		return c.Status(httpErr.Status).JSON(payload)
 	}
 
+	var oauthErr *httperror.OAuthError
+	if errors.As(err, &oauthErr) {
+		if oauthErr.Status >= fiber.StatusInternalServerError {
+			zap.L().Error("Handler returned server error", zap.String("code", oauthErr.Code), zap.Error(oauthErr))
+		} else {
+			zap.L().Warn("Handler returned client error", zap.String("code", oauthErr.Code), zap.Error(oauthErr))
+		}
+
+		if oauthErr.Status == fiber.StatusUnauthorized {
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="identity"`)
+		}
+
+		return c.Status(oauthErr.Status).JSON(oauthErr)
+	}
+
 	var fiberErr *fiber.Error
 	if errors.As(err, &fiberErr) {
 		zap.L().Warn("Fiber validation error", zap.String("message", fiberErr.Message), zap.Error(err))
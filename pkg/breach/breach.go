@@ -0,0 +1,97 @@
+// Package breach answers whether a password appears in a Have I Been Pwned
+// style corpus without calling any external service. The corpus is compiled
+// by `identityctl build-breach-index` into a file of fixed-size records sorted
+// by SHA-1, which is binary searched on disk.
+package breach
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// magic identifies the index format and its version.
+var magic = []byte("BRCHIDX1")
+
+const (
+	hashSize   = sha1.Size
+	recordSize = hashSize + 4
+)
+
+var ErrInvalidIndex = errors.New("breach: invalid index file")
+
+// Index is an opened breach index. It is safe for concurrent use.
+type Index struct {
+	file    *os.File
+	records int64
+}
+
+func Open(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header, magic) {
+		file.Close()
+		return nil, ErrInvalidIndex
+	}
+
+	size := info.Size() - int64(len(magic))
+	if size%recordSize != 0 {
+		file.Close()
+		return nil, ErrInvalidIndex
+	}
+
+	return &Index{file: file, records: size / recordSize}, nil
+}
+
+// Len returns the number of hashes in the index.
+func (i *Index) Len() int64 {
+	return i.records
+}
+
+// Count returns how often the password was seen in the corpus, or 0 when it
+// is absent.
+func (i *Index) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	return i.lookup(sum)
+}
+
+func (i *Index) lookup(hash [hashSize]byte) (int, error) {
+	record := make([]byte, recordSize)
+
+	low, high := int64(0), i.records
+	for low < high {
+		mid := low + (high-low)/2
+		if _, err := i.file.ReadAt(record, int64(len(magic))+mid*recordSize); err != nil {
+			return 0, fmt.Errorf("breach: read record %d: %w", mid, err)
+		}
+
+		switch bytes.Compare(record[:hashSize], hash[:]) {
+		case 0:
+			return int(binary.BigEndian.Uint32(record[hashSize:])), nil
+		case -1:
+			low = mid + 1
+		default:
+			high = mid
+		}
+	}
+
+	return 0, nil
+}
+
+func (i *Index) Close() error {
+	return i.file.Close()
+}
@@ -0,0 +1,137 @@
+package breach
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildIndex compiles an index with fill and returns its path.
+func buildIndex(t *testing.T, fill func(w *Writer) error) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fill(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "breach.idx")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestIndexCount(t *testing.T) {
+	sources := []struct {
+		name string
+		fill func(w *Writer) error
+	}{
+		{"hash list", func(w *Writer) error {
+			file, err := os.Open("testdata/ordered-by-hash.txt")
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return w.AddHashList(file)
+		}},
+		{"range files", func(w *Writer) error { return w.AddRangeDir("testdata/ranges") }},
+	}
+
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"password", 9545824},
+		{"123456", 37359195},
+		{"qwerty", 3912816},
+		{"hunter2", 17043},
+		{"correct horse battery staple", 0},
+		{"", 0},
+	}
+
+	for _, source := range sources {
+		t.Run(source.name, func(t *testing.T) {
+			index, err := Open(buildIndex(t, source.fill))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer index.Close()
+
+			if index.Len() != 4 {
+				t.Fatalf("Len = %d, want 4", index.Len())
+			}
+			for _, tt := range tests {
+				count, err := index.Count(tt.password)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if count != tt.want {
+					t.Fatalf("Count(%q) = %d, want %d", tt.password, count, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenMalformedIndex(t *testing.T) {
+	valid, err := os.ReadFile(buildIndex(t, func(w *Writer) error { return w.AddRangeDir("testdata/ranges") }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		contents []byte
+	}{
+		{"empty", nil},
+		{"header only, truncated", magic[:4]},
+		{"other format", append([]byte("BRCHIDX0"), valid[len(magic):]...)},
+		{"truncated record", valid[:len(valid)-1]},
+		{"plain hash list", []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "breach.idx")
+			if err := os.WriteFile(path, tt.contents, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if index, err := Open(path); !errors.Is(err, ErrInvalidIndex) {
+				if index != nil {
+					index.Close()
+				}
+				t.Fatalf("got %v, want ErrInvalidIndex", err)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := Open(filepath.Join(t.TempDir(), "missing.idx")); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("got %v, want os.ErrNotExist", err)
+		}
+	})
+}
+
+func TestWriterRejectsUnsortedInput(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := "7C4A8D09CA3762AF61E59520943DC26494F8941B:37359195\n5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n"
+	if err := w.AddHashList(bytes.NewBufferString(list)); err == nil {
+		t.Fatal("accepted hashes out of order")
+	}
+}
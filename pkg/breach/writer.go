@@ -0,0 +1,170 @@
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rangePrefixLength is the number of hex characters in a range file name,
+// as served by the HIBP range API (/range/{prefix}).
+const rangePrefixLength = 5
+
+// Writer compiles an index from hashes added in ascending order. Both HIBP
+// downloads (the "ordered by hash" list and the range API files) are already
+// sorted, so the corpus never has to fit in memory.
+type Writer struct {
+	out     *bufio.Writer
+	last    []byte
+	written int64
+}
+
+func NewWriter(w io.Writer) (*Writer, error) {
+	out := bufio.NewWriterSize(w, 1<<20)
+	if _, err := out.Write(magic); err != nil {
+		return nil, err
+	}
+
+	return &Writer{out: out}, nil
+}
+
+// Add appends one hash with its breach count. Hashes must be strictly
+// increasing.
+func (w *Writer) Add(hash []byte, count uint32) error {
+	if len(hash) != hashSize {
+		return fmt.Errorf("breach: hash must be %d bytes", hashSize)
+	}
+	if w.last != nil && bytes.Compare(hash, w.last) <= 0 {
+		return fmt.Errorf("breach: hash %X is out of order; input must be sorted by hash", hash)
+	}
+
+	record := make([]byte, recordSize)
+	copy(record, hash)
+	binary.BigEndian.PutUint32(record[hashSize:], count)
+	if _, err := w.out.Write(record); err != nil {
+		return err
+	}
+
+	w.last = record[:hashSize]
+	w.written++
+	return nil
+}
+
+// Written returns the number of hashes added so far.
+func (w *Writer) Written() int64 {
+	return w.written
+}
+
+// Flush writes any buffered records to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.out.Flush()
+}
+
+// AddHashList adds every "HASH:COUNT" line of a full-hash list such as
+// pwned-passwords-sha1-ordered-by-hash.txt.
+func (w *Writer) AddHashList(r io.Reader) error {
+	return w.addLines(r, "")
+}
+
+// AddRangeDir adds every range file in dir. Each file is named after a
+// five-character hash prefix and holds "SUFFIX:COUNT" lines.
+func (w *Writer) AddRangeDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var prefixes []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if entry.IsDir() || len(name) != rangePrefixLength {
+			continue
+		}
+		if _, err := hex.DecodeString(name + "0"); err != nil {
+			continue
+		}
+		prefixes = append(prefixes, entry.Name())
+	}
+	sort.Slice(prefixes, func(a, b int) bool {
+		return strings.ToUpper(prefixes[a]) < strings.ToUpper(prefixes[b])
+	})
+
+	for _, name := range prefixes {
+		if err := w.addRangeFile(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) addRangeFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	prefix := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if err := w.addLines(file, prefix); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (w *Writer) addLines(r io.Reader, prefix string) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		hash, count, err := parseLine(prefix, text)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		// Padded range responses list fake suffixes with a zero count.
+		if count == 0 {
+			continue
+		}
+		if err := w.Add(hash, count); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseLine decodes "HEX" or "HEX:COUNT". A missing count is treated as 1
+// so plain hash lists can be imported too.
+func parseLine(prefix, text string) ([]byte, uint32, error) {
+	digest, countText, hasCount := strings.Cut(text, ":")
+
+	hash, err := hex.DecodeString(prefix + digest)
+	if err != nil || len(hash) != hashSize {
+		return nil, 0, fmt.Errorf("breach: invalid SHA-1 %q", prefix+digest)
+	}
+
+	if !hasCount {
+		return hash, 1, nil
+	}
+
+	count, err := strconv.ParseUint(countText, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("breach: invalid count %q", countText)
+	}
+
+	return hash, uint32(min(count, math.MaxUint32)), nil
+}
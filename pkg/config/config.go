@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,8 +16,91 @@ type AppConfig struct {
 	PostgresHost     string `mapstructure:"POSTGRES_HOST"`
 	PostgresPort     string `mapstructure:"POSTGRES_PORT"`
 	RabbitMQURL      string `mapstructure:"RABBITMQ_URL"`
-	JWTSecret        string `mapstructure:"JWT_SECRET"`
 	ServiceName      string `mapstructure:"SERVICE_NAME"`
+
+	JWTKeysDir       string        `mapstructure:"JWT_KEYS_DIR"`
+	JWTSigningKeyID  string        `mapstructure:"JWT_SIGNING_KEY_ID"`
+	JWTAccessTTL     time.Duration `mapstructure:"JWT_ACCESS_TTL"`
+	RefreshTokenTTL  time.Duration `mapstructure:"REFRESH_TOKEN_TTL"`
+	MFATokenTTL      time.Duration `mapstructure:"MFA_TOKEN_TTL"`
+	TrustedDeviceTTL time.Duration `mapstructure:"TRUSTED_DEVICE_TTL"`
+
+	OIDCIssuer           string        `mapstructure:"OIDC_ISSUER"`
+	OIDCLoginURL         string        `mapstructure:"OIDC_LOGIN_URL"`
+	AuthorizationCodeTTL time.Duration `mapstructure:"AUTHORIZATION_CODE_TTL"`
+
+	RevocationCacheTTL time.Duration `mapstructure:"REVOCATION_CACHE_TTL"`
+
+	SecretMasterKeys       string `mapstructure:"SECRET_MASTER_KEYS"`
+	SecretMasterKeysFile   string `mapstructure:"SECRET_MASTER_KEYS_FILE"`
+	SecretMasterKeyVersion string `mapstructure:"SECRET_MASTER_KEY_VERSION"`
+
+	TOTPAlgorithm    string `mapstructure:"TOTP_ALGORITHM"`
+	TOTPPeriod       int    `mapstructure:"TOTP_PERIOD"`
+	TOTPDigits       int    `mapstructure:"TOTP_DIGITS"`
+	TOTPWindow       int    `mapstructure:"TOTP_WINDOW"`
+	HOTPLookAhead    int    `mapstructure:"HOTP_LOOK_AHEAD"`
+	HOTPResyncWindow int    `mapstructure:"HOTP_RESYNC_WINDOW"`
+
+	MailerDriver  string `mapstructure:"MAILER_DRIVER"`
+	MailFrom      string `mapstructure:"MAIL_FROM"`
+	MailerFileDir string `mapstructure:"MAILER_FILE_DIR"`
+	SMTPHost      string `mapstructure:"SMTP_HOST"`
+	SMTPPort      string `mapstructure:"SMTP_PORT"`
+	SMTPUsername  string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword  string `mapstructure:"SMTP_PASSWORD"`
+
+	EmailVerificationURL string        `mapstructure:"EMAIL_VERIFICATION_URL"`
+	EmailVerificationTTL time.Duration `mapstructure:"EMAIL_VERIFICATION_TTL"`
+	PasswordResetURL     string        `mapstructure:"PASSWORD_RESET_URL"`
+	PasswordResetTTL     time.Duration `mapstructure:"PASSWORD_RESET_TTL"`
+
+	WebAuthnRPID          string        `mapstructure:"WEBAUTHN_RP_ID"`
+	WebAuthnRPDisplayName string        `mapstructure:"WEBAUTHN_RP_DISPLAY_NAME"`
+	WebAuthnRPOrigins     string        `mapstructure:"WEBAUTHN_RP_ORIGINS"`
+	WebAuthnSessionTTL    time.Duration `mapstructure:"WEBAUTHN_SESSION_TTL"`
+
+	PasswordHashAlgorithm string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	Argon2Memory          uint32 `mapstructure:"ARGON2_MEMORY"`
+	Argon2Iterations      uint32 `mapstructure:"ARGON2_ITERATIONS"`
+	Argon2Parallelism     uint8  `mapstructure:"ARGON2_PARALLELISM"`
+	Argon2SaltLength      uint32 `mapstructure:"ARGON2_SALT_LENGTH"`
+	Argon2KeyLength       uint32 `mapstructure:"ARGON2_KEY_LENGTH"`
+	BcryptCost            int    `mapstructure:"BCRYPT_COST"`
+
+	PasswordMinLength int `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxBytes  int `mapstructure:"PASSWORD_MAX_BYTES"`
+	PasswordMinScore  int `mapstructure:"PASSWORD_MIN_SCORE"`
+
+	BreachedPasswordsFile     string `mapstructure:"BREACHED_PASSWORDS_FILE"`
+	BreachedPasswordsMinCount int    `mapstructure:"BREACHED_PASSWORDS_MIN_COUNT"`
+
+	LockoutAccountFreeAttempts int           `mapstructure:"LOCKOUT_ACCOUNT_FREE_ATTEMPTS"`
+	LockoutAccountThreshold    int           `mapstructure:"LOCKOUT_ACCOUNT_THRESHOLD"`
+	LockoutIPFreeAttempts      int           `mapstructure:"LOCKOUT_IP_FREE_ATTEMPTS"`
+	LockoutIPThreshold         int           `mapstructure:"LOCKOUT_IP_THRESHOLD"`
+	LockoutBaseDelay           time.Duration `mapstructure:"LOCKOUT_BASE_DELAY"`
+	LockoutDuration            time.Duration `mapstructure:"LOCKOUT_DURATION"`
+	LockoutWindow              time.Duration `mapstructure:"LOCKOUT_WINDOW"`
+
+	TrustedProxies string `mapstructure:"TRUSTED_PROXIES"`
+	ProxyHeader    string `mapstructure:"PROXY_HEADER"`
+
+	ForwardAuthPoliciesFile string `mapstructure:"FORWARD_AUTH_POLICIES_FILE"`
+	ForwardAuthHeaders      string `mapstructure:"FORWARD_AUTH_HEADERS"`
+
+	RateLimitStore string `mapstructure:"RATE_LIMIT_STORE"`
+	RedisURL       string `mapstructure:"REDIS_URL"`
+
+	RateLimitLogin              string `mapstructure:"RATE_LIMIT_LOGIN"`
+	RateLimitRegister           string `mapstructure:"RATE_LIMIT_REGISTER"`
+	RateLimitTwoFactorChallenge string `mapstructure:"RATE_LIMIT_TWO_FACTOR_CHALLENGE"`
+	RateLimitPasswordForgot     string `mapstructure:"RATE_LIMIT_PASSWORD_FORGOT"`
+	RateLimitPasswordReset      string `mapstructure:"RATE_LIMIT_PASSWORD_RESET"`
+	RateLimitEmailVerify        string `mapstructure:"RATE_LIMIT_EMAIL_VERIFY"`
+	RateLimitPasskeyLogin       string `mapstructure:"RATE_LIMIT_PASSKEY_LOGIN"`
+	RateLimitTokenRefresh       string `mapstructure:"RATE_LIMIT_TOKEN_REFRESH"`
+	RateLimitOAuthToken         string `mapstructure:"RATE_LIMIT_OAUTH_TOKEN"`
 }
 
 func Read() *AppConfig {
@@ -48,7 +132,74 @@ func bindEnvVariables() {
 	_ = viper.BindEnv("POSTGRES_PORT")
 	_ = viper.BindEnv("RABBITMQ_URL")
 	_ = viper.BindEnv("SERVICE_NAME")
-	_ = viper.BindEnv("JWT_SECRET")
+	_ = viper.BindEnv("JWT_KEYS_DIR")
+	_ = viper.BindEnv("JWT_SIGNING_KEY_ID")
+	_ = viper.BindEnv("JWT_ACCESS_TTL")
+	_ = viper.BindEnv("REFRESH_TOKEN_TTL")
+	_ = viper.BindEnv("MFA_TOKEN_TTL")
+	_ = viper.BindEnv("TRUSTED_DEVICE_TTL")
+	_ = viper.BindEnv("OIDC_ISSUER")
+	_ = viper.BindEnv("OIDC_LOGIN_URL")
+	_ = viper.BindEnv("AUTHORIZATION_CODE_TTL")
+	_ = viper.BindEnv("REVOCATION_CACHE_TTL")
+	_ = viper.BindEnv("SECRET_MASTER_KEYS")
+	_ = viper.BindEnv("SECRET_MASTER_KEYS_FILE")
+	_ = viper.BindEnv("SECRET_MASTER_KEY_VERSION")
+	_ = viper.BindEnv("TOTP_ALGORITHM")
+	_ = viper.BindEnv("TOTP_PERIOD")
+	_ = viper.BindEnv("TOTP_DIGITS")
+	_ = viper.BindEnv("TOTP_WINDOW")
+	_ = viper.BindEnv("HOTP_LOOK_AHEAD")
+	_ = viper.BindEnv("HOTP_RESYNC_WINDOW")
+	_ = viper.BindEnv("MAILER_DRIVER")
+	_ = viper.BindEnv("MAIL_FROM")
+	_ = viper.BindEnv("MAILER_FILE_DIR")
+	_ = viper.BindEnv("SMTP_HOST")
+	_ = viper.BindEnv("SMTP_PORT")
+	_ = viper.BindEnv("SMTP_USERNAME")
+	_ = viper.BindEnv("SMTP_PASSWORD")
+	_ = viper.BindEnv("EMAIL_VERIFICATION_URL")
+	_ = viper.BindEnv("EMAIL_VERIFICATION_TTL")
+	_ = viper.BindEnv("PASSWORD_RESET_URL")
+	_ = viper.BindEnv("PASSWORD_RESET_TTL")
+	_ = viper.BindEnv("WEBAUTHN_RP_ID")
+	_ = viper.BindEnv("WEBAUTHN_RP_DISPLAY_NAME")
+	_ = viper.BindEnv("WEBAUTHN_RP_ORIGINS")
+	_ = viper.BindEnv("WEBAUTHN_SESSION_TTL")
+	_ = viper.BindEnv("PASSWORD_HASH_ALGORITHM")
+	_ = viper.BindEnv("ARGON2_MEMORY")
+	_ = viper.BindEnv("ARGON2_ITERATIONS")
+	_ = viper.BindEnv("ARGON2_PARALLELISM")
+	_ = viper.BindEnv("ARGON2_SALT_LENGTH")
+	_ = viper.BindEnv("ARGON2_KEY_LENGTH")
+	_ = viper.BindEnv("BCRYPT_COST")
+	_ = viper.BindEnv("PASSWORD_MIN_LENGTH")
+	_ = viper.BindEnv("PASSWORD_MAX_BYTES")
+	_ = viper.BindEnv("PASSWORD_MIN_SCORE")
+	_ = viper.BindEnv("BREACHED_PASSWORDS_FILE")
+	_ = viper.BindEnv("BREACHED_PASSWORDS_MIN_COUNT")
+	_ = viper.BindEnv("LOCKOUT_ACCOUNT_FREE_ATTEMPTS")
+	_ = viper.BindEnv("LOCKOUT_ACCOUNT_THRESHOLD")
+	_ = viper.BindEnv("LOCKOUT_IP_FREE_ATTEMPTS")
+	_ = viper.BindEnv("LOCKOUT_IP_THRESHOLD")
+	_ = viper.BindEnv("LOCKOUT_BASE_DELAY")
+	_ = viper.BindEnv("LOCKOUT_DURATION")
+	_ = viper.BindEnv("LOCKOUT_WINDOW")
+	_ = viper.BindEnv("TRUSTED_PROXIES")
+	_ = viper.BindEnv("PROXY_HEADER")
+	_ = viper.BindEnv("FORWARD_AUTH_POLICIES_FILE")
+	_ = viper.BindEnv("FORWARD_AUTH_HEADERS")
+	_ = viper.BindEnv("RATE_LIMIT_STORE")
+	_ = viper.BindEnv("REDIS_URL")
+	_ = viper.BindEnv("RATE_LIMIT_LOGIN")
+	_ = viper.BindEnv("RATE_LIMIT_REGISTER")
+	_ = viper.BindEnv("RATE_LIMIT_TWO_FACTOR_CHALLENGE")
+	_ = viper.BindEnv("RATE_LIMIT_PASSWORD_FORGOT")
+	_ = viper.BindEnv("RATE_LIMIT_PASSWORD_RESET")
+	_ = viper.BindEnv("RATE_LIMIT_EMAIL_VERIFY")
+	_ = viper.BindEnv("RATE_LIMIT_PASSKEY_LOGIN")
+	_ = viper.BindEnv("RATE_LIMIT_TOKEN_REFRESH")
+	_ = viper.BindEnv("RATE_LIMIT_OAUTH_TOKEN")
 }
 
 func setDefaults() {
@@ -57,5 +208,64 @@ func setDefaults() {
 	viper.SetDefault("POSTGRES_HOST", "localhost")
 	viper.SetDefault("POSTGRES_PORT", "5432")
 	viper.SetDefault("SERVICE_NAME", "auction")
-	viper.SetDefault("JWT_SECRET", "change-me")
+	viper.SetDefault("JWT_ACCESS_TTL", "15m")
+	viper.SetDefault("REFRESH_TOKEN_TTL", "720h")
+	viper.SetDefault("MFA_TOKEN_TTL", "5m")
+	viper.SetDefault("TRUSTED_DEVICE_TTL", "720h")
+	viper.SetDefault("OIDC_ISSUER", "http://localhost:8080")
+	viper.SetDefault("OIDC_LOGIN_URL", "http://localhost:3000/authorize")
+	viper.SetDefault("AUTHORIZATION_CODE_TTL", "1m")
+	viper.SetDefault("REVOCATION_CACHE_TTL", "30s")
+	viper.SetDefault("TOTP_ALGORITHM", "SHA1")
+	viper.SetDefault("TOTP_PERIOD", 30)
+	viper.SetDefault("TOTP_DIGITS", 6)
+	viper.SetDefault("TOTP_WINDOW", 1)
+	viper.SetDefault("HOTP_LOOK_AHEAD", 10)
+	viper.SetDefault("HOTP_RESYNC_WINDOW", 100)
+	viper.SetDefault("MAILER_DRIVER", "log")
+	viper.SetDefault("MAIL_FROM", "Auction <no-reply@localhost>")
+	viper.SetDefault("MAILER_FILE_DIR", "mail")
+	viper.SetDefault("SMTP_PORT", "587")
+	viper.SetDefault("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email")
+	viper.SetDefault("EMAIL_VERIFICATION_TTL", "24h")
+	viper.SetDefault("PASSWORD_RESET_URL", "http://localhost:3000/reset-password")
+	viper.SetDefault("PASSWORD_RESET_TTL", "1h")
+	viper.SetDefault("WEBAUTHN_RP_ID", "localhost")
+	viper.SetDefault("WEBAUTHN_RP_DISPLAY_NAME", "Auction")
+	viper.SetDefault("WEBAUTHN_RP_ORIGINS", "http://localhost:8080")
+	viper.SetDefault("WEBAUTHN_SESSION_TTL", "5m")
+	viper.SetDefault("PASSWORD_HASH_ALGORITHM", "argon2id")
+	viper.SetDefault("ARGON2_MEMORY", 64*1024)
+	viper.SetDefault("ARGON2_ITERATIONS", 3)
+	viper.SetDefault("ARGON2_PARALLELISM", 2)
+	viper.SetDefault("ARGON2_SALT_LENGTH", 16)
+	viper.SetDefault("ARGON2_KEY_LENGTH", 32)
+	viper.SetDefault("BCRYPT_COST", 12)
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 10)
+	viper.SetDefault("PASSWORD_MAX_BYTES", 256)
+	viper.SetDefault("PASSWORD_MIN_SCORE", 3)
+	viper.SetDefault("BREACHED_PASSWORDS_FILE", "")
+	viper.SetDefault("BREACHED_PASSWORDS_MIN_COUNT", 1)
+	viper.SetDefault("LOCKOUT_ACCOUNT_FREE_ATTEMPTS", 5)
+	viper.SetDefault("LOCKOUT_ACCOUNT_THRESHOLD", 10)
+	viper.SetDefault("LOCKOUT_IP_FREE_ATTEMPTS", 20)
+	viper.SetDefault("LOCKOUT_IP_THRESHOLD", 100)
+	viper.SetDefault("LOCKOUT_BASE_DELAY", "1s")
+	viper.SetDefault("LOCKOUT_DURATION", "15m")
+	viper.SetDefault("LOCKOUT_WINDOW", "1h")
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("PROXY_HEADER", "X-Forwarded-For")
+	viper.SetDefault("FORWARD_AUTH_POLICIES_FILE", "")
+	viper.SetDefault("FORWARD_AUTH_HEADERS", "user_id:User-ID,email:User-Email,name:User-Name,client_id:Client-ID,token:Authorization")
+	viper.SetDefault("RATE_LIMIT_STORE", "memory")
+	viper.SetDefault("REDIS_URL", "redis://localhost:6379/0")
+	viper.SetDefault("RATE_LIMIT_LOGIN", "ip:20/1m,email:10/15m")
+	viper.SetDefault("RATE_LIMIT_REGISTER", "ip:10/1h")
+	viper.SetDefault("RATE_LIMIT_TWO_FACTOR_CHALLENGE", "ip:20/1m")
+	viper.SetDefault("RATE_LIMIT_PASSWORD_FORGOT", "ip:5/15m,email:3/1h")
+	viper.SetDefault("RATE_LIMIT_PASSWORD_RESET", "ip:10/15m")
+	viper.SetDefault("RATE_LIMIT_EMAIL_VERIFY", "ip:20/15m")
+	viper.SetDefault("RATE_LIMIT_PASSKEY_LOGIN", "ip:30/1m")
+	viper.SetDefault("RATE_LIMIT_TOKEN_REFRESH", "ip:60/1m")
+	viper.SetDefault("RATE_LIMIT_OAUTH_TOKEN", "ip:60/1m")
 }
@@ -0,0 +1,116 @@
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+const dataKeySize = 32
+
+var ErrMalformedCiphertext = errors.New("envelope: malformed ciphertext")
+
+// KeyProvider wraps and unwraps data keys with a master key. Versions identify
+// master keys so rows encrypted before a rotation can still be opened. The
+// local provider keeps master keys in configuration; a KMS-backed provider can
+// implement the same interface.
+type KeyProvider interface {
+	CurrentVersion() string
+	WrapKey(ctx context.Context, version string, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, version string, wrapped []byte) ([]byte, error)
+}
+
+// Sealed is an encrypted value together with the wrapped data key that
+// encrypts it and the master key version that wraps the data key. Both byte
+// fields are base64 encoded for storage in text columns.
+type Sealed struct {
+	Ciphertext string
+	DataKey    string
+	KeyVersion string
+}
+
+// Seal encrypts plaintext with a fresh AES-256-GCM data key and wraps that key
+// with the provider's current master key. aad binds the ciphertext to its
+// context (e.g. the owning user ID) so it cannot be moved to another row.
+func Seal(ctx context.Context, provider KeyProvider, plaintext, aad []byte) (Sealed, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return Sealed{}, err
+	}
+
+	ciphertext, err := encrypt(dataKey, plaintext, aad)
+	if err != nil {
+		return Sealed{}, err
+	}
+
+	version := provider.CurrentVersion()
+	wrapped, err := provider.WrapKey(ctx, version, dataKey)
+	if err != nil {
+		return Sealed{}, err
+	}
+
+	return Sealed{
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+		DataKey:    base64.StdEncoding.EncodeToString(wrapped),
+		KeyVersion: version,
+	}, nil
+}
+
+func Open(ctx context.Context, provider KeyProvider, sealed Sealed, aad []byte) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(sealed.DataKey)
+	if err != nil {
+		return nil, ErrMalformedCiphertext
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(sealed.Ciphertext)
+	if err != nil {
+		return nil, ErrMalformedCiphertext
+	}
+
+	dataKey, err := provider.UnwrapKey(ctx, sealed.KeyVersion, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return decrypt(dataKey, ciphertext, aad)
+}
+
+// encrypt returns nonce || AES-GCM(key, plaintext).
+func encrypt(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func decrypt(key, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrMalformedCiphertext
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
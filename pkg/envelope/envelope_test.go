@@ -0,0 +1,155 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, dataKeySize)
+}
+
+func newTestProvider(t *testing.T, current string, keys map[string][]byte) *LocalKeyProvider {
+	t.Helper()
+
+	provider, err := NewLocalKeyProvider(current, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestProvider(t, "v1", map[string][]byte{"v1": testKey(1)})
+
+	sealed, err := Seal(ctx, provider, []byte("JBSWY3DPEHPK3PXP"), []byte("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed.KeyVersion != "v1" {
+		t.Fatalf("sealed under %q, want v1", sealed.KeyVersion)
+	}
+
+	plaintext, err := Open(ctx, provider, sealed, []byte("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("got %q", plaintext)
+	}
+
+	again, err := Seal(ctx, provider, []byte("JBSWY3DPEHPK3PXP"), []byte("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Ciphertext == sealed.Ciphertext || again.DataKey == sealed.DataKey {
+		t.Fatal("sealing twice reused the data key or nonce")
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestProvider(t, "v1", map[string][]byte{"v1": testKey(1)})
+
+	sealed, err := Seal(ctx, provider, []byte("JBSWY3DPEHPK3PXP"), []byte("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flip := func(encoded string) string {
+		raw, _ := base64.StdEncoding.DecodeString(encoded)
+		raw[len(raw)-1] ^= 0x01
+		return base64.StdEncoding.EncodeToString(raw)
+	}
+
+	tests := []struct {
+		name     string
+		provider KeyProvider
+		sealed   Sealed
+		aad      string
+		want     error
+	}{
+		{"wrong master key", newTestProvider(t, "v1", map[string][]byte{"v1": testKey(2)}), sealed, "user-1", nil},
+		{"unknown key version", newTestProvider(t, "v2", map[string][]byte{"v2": testKey(1)}), sealed, "user-1", ErrUnknownKeyVersion},
+		{"other user", provider, sealed, "user-2", nil},
+		{"tampered ciphertext", provider, Sealed{Ciphertext: flip(sealed.Ciphertext), DataKey: sealed.DataKey, KeyVersion: "v1"}, "user-1", nil},
+		{"tampered data key", provider, Sealed{Ciphertext: sealed.Ciphertext, DataKey: flip(sealed.DataKey), KeyVersion: "v1"}, "user-1", nil},
+		{"truncated ciphertext", provider, Sealed{Ciphertext: base64.StdEncoding.EncodeToString([]byte("short")), DataKey: sealed.DataKey, KeyVersion: "v1"}, "user-1", ErrMalformedCiphertext},
+		{"not base64", provider, Sealed{Ciphertext: "%%%", DataKey: sealed.DataKey, KeyVersion: "v1"}, "user-1", ErrMalformedCiphertext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := Open(ctx, tt.provider, tt.sealed, []byte(tt.aad))
+			if err == nil {
+				t.Fatalf("opened to %q", plaintext)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// After a rotation the new master key seals, and values sealed under the
+// previous one still open as long as it is configured.
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	before := newTestProvider(t, "v1", map[string][]byte{"v1": testKey(1)})
+	after := newTestProvider(t, "", map[string][]byte{"v1": testKey(1), "v2": testKey(2)})
+
+	if after.CurrentVersion() != "v2" {
+		t.Fatalf("current version %q, want the last one by name", after.CurrentVersion())
+	}
+
+	old, err := Seal(ctx, before, []byte("secret"), []byte("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := Open(ctx, after, old, []byte("user-1")); err != nil || string(plaintext) != "secret" {
+		t.Fatalf("previous key no longer opens: %q, %v", plaintext, err)
+	}
+
+	resealed, err := Seal(ctx, after, []byte("secret"), []byte("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resealed.KeyVersion != "v2" {
+		t.Fatalf("resealed under %q, want v2", resealed.KeyVersion)
+	}
+	if _, err := Open(ctx, before, resealed, []byte("user-1")); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Fatalf("got %v, want ErrUnknownKeyVersion", err)
+	}
+}
+
+func TestLoadLocalKeyProvider(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testKey(1))
+
+	tests := []struct {
+		name    string
+		current string
+		inline  string
+		wantErr bool
+	}{
+		{"single key", "", "v1:" + encoded, false},
+		{"explicit current", "v1", "v1:" + encoded + ",v2:" + encoded, false},
+		{"unknown current", "v3", "v1:" + encoded, true},
+		{"no keys", "", "", true},
+		{"missing version", "", ":" + encoded, true},
+		{"not base64", "", "v1:%%%", true},
+		{"short key", "", "v1:" + base64.StdEncoding.EncodeToString(testKey(1)[:16]), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadLocalKeyProvider(tt.current, tt.inline, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -45,6 +45,10 @@ func Unauthorized(code, message string, details interface{}) *Error {
 	return New(http.StatusUnauthorized, code, message, details)
 }
 
+func Forbidden(code, message string, details interface{}) *Error {
+	return New(http.StatusForbidden, code, message, details)
+}
+
 func Conflict(code, message string, details interface{}) *Error {
 	return New(http.StatusConflict, code, message, details)
 }
@@ -61,6 +65,10 @@ func UnprocessableEntity(code, message string, details interface{}) *Error {
 	return New(http.StatusUnprocessableEntity, code, message, details)
 }
 
+func TooManyRequests(code, message string, details interface{}) *Error {
+	return New(http.StatusTooManyRequests, code, message, details)
+}
+
 func NoContent(code, message string, details interface {}) *Error {
 	return New(http.StatusNoContent, code, message, details)
 }
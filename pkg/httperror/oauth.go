@@ -0,0 +1,38 @@
+package httperror
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// OAuthError is an error of the OAuth 2.0 token endpoint (RFC 6749 section
+// 5.2). Clients parse error and error_description rather than the code and
+// message of Error.
+type OAuthError struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// OAuth returns the error with the given OAuth error code: a 401 for
+// invalid_client as the client failed to authenticate, a 500 for
+// server_error and a 400 otherwise.
+func OAuth(code, description string) *OAuthError {
+	status := http.StatusBadRequest
+	switch code {
+	case "invalid_client":
+		status = http.StatusUnauthorized
+	case "server_error":
+		status = http.StatusInternalServerError
+	}
+
+	return &OAuthError{
+		Status:      status,
+		Code:        code,
+		Description: description,
+	}
+}
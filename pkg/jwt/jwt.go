@@ -2,6 +2,8 @@ package jwt
 
 import (
 	"auction/pkg/config"
+	"errors"
+	"strings"
 	"time"
 
 	"auction/domain"
@@ -12,18 +14,289 @@ import (
 
 var appConfig = config.Read()
 
+var keyStore *KeyStore
+
+var (
+	ErrInvalidToken   = errors.New("jwt: invalid token")
+	ErrWrongTokenType = errors.New("jwt: unexpected token type")
+)
+
+const (
+	// TokenTypeAccess grants access to private routes.
+	TokenTypeAccess = "access"
+	// TokenTypeMFAPending only proves the password step of a 2FA login and is
+	// accepted exclusively by /2fa/challenge.
+	TokenTypeMFAPending = "mfa_pending"
+	// TokenTypeEmailVerification is mailed to the user and only accepted by
+	// /email/verify for the address it was issued for.
+	TokenTypeEmailVerification = "email_verification"
+	// TokenTypeEmailChange is mailed to a new address requested through
+	// PATCH /me and confirms the switch to it.
+	TokenTypeEmailChange = "email_change"
+	// TokenTypeTrustedDevice is kept by a device the user chose to remember
+	// and lets /login skip the second factor there.
+	TokenTypeTrustedDevice = "trusted_device"
+	// TokenTypeID is the OpenID Connect ID token handed to a client after an
+	// authorization code exchange. It describes the sign-in and grants no
+	// access.
+	TokenTypeID = "id_token"
+	// TokenTypeMachine identifies a service rather than a user. It is issued
+	// to confidential clients by the client_credentials grant; its subject is
+	// the client id.
+	TokenTypeMachine = "machine"
+
+	AudienceAPI    = "api"
+	AudienceMFA    = "identity:2fa"
+	AudienceEmail  = "identity:email"
+	AudienceDevice = "identity:device"
+
+	// Authentication method references (RFC 8176). RFC 8176 has no value
+	// for one-time recovery codes, so AMRRecoveryCode is service specific.
+	// A passkey with user verification counts as multi-factor on its own.
+	// AMRTrustedDevice marks a login whose second factor was skipped on a
+	// remembered device.
+	AMRPassword      = "pwd"
+	AMROTP           = "otp"
+	AMRRecoveryCode  = "rcode"
+	AMRHardwareKey   = "hwk"
+	AMRMultiFactor   = "mfa"
+	AMRTrustedDevice = "tdev"
+)
+
+// Claims are shared by every token type. Profile claims are left empty in
+// ID tokens whose scope did not request them.
 type Claims struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name          string   `json:"name,omitempty"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified *bool    `json:"email_verified,omitempty"`
+	Type          string   `json:"typ"`
+	AMR           []string `json:"amr,omitempty"`
+	SessionID     string   `json:"sid,omitempty"`
+	// ClientID and Scope are set on access tokens issued to an OIDC client.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// Roles and Permissions are the user's, resolved when an access token is
+	// issued.
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// AuthorizedParty, Nonce and AuthTime only appear in ID tokens.
+	AuthorizedParty string              `json:"azp,omitempty"`
+	Nonce           string              `json:"nonce,omitempty"`
+	AuthTime        *jwtPkg.NumericDate `json:"auth_time,omitempty"`
 	jwtPkg.RegisteredClaims
 }
 
-func CreateToken(u *domain.User) (string, error) {
-	token := jwtPkg.NewWithClaims(jwtPkg.SigningMethodHS256, Payload(u))
+// UseKeyStore sets the keys used by CreateToken and Decode. It must be called
+// once during startup before any token is issued or verified.
+func UseKeyStore(store *KeyStore) {
+	keyStore = store
+}
+
+// Keys returns the key store configured with UseKeyStore.
+func Keys() *KeyStore {
+	return keyStore
+}
+
+// CreateToken mints a first-party access token for the session sessionID,
+// recording the authentication methods the user completed, e.g. [pwd] or
+// [pwd otp], and returns it with its claims.
+func CreateToken(u *domain.User, sessionID string, amr ...string) (string, *Claims, error) {
+	return CreateAccessToken(u, AccessTokenOptions{SessionID: sessionID, AMR: amr})
+}
+
+// AccessTokenOptions describes the session an access token is issued for.
+// ClientID and Scope are empty for first-party tokens.
+type AccessTokenOptions struct {
+	SessionID   string
+	ClientID    string
+	Scope       string
+	AMR         []string
+	Roles       []string
+	Permissions []string
+}
 
-	secret := []byte(appConfig.JWTSecret)
+// CreateAccessToken mints an access token for the user. Tokens issued to an
+// OIDC client carry its id and the space-separated scope the user
+// authorized.
+func CreateAccessToken(u *domain.User, options AccessTokenOptions) (string, *Claims, error) {
+	claims := Payload(u)
+	claims.AMR = options.AMR
+	claims.SessionID = options.SessionID
+	claims.ClientID = options.ClientID
+	claims.Scope = options.Scope
+	claims.Roles = options.Roles
+	claims.Permissions = options.Permissions
 
-	tokenString, err := token.SignedString(secret)
+	token, err := sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &claims, nil
+}
+
+// CreateMachineToken mints the access token of a service authenticated with
+// the client_credentials grant.
+func CreateMachineToken(clientID, scope string) (string, *Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		Type:     TokenTypeMachine,
+		ClientID: clientID,
+		Scope:    scope,
+		RegisteredClaims: jwtPkg.RegisteredClaims{
+			Issuer:    "Identity",
+			Subject:   clientID,
+			Audience:  jwtPkg.ClaimStrings{AudienceAPI},
+			ExpiresAt: jwtPkg.NewNumericDate(now.Add(AccessTokenTTL())),
+			NotBefore: jwtPkg.NewNumericDate(now),
+			IssuedAt:  jwtPkg.NewNumericDate(now),
+			ID:        uuid.New().String(),
+		},
+	}
+
+	token, err := sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &claims, nil
+}
+
+// IDTokenOptions describes the sign-in an ID token is issued for.
+type IDTokenOptions struct {
+	ClientID  string
+	SessionID string
+	Nonce     string
+	Scope     string
+	AuthTime  time.Time
+	AMR       []string
+}
+
+// CreateIDToken mints an OpenID Connect ID token for the client. The name is
+// only included for the profile scope and the address for the email scope.
+func CreateIDToken(u *domain.User, options IDTokenOptions) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Type:            TokenTypeID,
+		AMR:             options.AMR,
+		SessionID:       options.SessionID,
+		AuthorizedParty: options.ClientID,
+		Nonce:           options.Nonce,
+		AuthTime:        jwtPkg.NewNumericDate(options.AuthTime),
+		RegisteredClaims: jwtPkg.RegisteredClaims{
+			Issuer:    Issuer(),
+			Subject:   u.ID,
+			Audience:  jwtPkg.ClaimStrings{options.ClientID},
+			ExpiresAt: jwtPkg.NewNumericDate(now.Add(AccessTokenTTL())),
+			IssuedAt:  jwtPkg.NewNumericDate(now),
+			ID:        uuid.New().String(),
+		},
+	}
+
+	for _, scope := range strings.Fields(options.Scope) {
+		switch scope {
+		case "profile":
+			claims.Name = u.Name
+		case "email":
+			verified := u.EmailVerifiedAt.Valid
+			claims.Email = u.Email
+			claims.EmailVerified = &verified
+		}
+	}
+
+	return sign(claims)
+}
+
+// CreateMFAToken mints the short-lived token returned by /login when the user
+// still has to pass the second factor.
+func CreateMFAToken(u *domain.User) (string, *Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		Type: TokenTypeMFAPending,
+		AMR:  []string{AMRPassword},
+		RegisteredClaims: jwtPkg.RegisteredClaims{
+			Issuer:    "Identity",
+			Subject:   u.ID,
+			Audience:  jwtPkg.ClaimStrings{AudienceMFA},
+			ExpiresAt: jwtPkg.NewNumericDate(now.Add(MFATokenTTL())),
+			NotBefore: jwtPkg.NewNumericDate(now),
+			IssuedAt:  jwtPkg.NewNumericDate(now),
+			ID:        uuid.New().String(),
+		},
+	}
+
+	token, err := sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &claims, nil
+}
+
+// CreateEmailVerificationToken mints the token sent in the verification mail.
+// It is bound to the current address, so it stops working once the email
+// changes.
+func CreateEmailVerificationToken(u *domain.User) (string, error) {
+	return createEmailToken(u.ID, u.Email, TokenTypeEmailVerification)
+}
+
+// CreateEmailChangeToken mints the token mailed to newEmail to confirm that
+// the user controls it before it replaces their current address.
+func CreateEmailChangeToken(u *domain.User, newEmail string) (string, error) {
+	return createEmailToken(u.ID, newEmail, TokenTypeEmailChange)
+}
+
+// CreateTrustedDeviceToken mints the token a remembered device presents to
+// /login. Its jti is deviceID, the id of the stored device.
+func CreateTrustedDeviceToken(u *domain.User, deviceID string) (string, *Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		Type: TokenTypeTrustedDevice,
+		RegisteredClaims: jwtPkg.RegisteredClaims{
+			Issuer:    "Identity",
+			Subject:   u.ID,
+			Audience:  jwtPkg.ClaimStrings{AudienceDevice},
+			ExpiresAt: jwtPkg.NewNumericDate(now.Add(TrustedDeviceTTL())),
+			NotBefore: jwtPkg.NewNumericDate(now),
+			IssuedAt:  jwtPkg.NewNumericDate(now),
+			ID:        deviceID,
+		},
+	}
+
+	token, err := sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &claims, nil
+}
+
+func createEmailToken(userID, email, tokenType string) (string, error) {
+	now := time.Now()
+	return sign(Claims{
+		Email: email,
+		Type:  tokenType,
+		RegisteredClaims: jwtPkg.RegisteredClaims{
+			Issuer:    "Identity",
+			Subject:   userID,
+			Audience:  jwtPkg.ClaimStrings{AudienceEmail},
+			ExpiresAt: jwtPkg.NewNumericDate(now.Add(EmailVerificationTTL())),
+			NotBefore: jwtPkg.NewNumericDate(now),
+			IssuedAt:  jwtPkg.NewNumericDate(now),
+			ID:        uuid.New().String(),
+		},
+	})
+}
+
+func sign(claims jwtPkg.Claims) (string, error) {
+	if keyStore == nil {
+		return "", ErrNoSigningKey
+	}
+
+	key := keyStore.SigningKey()
+
+	token := jwtPkg.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.Private)
 	if err != nil {
 		return "", err
 	}
@@ -31,15 +304,60 @@ func CreateToken(u *domain.User) (string, error) {
 	return tokenString, nil
 }
 
+// AccessTokenTTL is the lifetime of access tokens minted by CreateToken and
+// CreateMachineToken.
+func AccessTokenTTL() time.Duration {
+	if appConfig.JWTAccessTTL <= 0 {
+		return 15 * time.Minute
+	}
+	return appConfig.JWTAccessTTL
+}
+
+// MFATokenTTL is the lifetime of tokens minted by CreateMFAToken.
+func MFATokenTTL() time.Duration {
+	if appConfig.MFATokenTTL <= 0 {
+		return 5 * time.Minute
+	}
+	return appConfig.MFATokenTTL
+}
+
+// EmailVerificationTTL is the lifetime of tokens minted by
+// CreateEmailVerificationToken and CreateEmailChangeToken.
+func EmailVerificationTTL() time.Duration {
+	if appConfig.EmailVerificationTTL <= 0 {
+		return 24 * time.Hour
+	}
+	return appConfig.EmailVerificationTTL
+}
+
+// Issuer is the OpenID Connect issuer identifier: the public base URL of the
+// service, without a trailing slash. Only ID tokens carry it; other tokens
+// keep the "Identity" issuer their consumers already check.
+func Issuer() string {
+	return strings.TrimRight(appConfig.OIDCIssuer, "/")
+}
+
+// TrustedDeviceTTL is how long a remembered device may skip the second
+// factor.
+func TrustedDeviceTTL() time.Duration {
+	if appConfig.TrustedDeviceTTL <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return appConfig.TrustedDeviceTTL
+}
+
 func Payload(u *domain.User) Claims {
+	verified := u.EmailVerifiedAt.Valid
 	return Claims{
-		Name:  u.Name,
-		Email: u.Email,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: &verified,
+		Type:          TokenTypeAccess,
 		RegisteredClaims: jwtPkg.RegisteredClaims{
 			Issuer:    "Identity",
 			Subject:   u.ID,
-			Audience:  jwtPkg.ClaimStrings{"api"},
-			ExpiresAt: jwtPkg.NewNumericDate(time.Now().Add(5 * time.Hour)),
+			Audience:  jwtPkg.ClaimStrings{AudienceAPI},
+			ExpiresAt: jwtPkg.NewNumericDate(time.Now().Add(AccessTokenTTL())),
 			NotBefore: jwtPkg.NewNumericDate(time.Now()),
 			IssuedAt:  jwtPkg.NewNumericDate(time.Now()),
 			ID:        uuid.New().String(),
@@ -47,24 +365,105 @@ func Payload(u *domain.User) Claims {
 	}
 }
 
+// Decode verifies any token issued by this service regardless of its type.
+// Use DecodeAccessToken or DecodeMFAToken wherever the type matters.
 func Decode(jwt string) (*Claims, error) {
-	tokenSecret := []byte(appConfig.JWTSecret)
+	claims := &Claims{}
+	if err := parse(jwt, claims); err != nil {
+		return &Claims{}, err
+	}
+
+	if claims.Subject == "" {
+		return &Claims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func DecodeAccessToken(jwt string) (*Claims, error) {
+	return decodeTyped(jwt, TokenTypeAccess, AudienceAPI)
+}
+
+func DecodeMachineToken(jwt string) (*Claims, error) {
+	return decodeTyped(jwt, TokenTypeMachine, AudienceAPI)
+}
+
+func DecodeMFAToken(jwt string) (*Claims, error) {
+	return decodeTyped(jwt, TokenTypeMFAPending, AudienceMFA)
+}
+
+func DecodeEmailVerificationToken(jwt string) (*Claims, error) {
+	return decodeTyped(jwt, TokenTypeEmailVerification, AudienceEmail)
+}
+
+func DecodeEmailChangeToken(jwt string) (*Claims, error) {
+	return decodeTyped(jwt, TokenTypeEmailChange, AudienceEmail)
+}
+
+func DecodeTrustedDeviceToken(jwt string) (*Claims, error) {
+	return decodeTyped(jwt, TokenTypeTrustedDevice, AudienceDevice)
+}
+
+func decodeTyped(jwt, tokenType, audience string) (*Claims, error) {
+	claims, err := Decode(jwt)
+	if err != nil {
+		return claims, err
+	}
+
+	if claims.Type != tokenType || !hasAudience(claims.Audience, audience) {
+		return &Claims{}, ErrWrongTokenType
+	}
+
+	return claims, nil
+}
 
-	parsedToken, err := jwtPkg.ParseWithClaims(jwt, &Claims{}, func(token *jwtPkg.Token) (any, error) {
-		if _, ok := token.Method.(*jwtPkg.SigningMethodHMAC); !ok {
+func hasAudience(audiences jwtPkg.ClaimStrings, audience string) bool {
+	for _, a := range audiences {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// parse verifies the signature with the key named by the kid header and
+// rejects tokens whose alg does not match that key. Every token this service
+// issues carries iat and exp, so tokens without them are rejected rather than
+// handed to callers that rely on both.
+func parse(tokenString string, claims jwtPkg.Claims) error {
+	if keyStore == nil {
+		return ErrNoSigningKey
+	}
+
+	parsedToken, err := jwtPkg.ParseWithClaims(tokenString, claims, func(token *jwtPkg.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, err := keyStore.Lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, jwtPkg.ErrSignatureInvalid
 		}
-		return tokenSecret, nil
-	})
 
-	if err != nil || parsedToken == nil || !parsedToken.Valid {
-		return &Claims{}, err
+		return key.Public, nil
+	}, jwtPkg.WithValidMethods([]string{
+		jwtPkg.SigningMethodRS256.Alg(),
+		jwtPkg.SigningMethodEdDSA.Alg(),
+	}), jwtPkg.WithExpirationRequired())
+
+	if err != nil {
+		return err
 	}
 
-	claims, ok := parsedToken.Claims.(*Claims)
-	if !ok || claims.Subject == "" {
-		return &Claims{}, err
+	if parsedToken == nil || !parsedToken.Valid {
+		return ErrInvalidToken
 	}
 
-	return claims, nil
+	if issuedAt, err := claims.GetIssuedAt(); err != nil || issuedAt == nil {
+		return ErrInvalidToken
+	}
+
+	return nil
 }
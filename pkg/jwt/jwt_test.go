@@ -0,0 +1,81 @@
+package jwt
+
+import (
+	"auction/domain"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func useTestKeys(t *testing.T) {
+	t.Helper()
+
+	key, err := GenerateEd25519Key("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewKeyStore("test", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := keyStore
+	UseKeyStore(store)
+	t.Cleanup(func() { UseKeyStore(previous) })
+}
+
+func TestCreateIDTokenScopes(t *testing.T) {
+	useTestKeys(t)
+
+	user := &domain.User{
+		ID:              "6a0f3bd4-5d1e-4c55-9a7f-0d3c1e0f7b21",
+		Email:           "bidder@example.com",
+		Name:            "Bidder",
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+
+	tests := []struct {
+		scope     string
+		wantName  bool
+		wantEmail bool
+	}{
+		{"openid", false, false},
+		{"openid profile", true, false},
+		{"openid email", false, true},
+		{"openid profile email", true, true},
+		{"openid bids:read", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			token, err := CreateIDToken(user, IDTokenOptions{
+				ClientID:  "client-1",
+				SessionID: "session-1",
+				Nonce:     "n-0S6_WzA2Mj",
+				Scope:     tt.scope,
+				AuthTime:  time.Now(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := Decode(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if claims.Subject != user.ID || claims.AuthorizedParty != "client-1" || claims.Nonce != "n-0S6_WzA2Mj" {
+				t.Fatalf("unexpected claims %+v", claims)
+			}
+			if got := claims.Name != ""; got != tt.wantName {
+				t.Errorf("name present = %v, want %v", got, tt.wantName)
+			}
+			if got := claims.Email != "" || claims.EmailVerified != nil; got != tt.wantEmail {
+				t.Errorf("email present = %v, want %v", got, tt.wantEmail)
+			}
+			if tt.wantEmail && (claims.Email != user.Email || !*claims.EmailVerified) {
+				t.Errorf("email claims %q, %v", claims.Email, *claims.EmailVerified)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	jwtPkg "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKeyID     = errors.New("jwt: unknown key id")
+	ErrNoSigningKey     = errors.New("jwt: no signing key configured")
+	errUnsupportedKey   = errors.New("jwt: unsupported key type")
+	errMalformedKeyFile = errors.New("jwt: malformed PEM key file")
+)
+
+const (
+	privateKeySuffix = ".pem"
+	publicKeySuffix  = ".pub.pem"
+)
+
+// Key is a single signing or verification key identified by the kid header.
+// Retired keys carry no private part: they only verify tokens issued before
+// the rotation and are still published in the JWKS until those tokens expire.
+type Key struct {
+	ID      string
+	Method  jwtPkg.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+func (k *Key) Retired() bool {
+	return k.Private == nil
+}
+
+type KeyStore struct {
+	keys    map[string]*Key
+	signing *Key
+}
+
+func NewKeyStore(signingKeyID string, keys ...*Key) (*KeyStore, error) {
+	s := &KeyStore{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+
+	if signingKeyID == "" {
+		// Default to the newest active key when kids sort chronologically,
+		// e.g. "2025-01" < "2025-06".
+		ids := s.IDs()
+		for i := len(ids) - 1; i >= 0; i-- {
+			if !s.keys[ids[i]].Retired() {
+				signingKeyID = ids[i]
+				break
+			}
+		}
+	}
+
+	signing, ok := s.keys[signingKeyID]
+	if !ok || signing.Retired() {
+		return nil, ErrNoSigningKey
+	}
+	s.signing = signing
+
+	return s, nil
+}
+
+// LoadKeyStore reads every "<kid>.pem" private key and "<kid>.pub.pem" retired
+// public key from dir. RSA keys sign with RS256 and Ed25519 keys with EdDSA.
+func LoadKeyStore(dir, signingKeyID string) (*KeyStore, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+
+		var key *Key
+		switch {
+		case strings.HasSuffix(name, publicKeySuffix):
+			key, err = loadPublicKey(strings.TrimSuffix(name, publicKeySuffix), path)
+		case strings.HasSuffix(name, privateKeySuffix):
+			key, err = loadPrivateKey(strings.TrimSuffix(name, privateKeySuffix), path)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return NewKeyStore(signingKeyID, keys...)
+}
+
+// GenerateEd25519Key creates an in-memory key, used when no key directory is
+// configured so local development works without provisioning files.
+func GenerateEd25519Key(id string) (*Key, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{ID: id, Method: jwtPkg.SigningMethodEdDSA, Private: private, Public: public}, nil
+}
+
+func (s *KeyStore) SigningKey() *Key {
+	return s.signing
+}
+
+func (s *KeyStore) Lookup(id string) (*Key, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	return key, nil
+}
+
+func (s *KeyStore) IDs() []string {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func loadPrivateKey(id, path string) (*Key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwtPkg.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwtPkg.SigningMethodEdDSA, Private: k, Public: k.Public()}, nil
+	default:
+		return nil, errUnsupportedKey
+	}
+}
+
+func loadPublicKey(id, path string) (*Key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwtPkg.SigningMethodRS256, Public: k}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwtPkg.SigningMethodEdDSA, Public: k}, nil
+	default:
+		return nil, errUnsupportedKey
+	}
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errMalformedKeyFile
+	}
+	return block, nil
+}
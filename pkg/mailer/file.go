@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes each message as an .eml file into a directory. It is
+// meant for development and end-to-end tests that need to read the mail.
+type FileMailer struct {
+	from *mail.Address
+	dir  string
+}
+
+func NewFileMailer(from *mail.Address, dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileMailer{from: from, dir: dir}, nil
+}
+
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	to, data, err := render(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), to.Address)
+	return os.WriteFile(filepath.Join(m.dir, filepath.Base(name)), data, 0o600)
+}
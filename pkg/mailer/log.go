@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"context"
+	"net/mail"
+
+	"go.uber.org/zap"
+)
+
+// LogMailer writes messages to the application log instead of sending them.
+// Bodies contain single-use links, so never use it in production.
+type LogMailer struct {
+	from *mail.Address
+}
+
+func NewLogMailer(from *mail.Address) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	to, _, err := render(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	zap.L().Info("Mail not sent (log mailer)",
+		zap.String("from", m.from.Address),
+		zap.String("to", to.Address),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body),
+	)
+	return nil
+}
@@ -0,0 +1,88 @@
+package mailer
+
+import (
+	"auction/pkg/config"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverSMTP = "smtp"
+)
+
+var (
+	ErrUnknownDriver  = errors.New("mailer: unknown driver")
+	ErrInvalidAddress = errors.New("mailer: invalid address")
+)
+
+// Message is a plain-text email. From is filled in by the mailer.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email. Implementations must be safe for
+// concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by MAILER_DRIVER.
+func New(cfg *config.AppConfig) (Mailer, error) {
+	from, err := mail.ParseAddress(cfg.MailFrom)
+	if err != nil {
+		return nil, fmt.Errorf("%w: MAIL_FROM: %v", ErrInvalidAddress, err)
+	}
+
+	switch strings.ToLower(cfg.MailerDriver) {
+	case "", DriverLog:
+		return NewLogMailer(from), nil
+	case DriverFile:
+		return NewFileMailer(from, cfg.MailerFileDir)
+	case DriverSMTP:
+		return NewSMTPMailer(from, cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, cfg.MailerDriver)
+	}
+}
+
+// render builds the RFC 5322 representation of msg. The recipient is parsed
+// so that header injection through CR/LF is impossible.
+func render(from *mail.Address, msg Message) (*mail.Address, []byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, nil, err
+	}
+
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return to, buf.Bytes(), nil
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPMailer delivers through an SMTP relay. smtp.SendMail upgrades to TLS
+// with STARTTLS whenever the server offers it, and PLAIN auth is refused over
+// an unencrypted connection to anything but localhost.
+type SMTPMailer struct {
+	from *mail.Address
+	addr string
+	auth smtp.Auth
+	host string
+}
+
+func NewSMTPMailer(from *mail.Address, host, port, username, password string) *SMTPMailer {
+	m := &SMTPMailer{
+		from: from,
+		addr: net.JoinHostPort(host, port),
+		host: host,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	to, data, err := render(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	// net/smtp has no context support; run it aside so callers can give up.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, m.auth, m.from.Address, []string{to.Address}, data)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,79 @@
+package password
+
+import (
+	"auction/pkg/config"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownFormat = errors.New("password: unknown hash format")
+
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
+}
+
+// scheme is implemented by every supported storage format so the migrating
+// hasher can route a stored hash to the implementation that produced it.
+type scheme interface {
+	Hasher
+	Recognizes(encoded string) bool
+}
+
+type migratingHasher struct {
+	preferred scheme
+	schemes   []scheme
+}
+
+// NewHasher returns a Hasher that creates hashes with the configured algorithm
+// and still verifies every other supported format. NeedsRehash reports true for
+// anything not produced by the preferred algorithm with its current parameters.
+func NewHasher(cfg *config.AppConfig) (Hasher, error) {
+	argon := NewArgon2idHasher(Argon2idParams{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+		SaltLength:  cfg.Argon2SaltLength,
+		KeyLength:   cfg.Argon2KeyLength,
+	})
+	bc := NewBcryptHasher(cfg.BcryptCost)
+
+	var preferred scheme
+	switch strings.ToLower(cfg.PasswordHashAlgorithm) {
+	case "", "argon2id":
+		preferred = argon
+	case "bcrypt":
+		preferred = bc
+	default:
+		return nil, fmt.Errorf("password: unsupported hash algorithm %q", cfg.PasswordHashAlgorithm)
+	}
+
+	return &migratingHasher{
+		preferred: preferred,
+		schemes:   []scheme{argon, bc, legacySHA256Hasher{}},
+	}, nil
+}
+
+func (h *migratingHasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *migratingHasher) Verify(password, encoded string) (bool, error) {
+	for _, s := range h.schemes {
+		if s.Recognizes(encoded) {
+			return s.Verify(password, encoded)
+		}
+	}
+
+	return false, ErrUnknownFormat
+}
+
+func (h *migratingHasher) NeedsRehash(encoded string) bool {
+	if !h.preferred.Recognizes(encoded) {
+		return true
+	}
+
+	return h.preferred.NeedsRehash(encoded)
+}
@@ -0,0 +1,184 @@
+package password
+
+import (
+	"auction/pkg/config"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+var testArgon2idParams = Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func newTestHasher(t *testing.T, algorithm string) Hasher {
+	t.Helper()
+
+	hasher, err := NewHasher(&config.AppConfig{
+		PasswordHashAlgorithm: algorithm,
+		Argon2Memory:          testArgon2idParams.Memory,
+		Argon2Iterations:      testArgon2idParams.Iterations,
+		Argon2Parallelism:     testArgon2idParams.Parallelism,
+		BcryptCost:            4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hasher
+}
+
+func legacyHash(password string) string {
+	digest := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(digest[:])
+}
+
+func TestHashVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher Hasher
+	}{
+		{"argon2id", NewArgon2idHasher(testArgon2idParams)},
+		{"bcrypt", NewBcryptHasher(4)},
+		{"migrating, argon2id preferred", newTestHasher(t, "argon2id")},
+		{"migrating, bcrypt preferred", newTestHasher(t, "bcrypt")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.hasher.Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again, _ := tt.hasher.Hash("correct horse battery staple"); again == encoded {
+				t.Fatal("two hashes of the same password are equal; the salt is not random")
+			}
+
+			if ok, err := tt.hasher.Verify("correct horse battery staple", encoded); err != nil || !ok {
+				t.Fatalf("Verify(right password) = (%v, %v)", ok, err)
+			}
+			if ok, err := tt.hasher.Verify("correct horse battery stapler", encoded); err != nil || ok {
+				t.Fatalf("Verify(wrong password) = (%v, %v)", ok, err)
+			}
+			if tt.hasher.NeedsRehash(encoded) {
+				t.Fatal("a fresh hash needs rehashing")
+			}
+		})
+	}
+}
+
+func TestMigratingHasherVerifiesEveryFormat(t *testing.T) {
+	hasher := newTestHasher(t, "argon2id")
+
+	argon, err := NewArgon2idHasher(testArgon2idParams).Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcrypt, err := NewBcryptHasher(4).Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		encoded     string
+		needsRehash bool
+	}{
+		{"argon2id", argon, false},
+		{"bcrypt", bcrypt, true},
+		{"legacy sha-256", legacyHash("hunter2"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := hasher.Verify("hunter2", tt.encoded); err != nil || !ok {
+				t.Fatalf("Verify(right password) = (%v, %v)", ok, err)
+			}
+			if ok, err := hasher.Verify("hunter3", tt.encoded); err != nil || ok {
+				t.Fatalf("Verify(wrong password) = (%v, %v)", ok, err)
+			}
+			if got := hasher.NeedsRehash(tt.encoded); got != tt.needsRehash {
+				t.Fatalf("NeedsRehash = %v, want %v", got, tt.needsRehash)
+			}
+		})
+	}
+}
+
+func TestNeedsRehashOnParameterChange(t *testing.T) {
+	argon := NewArgon2idHasher(testArgon2idParams)
+	encoded, err := argon.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcrypt := NewBcryptHasher(4)
+	bcryptEncoded, err := bcrypt.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		hasher  Hasher
+		encoded string
+		want    bool
+	}{
+		{"same argon2id parameters", NewArgon2idHasher(testArgon2idParams), encoded, false},
+		{"more memory", NewArgon2idHasher(Argon2idParams{Memory: 2048, Iterations: 1, Parallelism: 1}), encoded, true},
+		{"more iterations", NewArgon2idHasher(Argon2idParams{Memory: 1024, Iterations: 2, Parallelism: 1}), encoded, true},
+		{"more lanes", NewArgon2idHasher(Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 2}), encoded, true},
+		{"longer key", NewArgon2idHasher(Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, KeyLength: 64}), encoded, true},
+		{"same bcrypt cost", NewBcryptHasher(4), bcryptEncoded, false},
+		{"higher bcrypt cost", NewBcryptHasher(5), bcryptEncoded, true},
+		{"malformed argon2id", argon, "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.encoded); got != tt.want {
+				t.Fatalf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Stored hashes are parsed, not trusted: a malformed one must fail
+// verification with an error instead of panicking inside the KDF.
+func TestVerifyMalformedHash(t *testing.T) {
+	hasher := newTestHasher(t, "argon2id")
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    error
+	}{
+		{"zero lanes", "$argon2id$v=19$m=1024,t=1,p=0$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5", errInvalidArgon2idHash},
+		{"zero rounds", "$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5", errInvalidArgon2idHash},
+		{"empty key", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$", errInvalidArgon2idHash},
+		{"lanes out of range", "$argon2id$v=19$m=1024,t=1,p=256$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5", errInvalidArgon2idHash},
+		{"other version", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5", errInvalidArgon2idHash},
+		{"missing parameters", "$argon2id$v=19$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5", errInvalidArgon2idHash},
+		{"salt not base64", "$argon2id$v=19$m=1024,t=1,p=1$%%%$a2V5a2V5a2V5a2V5", errInvalidArgon2idHash},
+		{"truncated bcrypt", "$2a$04$short", nil},
+		{"unknown format", "plaintext", ErrUnknownFormat},
+		{"empty", "", ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := hasher.Verify("hunter2", tt.encoded)
+			if ok || err == nil {
+				t.Fatalf("Verify = (%v, %v), want an error", ok, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if !hasher.NeedsRehash(tt.encoded) {
+				t.Fatal("a malformed hash does not need rehashing")
+			}
+		})
+	}
+}
+
+func TestNewHasherRejectsUnknownAlgorithm(t *testing.T) {
+	if _, err := NewHasher(&config.AppConfig{PasswordHashAlgorithm: "md5"}); err == nil {
+		t.Fatal("accepted an unsupported algorithm")
+	}
+}
@@ -0,0 +1,34 @@
+package password
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// legacySHA256Hasher verifies the unsalted hex SHA-256 digests written before
+// Argon2id was introduced. It never produces new hashes; rows it matches are
+// rehashed with the preferred algorithm on the next successful login.
+type legacySHA256Hasher struct{}
+
+func (legacySHA256Hasher) Hash(string) (string, error) {
+	return "", ErrUnknownFormat
+}
+
+func (legacySHA256Hasher) Verify(password, encoded string) (bool, error) {
+	hash := sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(hash[:])), []byte(encoded)) == 1, nil
+}
+
+func (legacySHA256Hasher) NeedsRehash(string) bool {
+	return true
+}
+
+func (legacySHA256Hasher) Recognizes(encoded string) bool {
+	if len(encoded) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
@@ -0,0 +1,164 @@
+package password
+
+import (
+	"auction/pkg/config"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ccojocar/zxcvbn-go"
+	"go.uber.org/zap"
+)
+
+// bcryptMaxBytes is the input length after which bcrypt silently ignores the
+// rest of the password.
+const bcryptMaxBytes = 72
+
+// minPersonalTokenLength keeps short names or email parts such as "al" from
+// rejecting unrelated passwords.
+const minPersonalTokenLength = 3
+
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxBytes     = "max_bytes"
+	RulePersonalInfo = "personal_info"
+	RuleStrength     = "strength"
+	RuleBreached     = "breached"
+)
+
+// BreachChecker reports how often a password appears in a breach corpus.
+// pkg/breach provides an offline implementation.
+type BreachChecker interface {
+	Count(password string) (int, error)
+}
+
+// Violation is one failed policy rule, returned to clients in the Details of
+// the error response.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Policy decides whether a new password is acceptable. Length is counted in
+// characters so multi-byte passwords are not penalised, while MaxBytes bounds
+// the hashing input. MinScore is a zxcvbn score from 0 (guessable) to 4.
+// When Breaches is set, passwords seen at least MinBreachCount times are
+// rejected.
+type Policy struct {
+	MinLength      int
+	MaxBytes       int
+	MinScore       int
+	Breaches       BreachChecker
+	MinBreachCount int
+}
+
+// NewPolicy builds the policy from the configuration. breaches may be nil to
+// disable the breach check.
+func NewPolicy(cfg *config.AppConfig, breaches BreachChecker) *Policy {
+	policy := &Policy{
+		MinLength:      cfg.PasswordMinLength,
+		MaxBytes:       cfg.PasswordMaxBytes,
+		MinScore:       cfg.PasswordMinScore,
+		Breaches:       breaches,
+		MinBreachCount: max(cfg.BreachedPasswordsMinCount, 1),
+	}
+
+	if strings.EqualFold(cfg.PasswordHashAlgorithm, "bcrypt") && (policy.MaxBytes <= 0 || policy.MaxBytes > bcryptMaxBytes) {
+		policy.MaxBytes = bcryptMaxBytes
+	}
+
+	return policy
+}
+
+// Check returns every rule the password breaks for a user with the given
+// email and name, or nil when it is acceptable. The password is checked
+// exactly as given; surrounding whitespace is part of it.
+func (p *Policy) Check(password, email, name string) []Violation {
+	var violations []Violation
+
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		// Scoring is quadratic in the length; skip the other rules for
+		// oversized input.
+		return []Violation{{
+			Rule:    RuleMaxBytes,
+			Message: fmt.Sprintf("Password must be at most %d bytes", p.MaxBytes),
+		}}
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+
+	personal := personalTokens(email, name)
+	lower := strings.ToLower(password)
+	for _, token := range personal {
+		if strings.Contains(lower, token) {
+			violations = append(violations, Violation{
+				Rule:    RulePersonalInfo,
+				Message: "Password must not contain your email address or name",
+			})
+			break
+		}
+	}
+
+	if p.MinScore > 0 {
+		result := zxcvbn.PasswordStrength(password, personal)
+		if result.Score < p.MinScore {
+			violations = append(violations, Violation{
+				Rule:    RuleStrength,
+				Message: "Password is too easy to guess; use a longer passphrase or fewer predictable patterns",
+			})
+		}
+	}
+
+	if p.Breaches != nil {
+		// A broken corpus must not block every signup; the other rules
+		// still apply.
+		count, err := p.Breaches.Count(password)
+		if err != nil {
+			zap.L().Warn("Breached password lookup failed", zap.Error(err))
+		} else if count >= max(p.MinBreachCount, 1) {
+			violations = append(violations, Violation{
+				Rule:    RuleBreached,
+				Message: "Password has appeared in a known data breach; choose a different one",
+			})
+		}
+	}
+
+	return violations
+}
+
+// personalTokens returns the lower-cased email, its local part, and each
+// part of the name and local part that is long enough to be meaningful.
+func personalTokens(email, name string) []string {
+	var tokens []string
+	add := func(token string) {
+		token = strings.ToLower(strings.TrimSpace(token))
+		if utf8.RuneCountInString(token) >= minPersonalTokenLength {
+			tokens = append(tokens, token)
+		}
+	}
+
+	add(email)
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		local := email[:at]
+		add(local)
+		for _, part := range strings.FieldsFunc(local, isSeparator) {
+			add(part)
+		}
+	}
+
+	for _, part := range strings.FieldsFunc(name, isSeparator) {
+		add(part)
+	}
+
+	return tokens
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// purgeInterval bounds how often expired counters are swept.
+const purgeInterval = time.Minute
+
+type counter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// MemoryStore keeps counters in process. Limits are then enforced per
+// replica.
+type MemoryStore struct {
+	mu         sync.Mutex
+	counters   map[string]counter
+	lastPurged time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters: make(map[string]counter),
+	}
+}
+
+func (s *MemoryStore) Increment(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purgeLocked(now)
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expiresAt) {
+		c = counter{expiresAt: now.Add(ttl)}
+	}
+	c.value++
+	s.counters[key] = c
+
+	return c.value, nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !time.Now().Before(c.expiresAt) {
+		return 0, nil
+	}
+	return c.value, nil
+}
+
+func (s *MemoryStore) purgeLocked(now time.Time) {
+	if now.Sub(s.lastPurged) < purgeInterval {
+		return
+	}
+	s.lastPurged = now
+
+	for key, c := range s.counters {
+		if !now.Before(c.expiresAt) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package ratelimit implements a sliding window limiter over a pluggable
+// counter store. Each window is a plain counter, so a shared backend only
+// needs INCR, PEXPIRE and GET; RedisStore provides exactly that.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("ratelimit: invalid limit")
+
+// Store keeps expiring counters. MemoryStore serves a single replica;
+// RedisStore shares the counters between replicas.
+type Store interface {
+	// Increment adds one to the counter at key, creating it with the given
+	// time to live, and returns the new value.
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Get returns the counter at key, or 0 when it does not exist.
+	Get(ctx context.Context, key string) (int64, error)
+}
+
+// Limit allows Requests per Window.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseLimit reads a limit written as "<requests>/<window>", e.g. "10/1m".
+func ParseLimit(value string) (Limit, error) {
+	requests, window, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, value)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, value)
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d < time.Second {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, value)
+	}
+
+	return Limit{Requests: n, Window: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Window)
+}
+
+// Result describes the state of a key after a request was counted.
+type Result struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// Reset is when the current window ends.
+	Reset time.Duration
+	// RetryAfter is how long a rejected client should wait.
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow counts one request for key and reports whether it fits the limit.
+// The rate is estimated from the current window plus the overlapping share
+// of the previous one, which smooths the burst a fixed window allows at its
+// boundary. Rejected requests are counted too, so a client that keeps
+// hammering stays limited.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := l.now()
+	window := limit.Window
+	start := now.Truncate(window)
+	elapsed := now.Sub(start)
+
+	current, err := l.store.Increment(ctx, windowKey(key, start), 2*window)
+	if err != nil {
+		return Result{}, err
+	}
+
+	previous, err := l.store.Get(ctx, windowKey(key, start.Add(-window)))
+	if err != nil {
+		return Result{}, err
+	}
+
+	weight := 1 - float64(elapsed)/float64(window)
+	estimated := float64(previous)*weight + float64(current)
+
+	result := Result{
+		Allowed:   estimated <= float64(limit.Requests),
+		Limit:     limit,
+		Remaining: max(limit.Requests-int(math.Ceil(estimated)), 0),
+		Reset:     window - elapsed,
+	}
+
+	if !result.Allowed {
+		result.RetryAfter = retryAfter(limit, previous, current, elapsed)
+	}
+
+	return result, nil
+}
+
+// retryAfter returns when the estimate drops back under the limit, assuming
+// no further requests: first as the previous window slides out, otherwise
+// once the current window becomes the previous one.
+func retryAfter(limit Limit, previous, current int64, elapsed time.Duration) time.Duration {
+	window := float64(limit.Window)
+	room := float64(limit.Requests) - float64(current)
+
+	if room >= 0 && previous > 0 {
+		// previous * (1 - t/window) <= room
+		t := window * (1 - room/float64(previous))
+		return max(time.Duration(t)-elapsed, time.Second)
+	}
+
+	// current * (1 - t/window) <= limit in the next window
+	t := window * (1 - float64(limit.Requests)/float64(current))
+	return max(limit.Window-elapsed+time.Duration(t), time.Second)
+}
+
+func windowKey(key string, start time.Time) string {
+	return key + ":" + strconv.FormatInt(start.Unix(), 10)
+}
+
+// What a Rule counts requests by.
+const (
+	ByIP         = "ip"
+	ByEmail      = "email"
+	ByIPAndEmail = "ip+email"
+)
+
+// Rule applies a limit to requests grouped by client IP, by the email in
+// the request body, or by both combined.
+type Rule struct {
+	By    string
+	Limit Limit
+}
+
+// ParseRules reads a comma-separated list of "<by>:<limit>" rules, e.g.
+// "ip:20/1m,email:5/15m". An empty value yields no rules.
+func ParseRules(value string) ([]Rule, error) {
+	var rules []Rule
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		by, limitText, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLimit, part)
+		}
+
+		by = strings.ToLower(strings.TrimSpace(by))
+		if by != ByIP && by != ByEmail && by != ByIPAndEmail {
+			return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidLimit, by)
+		}
+
+		limit, err := ParseLimit(limitText)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, Rule{By: by, Limit: limit})
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter over a MemoryStore whose clock is set
+// through the returned function.
+func newTestLimiter() (*Limiter, func(time.Time)) {
+	limiter := NewLimiter(NewMemoryStore())
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	return limiter, func(t time.Time) { now = t }
+}
+
+func allow(t *testing.T, limiter *Limiter, key string, limit Limit) Result {
+	t.Helper()
+	result, err := limiter.Allow(context.Background(), key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestLimiterWindowRollover(t *testing.T) {
+	limiter, setNow := newTestLimiter()
+	limit := Limit{Requests: 4, Window: time.Minute}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := range 4 {
+		if result := allow(t, limiter, "k", limit); !result.Allowed || result.Remaining != 3-i {
+			t.Fatalf("request %d: got %+v", i+1, result)
+		}
+	}
+	result := allow(t, limiter, "k", limit)
+	if result.Allowed {
+		t.Fatal("request over the limit was allowed")
+	}
+	if result.RetryAfter < time.Second || result.RetryAfter > 2*time.Minute {
+		t.Fatalf("retry after %s", result.RetryAfter)
+	}
+
+	// At the start of the next window the previous one still counts in full.
+	setNow(start.Add(time.Minute))
+	if result := allow(t, limiter, "k", limit); result.Allowed {
+		t.Fatalf("previous window was forgotten at the boundary: %+v", result)
+	}
+
+	// Three quarters in, a quarter of the five earlier requests remains.
+	setNow(start.Add(time.Minute + 45*time.Second))
+	if result := allow(t, limiter, "k", limit); !result.Allowed {
+		t.Fatalf("sliding window did not recover: %+v", result)
+	}
+
+	// Two windows later nothing of the first one remains.
+	setNow(start.Add(3 * time.Minute))
+	if result := allow(t, limiter, "k", limit); !result.Allowed || result.Remaining != 3 {
+		t.Fatalf("old windows still counted: %+v", result)
+	}
+}
+
+func TestLimiterKeyIsolation(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	limit := Limit{Requests: 2, Window: time.Minute}
+
+	for range 3 {
+		allow(t, limiter, "login:ip:a", limit)
+	}
+	if result := allow(t, limiter, "login:ip:a", limit); result.Allowed {
+		t.Fatal("exhausted key was allowed")
+	}
+
+	if result := allow(t, limiter, "login:ip:b", limit); !result.Allowed || result.Remaining != 1 {
+		t.Fatalf("other key was affected: %+v", result)
+	}
+	if result := allow(t, limiter, "register:ip:a", limit); !result.Allowed {
+		t.Fatalf("same subject on another route was affected: %+v", result)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(" ip:20/1m, EMAIL:5/15m ,ip+email:3/1h,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{
+		{By: ByIP, Limit: Limit{Requests: 20, Window: time.Minute}},
+		{By: ByEmail, Limit: Limit{Requests: 5, Window: 15 * time.Minute}},
+		{By: ByIPAndEmail, Limit: Limit{Requests: 3, Window: time.Hour}},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("got %+v, want %+v", rules, want)
+	}
+
+	if rules, err := ParseRules(""); err != nil || rules != nil {
+		t.Fatalf("empty value: got (%v, %v)", rules, err)
+	}
+
+	for _, value := range []string{
+		"ip",
+		"ip:20",
+		"user:20/1m",
+		"ip:0/1m",
+		"ip:-1/1m",
+		"ip:x/1m",
+		"ip:20/soon",
+		"ip:20/500ms",
+		"ip:20/1m,email",
+	} {
+		if _, err := ParseRules(value); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("%q: got %v, want ErrInvalidLimit", value, err)
+		}
+	}
+}
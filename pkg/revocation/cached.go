@@ -0,0 +1,129 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	revoked   bool
+	watermark time.Time
+	expiresAt time.Time
+}
+
+// CachedStore answers lookups from memory and falls back to the backend once
+// an entry is older than ttl. Writes go to the backend first and then update
+// the local cache, so revocations made by this replica apply immediately while
+// those made elsewhere are picked up within ttl.
+type CachedStore struct {
+	backend Store
+	ttl     time.Duration
+
+	mu         sync.Mutex
+	tokens     map[string]cacheEntry
+	watermarks map[string]cacheEntry
+	nextPurge  time.Time
+}
+
+func NewCachedStore(backend Store, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		backend:    backend,
+		ttl:        ttl,
+		tokens:     make(map[string]cacheEntry),
+		watermarks: make(map[string]cacheEntry),
+	}
+}
+
+func (s *CachedStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := s.backend.RevokeToken(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A revoked jti never becomes valid again, so keep it until the token
+	// itself expires rather than for the regular ttl.
+	s.tokens[jti] = cacheEntry{revoked: true, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *CachedStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.tokens[jti]
+	s.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := s.backend.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeLocked(now)
+	s.tokens[jti] = cacheEntry{revoked: revoked, expiresAt: now.Add(s.ttl)}
+	return revoked, nil
+}
+
+func (s *CachedStore) RevokeUserTokensBefore(ctx context.Context, userID string, before time.Time) error {
+	if err := s.backend.RevokeUserTokensBefore(ctx, userID, before); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watermarks[userID] = cacheEntry{watermark: before, expiresAt: time.Now().Add(s.ttl)}
+	return nil
+}
+
+func (s *CachedStore) UserTokensRevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.watermarks[userID]
+	s.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		return entry.watermark, nil
+	}
+
+	watermark, err := s.backend.UserTokensRevokedBefore(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeLocked(now)
+	s.watermarks[userID] = cacheEntry{watermark: watermark, expiresAt: now.Add(s.ttl)}
+	return watermark, nil
+}
+
+// purgeLocked drops stale entries at most once per ttl to keep lookups cheap.
+func (s *CachedStore) purgeLocked(now time.Time) {
+	if now.Before(s.nextPurge) {
+		return
+	}
+	s.nextPurge = now.Add(s.ttl)
+
+	for jti, entry := range s.tokens {
+		if !now.Before(entry.expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	for userID, entry := range s.watermarks {
+		if !now.Before(entry.expiresAt) {
+			delete(s.watermarks, userID)
+		}
+	}
+}
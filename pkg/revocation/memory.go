@@ -0,0 +1,62 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type MemoryStore struct {
+	mu         sync.RWMutex
+	tokens     map[string]time.Time
+	watermarks map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens:     make(map[string]time.Time),
+		watermarks: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[jti] = expiresAt
+	s.purgeLocked(time.Now())
+	return nil
+}
+
+func (s *MemoryStore) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expiresAt, ok := s.tokens[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (s *MemoryStore) RevokeUserTokensBefore(_ context.Context, userID string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if before.After(s.watermarks[userID]) {
+		s.watermarks[userID] = before
+	}
+	return nil
+}
+
+func (s *MemoryStore) UserTokensRevokedBefore(_ context.Context, userID string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.watermarks[userID], nil
+}
+
+func (s *MemoryStore) purgeLocked(now time.Time) {
+	for jti, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrRevoked = errors.New("revocation: token has been revoked")
+
+// Store keeps the jti denylist and the per-user "issued before" watermark.
+// PgRepository is the durable implementation and MemoryStore a stand-in for a
+// shared cache such as Redis. CachedStore fronts another Store with its own
+// in-memory cache.
+type Store interface {
+	// RevokeToken denies a single jti until expiresAt, after which the token
+	// would be rejected for being expired anyway. Session ids (the sid claim)
+	// share the denylist, which ends every access token of the session.
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeUserTokensBefore invalidates every token of the user issued
+	// before the given instant. Callers use RevokeUserTokens.
+	RevokeUserTokensBefore(ctx context.Context, userID string, before time.Time) error
+	// UserTokensRevokedBefore returns the user's watermark, or the zero time
+	// when none has been set.
+	UserTokensRevokedBefore(ctx context.Context, userID string) (time.Time, error)
+}
+
+// RevokeUserTokens invalidates every token issued to the user before the
+// current second. iat has second precision, so the watermark is stored
+// truncated to the second and Check only rejects tokens issued strictly
+// before it: the tokens of a login right after the revocation stay valid.
+func RevokeUserTokens(ctx context.Context, store Store, userID string) error {
+	return store.RevokeUserTokensBefore(ctx, userID, time.Now().Truncate(time.Second))
+}
+
+// Check returns ErrRevoked when the token identified by jti, issued to userID
+// at issuedAt, or its session sid is denylisted, or the token predates the
+// user's watermark. sid is empty for tokens that belong to no session.
+func Check(ctx context.Context, store Store, jti, sid, userID string, issuedAt time.Time) error {
+	for _, id := range []string{jti, sid} {
+		if id == "" {
+			continue
+		}
+
+		revoked, err := store.IsTokenRevoked(ctx, id)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrRevoked
+		}
+	}
+
+	watermark, err := store.UserTokensRevokedBefore(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !watermark.IsZero() && issuedAt.Before(watermark) {
+		return ErrRevoked
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckWatermark(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	if err := RevokeUserTokens(ctx, store, "user"); err != nil {
+		t.Fatal(err)
+	}
+	watermark, err := store.UserTokensRevokedBefore(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watermark.IsZero() || !watermark.Equal(watermark.Truncate(time.Second)) {
+		t.Fatalf("watermark %v is not truncated to the second", watermark)
+	}
+
+	tests := []struct {
+		name     string
+		userID   string
+		issuedAt time.Time
+		want     error
+	}{
+		{"previous second", "user", watermark.Add(-time.Second), ErrRevoked},
+		{"last instant of the previous second", "user", watermark.Add(-time.Nanosecond), ErrRevoked},
+		{"same second", "user", watermark, nil},
+		{"next second", "user", watermark.Add(time.Second), nil},
+		{"user without watermark", "other", watermark.Add(-time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(ctx, store, "", "", tt.userID, tt.issuedAt); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDenylist(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+
+	if err := store.RevokeToken(ctx, "revoked-jti", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeToken(ctx, "revoked-sid", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeToken(ctx, "expired-jti", now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		jti  string
+		sid  string
+		want error
+	}{
+		{"valid", "jti", "sid", nil},
+		{"revoked jti", "revoked-jti", "sid", ErrRevoked},
+		{"revoked session", "jti", "revoked-sid", ErrRevoked},
+		{"no session", "jti", "", nil},
+		{"denylist entry expired", "expired-jti", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(ctx, store, tt.jti, tt.sid, "user", now); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// An earlier watermark written late, for example by another replica, must
+// not reopen tokens a later one revoked.
+func TestWatermarkOnlyMovesForward(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	later := time.Now().Truncate(time.Second)
+
+	if err := store.RevokeUserTokensBefore(ctx, "user", later); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeUserTokensBefore(ctx, "user", later.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	watermark, err := store.UserTokensRevokedBefore(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !watermark.Equal(later) {
+		t.Fatalf("got %v, want %v", watermark, later)
+	}
+}
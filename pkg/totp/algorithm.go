@@ -0,0 +1,59 @@
+package totp
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"strings"
+)
+
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+var ErrUnsupportedAlgorithm = errors.New("totp: unsupported algorithm")
+
+// ParseAlgorithm accepts the otpauth spellings (SHA1, SHA256, SHA512) in any
+// case and with an optional dash, e.g. "sha-256".
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch Algorithm(strings.ReplaceAll(strings.ToUpper(name), "-", "")) {
+	case SHA1:
+		return SHA1, nil
+	case SHA256:
+		return SHA256, nil
+	case SHA512:
+		return SHA512, nil
+	default:
+		return "", ErrUnsupportedAlgorithm
+	}
+}
+
+func (a Algorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+func (a Algorithm) keySize() int {
+	switch a {
+	case SHA256:
+		return sha256.Size
+	case SHA512:
+		return sha512.Size
+	default:
+		return sha1.Size
+	}
+}
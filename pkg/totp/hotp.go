@@ -0,0 +1,41 @@
+package totp
+
+// ValidateHOTP checks an RFC 4226 counter-based code. Counters from
+// lastCounter+1 up to opts.Window ahead are tried so a few button presses
+// that never reached the server do not desynchronise the token. It returns the
+// matching counter, which the caller must persist as the new lastCounter.
+func ValidateHOTP(secret, otp string, opts Options, lastCounter int64) (int64, bool) {
+	opts = opts.withDefaults()
+
+	lookAhead := int64(opts.Window)
+	if lookAhead < 1 {
+		lookAhead = 1
+	}
+
+	for counter := lastCounter + 1; counter <= lastCounter+lookAhead; counter++ {
+		if matches(secret, otp, counter, opts) {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+// ResyncHOTP implements the resynchronisation protocol of RFC 4226 section
+// 7.4: when a token has drifted beyond the regular look-ahead, the user sends
+// two consecutive codes and the server searches up to resyncWindow counters
+// ahead for the pair. It returns the counter of the second code.
+func ResyncHOTP(secret, otp, nextOTP string, opts Options, lastCounter int64, resyncWindow int) (int64, bool) {
+	opts = opts.withDefaults()
+	if opts.Type != TypeHOTP || resyncWindow < 1 {
+		return 0, false
+	}
+
+	for counter := lastCounter + 1; counter <= lastCounter+int64(resyncWindow); counter++ {
+		if matches(secret, otp, counter, opts) && matches(secret, nextOTP, counter+1, opts) {
+			return counter + 1, true
+		}
+	}
+
+	return 0, false
+}
@@ -3,7 +3,9 @@ package totp
 import (
 	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"net/url"
@@ -12,29 +14,84 @@ import (
 	"time"
 )
 
-func VerifyOTP(secret, otp string, timeStep, window, digits int) bool {
-	if timeStep <= 0 {
-		timeStep = 30
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+)
+
+// Options are the RFC 4226/6238 parameters used to generate and accept codes.
+// For TOTP, Window is the number of time steps accepted on either side of the
+// current one to tolerate clock drift. For HOTP it is the look-ahead: how many
+// counters past the last accepted one are tried. Period is ignored for HOTP.
+type Options struct {
+	Type      string
+	Algorithm Algorithm
+	Period    int
+	Digits    int
+	Window    int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Type == "" {
+		o.Type = TypeTOTP
 	}
-	if digits <= 0 {
-		digits = 6
+	if o.Algorithm == "" {
+		o.Algorithm = SHA1
+	}
+	if o.Period <= 0 {
+		o.Period = 30
+	}
+	if o.Digits <= 0 {
+		o.Digits = 6
 	}
+	if o.Window < 0 {
+		o.Window = 0
+	}
+	return o
+}
 
-	now := time.Now().Unix()
-	currentCounter := now / int64(timeStep)
+// Verify dispatches to Validate or ValidateHOTP depending on opts.Type.
+func Verify(secret, otp string, opts Options, lastCounter int64) (int64, bool) {
+	if opts.Type == TypeHOTP {
+		return ValidateHOTP(secret, otp, opts, lastCounter)
+	}
+	return Validate(secret, otp, opts, lastCounter)
+}
 
-	for i := -window; i <= window; i++ {
+// Validate checks a TOTP code against every time step in the drift window and
+// returns the counter of the matching step. Steps at or below lastCounter are
+// skipped so a code that was already accepted cannot be replayed; pass -1 when
+// no code has been accepted yet.
+func Validate(secret, otp string, opts Options, lastCounter int64) (int64, bool) {
+	opts = opts.withDefaults()
+
+	currentCounter := time.Now().Unix() / int64(opts.Period)
+
+	for i := -opts.Window; i <= opts.Window; i++ {
 		counter := currentCounter + int64(i)
-		generated, err := generateOTPForCounter(secret, counter, digits)
-		if err != nil {
-			return false
+		if counter <= lastCounter {
+			continue
 		}
-		if generated == otp {
-			return true
+
+		if matches(secret, otp, counter, opts) {
+			return counter, true
 		}
 	}
 
-	return false
+	return 0, false
+}
+
+func matches(secret, otp string, counter int64, opts Options) bool {
+	generated, err := generateOTPForCounter(secret, counter, opts.Digits, opts.Algorithm)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(generated), []byte(otp)) == 1
+}
+
+func VerifyOTP(secret, otp string, timeStep, window, digits int) bool {
+	_, ok := Validate(secret, otp, Options{Period: timeStep, Digits: digits, Window: window}, -1)
+	return ok
 }
 
 func GenerateOTP(secret string, timeStep, digits int) (string, error) {
@@ -47,26 +104,31 @@ func GenerateOTP(secret string, timeStep, digits int) (string, error) {
 
 	now := time.Now().Unix()
 	counter := now / int64(timeStep)
-	return generateOTPForCounter(secret, counter, digits)
+	return generateOTPForCounter(secret, counter, digits, SHA1)
 }
 
-func generateOTPForCounter(secret string, counter int64, digits int) (string, error) {
+// GenerateCode returns the code for an explicit counter: the moving factor for
+// HOTP, or the time step (Unix time / period) for TOTP.
+func GenerateCode(secret string, counter int64, opts Options) (string, error) {
+	opts = opts.withDefaults()
+	return generateOTPForCounter(secret, counter, opts.Digits, opts.Algorithm)
+}
+
+func generateOTPForCounter(secret string, counter int64, digits int, algorithm Algorithm) (string, error) {
 	key, err := base32Decode(secret)
 	if err != nil {
 		return "", err
 	}
 
+	newHash, err := algorithm.hash()
+	if err != nil {
+		return "", err
+	}
+
 	var msg [8]byte
-	msg[0] = 0
-	msg[1] = 0
-	msg[2] = 0
-	msg[3] = 0
-	msg[4] = byte(counter >> 24)
-	msg[5] = byte(counter >> 16)
-	msg[6] = byte(counter >> 8)
-	msg[7] = byte(counter)
-
-	h := hmac.New(sha1.New, key)
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	h := hmac.New(newHash, key)
 	_, _ = h.Write(msg[:])
 	hash := h.Sum(nil)
 
@@ -120,6 +182,17 @@ func base32Decode(data string) ([]byte, error) {
 	return result, nil
 }
 
+// GenerateSecret returns a base32 secret whose key length matches the HMAC
+// output size of the algorithm, as recommended by RFC 4226 section 4.
+func GenerateSecret(algorithm Algorithm) string {
+	key := make([]byte, algorithm.keySize())
+	if _, err := rand.Read(key); err != nil {
+		return ""
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
+}
+
 func GenerateTwoFactorSecret() string {
 	base32Chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
 	secret := make([]byte, 16)
@@ -137,25 +210,44 @@ func GenerateTwoFactorSecret() string {
     return string(secret)
 }
 
-func BuildUrl(secret string, email string, issuer string) string {
+// BuildUrl returns the otpauth:// provisioning URI. HOTP secrets always start
+// at counter 0.
+func BuildUrl(secret string, email string, issuer string, opts Options) string {
+	opts = opts.withDefaults()
 	label := url.QueryEscape(fmt.Sprintf("%s:%s", issuer, email))
 
 	v := url.Values{}
 	v.Set("secret", secret)
 	v.Set("issuer", issuer)
-	v.Set("period", "30")
-	v.Set("digits", "6")
-	v.Set("algorithm", "SHA1")
+	v.Set("digits", strconv.Itoa(opts.Digits))
+	v.Set("algorithm", string(opts.Algorithm))
+	if opts.Type == TypeHOTP {
+		v.Set("counter", "0")
+	} else {
+		v.Set("period", strconv.Itoa(opts.Period))
+	}
 
-	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
+	return fmt.Sprintf("otpauth://%s/%s?%s", opts.Type, label, v.Encode())
 }
 
 func GenerateRecoveryCodes(count int) []string {
 	var recoveryCodes []string
 
-	for i := 0; i <= count; i++ {
+	for i := 0; i < count; i++ {
 		recoveryCodes = append(recoveryCodes, rand.Text())
 	}
 
 	return recoveryCodes
+}
+
+// NormalizeRecoveryCode strips the separators and casing users tend to add
+// when typing a code so it matches the generated form before hashing.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '\t' {
+			return -1
+		}
+		return r
+	}, code)
 }
\ No newline at end of file
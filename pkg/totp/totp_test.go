@@ -0,0 +1,173 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret encodes the ASCII seeds of the RFC test vectors the way secrets
+// are stored: unpadded base32.
+func rfcSecret(seed string) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(seed))
+}
+
+var (
+	secretSHA1   = rfcSecret("12345678901234567890")
+	secretSHA256 = rfcSecret("12345678901234567890123456789012")
+	secretSHA512 = rfcSecret("1234567890123456789012345678901234567890123456789012345678901234")
+)
+
+// RFC 4226 Appendix D.
+var hotpVectors = []string{
+	"755224", "287082", "359152", "969429", "338314",
+	"254676", "287922", "162583", "399871", "520489",
+}
+
+func TestGenerateCodeHOTPVectors(t *testing.T) {
+	opts := Options{Type: TypeHOTP}
+
+	for counter, want := range hotpVectors {
+		got, err := GenerateCode(secretSHA1, int64(counter), opts)
+		if err != nil {
+			t.Fatalf("counter %d: %v", counter, err)
+		}
+		if got != want {
+			t.Errorf("counter %d: got %s, want %s", counter, got, want)
+		}
+	}
+}
+
+// RFC 6238 Appendix B.
+func TestGenerateCodeTOTPVectors(t *testing.T) {
+	tests := []struct {
+		unix   int64
+		sha1   string
+		sha256 string
+		sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+
+	for _, tt := range tests {
+		for _, c := range []struct {
+			algorithm Algorithm
+			secret    string
+			want      string
+		}{
+			{SHA1, secretSHA1, tt.sha1},
+			{SHA256, secretSHA256, tt.sha256},
+			{SHA512, secretSHA512, tt.sha512},
+		} {
+			opts := Options{Algorithm: c.algorithm, Digits: 8}
+			got, err := GenerateCode(c.secret, tt.unix/30, opts)
+			if err != nil {
+				t.Fatalf("%s at %d: %v", c.algorithm, tt.unix, err)
+			}
+			if got != c.want {
+				t.Errorf("%s at %d: got %s, want %s", c.algorithm, tt.unix, got, c.want)
+			}
+		}
+	}
+}
+
+func TestValidateHOTPLookAhead(t *testing.T) {
+	opts := Options{Type: TypeHOTP, Window: 3}
+
+	tests := []struct {
+		name        string
+		otp         string
+		lastCounter int64
+		wantCounter int64
+		wantOK      bool
+	}{
+		{"next counter", hotpVectors[1], 0, 1, true},
+		{"within look-ahead", hotpVectors[3], 0, 3, true},
+		{"beyond look-ahead", hotpVectors[4], 0, 0, false},
+		{"already used", hotpVectors[2], 2, 0, false},
+		{"older counter", hotpVectors[1], 2, 0, false},
+		{"wrong code", "000000", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := ValidateHOTP(secretSHA1, tt.otp, opts, tt.lastCounter)
+			if ok != tt.wantOK || counter != tt.wantCounter {
+				t.Errorf("got (%d, %v), want (%d, %v)", counter, ok, tt.wantCounter, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestResyncHOTP(t *testing.T) {
+	opts := Options{Type: TypeHOTP, Window: 1}
+
+	tests := []struct {
+		name         string
+		otp          string
+		nextOTP      string
+		resyncWindow int
+		wantCounter  int64
+		wantOK       bool
+	}{
+		{"consecutive pair", hotpVectors[6], hotpVectors[7], 10, 7, true},
+		{"missing second code", hotpVectors[6], "", 10, 0, false},
+		{"pair beyond window", hotpVectors[6], hotpVectors[7], 4, 0, false},
+		{"non-consecutive pair", hotpVectors[6], hotpVectors[8], 10, 0, false},
+		{"reversed pair", hotpVectors[7], hotpVectors[6], 10, 0, false},
+		{"no window", hotpVectors[6], hotpVectors[7], 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := ResyncHOTP(secretSHA1, tt.otp, tt.nextOTP, opts, 0, tt.resyncWindow)
+			if ok != tt.wantOK || counter != tt.wantCounter {
+				t.Errorf("got (%d, %v), want (%d, %v)", counter, ok, tt.wantCounter, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := ResyncHOTP(secretSHA1, hotpVectors[6], hotpVectors[7], Options{Type: TypeTOTP}, 0, 10); ok {
+		t.Error("resynchronised a TOTP secret")
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	opts := Options{Algorithm: SHA256, Window: 1}
+
+	// Stay clear of a step boundary so the current step cannot change
+	// between generating and validating.
+	if time.Now().Unix()%30 >= 28 {
+		time.Sleep(3 * time.Second)
+	}
+	current := time.Now().Unix() / 30
+
+	code := func(counter int64) string {
+		t.Helper()
+		otp, err := GenerateCode(secretSHA256, counter, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return otp
+	}
+
+	for _, offset := range []int64{-1, 0, 1} {
+		counter, ok := Validate(secretSHA256, code(current+offset), opts, -1)
+		if !ok || counter != current+offset {
+			t.Errorf("offset %d: got (%d, %v)", offset, counter, ok)
+		}
+	}
+
+	if _, ok := Validate(secretSHA256, code(current+3), opts, -1); ok {
+		t.Error("accepted a code outside the drift window")
+	}
+
+	if _, ok := Validate(secretSHA256, code(current), opts, current+1); ok {
+		t.Error("accepted a code at or below the last accepted step")
+	}
+}